@@ -2,39 +2,311 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"smuggler/internal/ai"
+	"smuggler/internal/apiserver"
+	"smuggler/internal/config"
+	"smuggler/internal/detector"
+	"smuggler/internal/logger"
+	"smuggler/internal/models"
+	"smuggler/internal/payload/mutate"
 	"smuggler/internal/scanner"
+	"smuggler/internal/sender"
+	"smuggler/internal/targets"
+	"smuggler/pkg/utils"
 )
 
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func (l *stringList) Values() []string {
+	return *l
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent writes, used to hold
+// a target's output while it is scanned alongside others.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+// Process exit codes, so pipelines can act on the outcome of a scan.
+const (
+	exitClean      = 0 // every target scanned, nothing suspicious
+	exitError      = 1 // bad flags or at least one target failed
+	exitSuspicious = 2 // at least one target was flagged suspicious
+)
+
+// reportExtensions maps each -output-format to its report file extension.
+var reportExtensions = map[string]string{
+	"text":   "txt",
+	"json":   "json",
+	"jsonl":  "jsonl",
+	"nuclei": "jsonl",
+	"sarif":  "sarif",
+	"html":   "html",
+}
+
+// targetReport is one scanned target's contribution to a report.
+type targetReport struct {
+	url     string
+	scanner *scanner.Scanner
+}
+
+// writeReport writes the results of the given targets to w in format.
+func writeReport(w io.Writer, format string, reports []targetReport) error {
+	var all []*models.ScanResult
+	for _, r := range reports {
+		all = append(all, r.scanner.GetResults()...)
+	}
+
+	switch format {
+	case "json":
+		return utils.WriteJSONArray(w, all)
+	case "jsonl":
+		return utils.WriteJSONLines(w, all)
+	case "nuclei":
+		for _, r := range reports {
+			if err := utils.WriteNucleiJSON(w, r.url, r.scanner.GetResults()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "sarif":
+		targets := make([]utils.SARIFTarget, 0, len(reports))
+		for _, r := range reports {
+			targets = append(targets, utils.SARIFTarget{URL: r.url, Results: r.scanner.GetResults()})
+		}
+		return utils.WriteSARIF(w, targets)
+	case "html":
+		label := fmt.Sprintf("%d targets", len(reports))
+		if len(reports) == 1 {
+			label = reports[0].url
+		}
+		page, err := utils.GenerateHTMLReport(all, label)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, page)
+		return err
+	default:
+		for _, r := range reports {
+			if _, err := fmt.Fprintf(w, "%s\n%s\n", r.scanner.GetReport().String(), r.scanner.Summary()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
 func main() {
+	// Report bad flags with exitError; the flag package's default exit
+	// code of 2 would read as a finding
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+
 	// Command-line flags
 	target := flag.String("target", "", "Target host or URL to scan (e.g. example.com or https://example.com:8443)")
-	targets := flag.String("targets", "", "Comma-separated list of targets (hostnames or URLs)")
-	inputFile := flag.String("input-file", "", "Path to file containing targets (one per line)")
-	port := flag.Int("port", 443, "Target port")
+	targetsFlag := flag.String("targets", "", "Comma-separated list of targets (hostnames or URLs)")
+	inputFile := flag.String("input-file", "", "Path to file containing targets (one per line, lines starting with # are skipped, - for stdin)")
+	port := flag.Int("port", 443, "Target port for targets that do not name one")
+	ports := flag.String("ports", "", "Ports to scan on every target without an explicit port, e.g. 80,443,8000-8010")
+	maxTargets := flag.Int("max-targets", 10000, "Abort if more than this many distinct targets are given, unless -force is set")
+	force := flag.Bool("force", false, "Proceed even when the target count exceeds -max-targets")
+	yes := flag.Bool("yes", false, "Same as -force")
+	statePath := flag.String("state", "", "Checkpoint file (JSON) recording each completed target and its outcome; targets already in it are skipped, so an interrupted run can be resumed")
+	restart := flag.Bool("restart", false, "Ignore the targets recorded in -state and scan them all again")
 	confidence := flag.Float64("confidence", 0.5, "Minimum confidence threshold (0.0-1.0)")
-	https := flag.Bool("https", false, "Use HTTPS/TLS connection")
+	https := flag.Bool("https", false, "Use HTTPS/TLS for targets whose scheme or port (80/443) does not decide it")
 	insecure := flag.Bool("insecure", false, "Skip TLS certificate verification (for lab/testing only)")
-	verbose := flag.Bool("v", false, "Verbose output")
+	sni := flag.String("sni", "", "TLS server name to send instead of the target host, e.g. a virtual host on a front-end dialed by IP")
+	hostHeader := flag.String("host-header", "", "Host header to send instead of the target host[:port], independently of -sni")
+	clientCert := flag.String("client-cert", "", "PEM client certificate for targets that require mTLS (with -client-key)")
+	clientKey := flag.String("client-key", "", "PEM private key for -client-cert")
+	caCert := flag.String("ca-cert", "", "PEM CA certificate(s) to verify targets against instead of the system roots, e.g. an internal CA")
+	verboseFlag := flag.Bool("v", false, "Verbose output: settings, per-target banners and live throughput")
+	debugFlag := flag.Bool("vv", false, "Debug output: like -v, plus every payload sent and every raw response")
+	quiet := flag.Bool("quiet", false, "Print only warnings and the final report, not per-step progress")
+	noColor := flag.Bool("no-color", false, "Disable colored verdicts (also off when stdout is not a terminal or NO_COLOR is set)")
+	confirm := flag.Int("confirm-runs", 0, "Re-run each suspicious technique N times and keep it only if a majority of the repeats also flag")
+	flag.IntVar(confirm, "confirm", 0, "Alias for -confirm-runs")
+	probeCount := flag.Int("probe-count", 1, "Number of probe requests the GPOST test sends after the smuggling payload, stopping at the first that shows poisoning")
+	pipelineBaseline := flag.Int("pipeline-baseline", 0, "Pipeline N benign requests on one connection to verify connection reuse before testing (0 disables)")
+	var cl0PathFlags stringList
+	flag.Var(&cl0PathFlags, "cl0-path", "Endpoint to test for CL.0 desync, e.g. a static asset or redirect (repeatable)")
+	smuggleMethod := flag.String("smuggle-method", "", "Method of the smuggled victim request (default GET)")
+	smugglePath := flag.String("smuggle-path", "", "Path of the smuggled victim request (default depends on the test: /admin, /api or /secret)")
+	var smuggleHeaderFlags stringList
+	flag.Var(&smuggleHeaderFlags, "smuggle-header", "Header for the smuggled victim request, \"Name: value\" (repeatable)")
+	var headerFlags stringList
+	flag.Var(&headerFlags, "header", "Header sent with the baseline and every test request, \"Name: value\" (repeatable), e.g. a Cookie or Authorization for authenticated areas")
+	userAgent := flag.String("user-agent", "", "User-Agent sent with the baseline and every test request")
+	baselineSamples := flag.Int("baseline-samples", 1, "Capture the baseline N times and use the median of the non-outlier samples as the reference")
+	exhaustive := flag.Bool("exhaustive", false, "Try every Obfuscated-TE and Chunk-Ext variant and chunk layout instead of stopping at the first high-confidence finding")
+	chunkSweep := flag.Bool("chunk-sweep", false, "Also run CL.TE and TE.CL with alternative chunk layouts (data chunks, zero-padded sizes, sizes that disagree with the data)")
+	chunkExtension := flag.String("chunk-extension", "", "Chunk extension for the chunk-ext test to send instead of the built-in ones, e.g. 'a=\"b\"' (sent as 1;<ext>)")
+	fuzz := flag.String("fuzz", "", "Finish with a fuzzing run that mutates header case, spacing, order and folding of this technique's payload: "+strings.Join(scanner.FuzzTechniques, ", "))
+	fuzzVariants := flag.Int("fuzz-variants", scanner.DefaultFuzzVariants, fmt.Sprintf("Maximum number of -fuzz variants to send (at most %d)", mutate.MaxVariants))
+	timingSamples := flag.Int("timing-samples", 0, "Send each payload and the baseline N times and compare median timing against the jitter (e.g. 5; 0 or 1 sends once)")
+	http2 := flag.Bool("http2", false, "Also test HTTP/2 downgrade smuggling (H2.CL, H2.TE); needs a front-end that speaks HTTP/2 (ALPN h2, or h2c without -https)")
+	pathsFile := flag.String("paths-file", "", "Wordlist of paths to sweep for CL.0 desync (one per line)")
+	pathsMax := flag.Int("paths-max", 500, "Maximum number of paths to sweep from -paths-file")
+	rate := flag.Float64("rate", 0, "Maximum requests per second across all targets (0 = unlimited)")
+	delay := flag.Duration("delay", 0, "Minimum delay between requests across all targets (e.g. 500ms); with -rate the slower of the two applies")
+	sweepDelay := flag.Duration("sweep-delay", 100*time.Millisecond, "Delay between requests in path sweeps")
+	step := flag.Bool("step", false, "Interactive step mode: show each intrusive payload and ask before sending it (requires a terminal)")
+	weightsFile := flag.String("weights", "", "JSON file overriding detector signal weights and finding severities, e.g. {\"timing-slower\": 0.1, \"TE.CL:body-changed\": 0, \"severity\": {\"CL.TE\": \"critical\"}}")
+	blockStatusesFlag := flag.String("block-statuses", "", "Comma-separated status codes the target uses for block pages (e.g. 403,429,406); tests answered with one are reported as blocked, not analyzed")
+	connectTimeout := flag.Duration("connect-timeout", 10*time.Second, "Timeout for connecting to the target (including proxy and TLS handshake)")
+	readTimeout := flag.Duration("read-timeout", 10*time.Second, "Timeout for reading each response")
+	firstByteTimeout := flag.Duration("first-byte-timeout", 0, "Give up on a response that has not started within this long, flagging it as hanging (0 uses the full read timeout)")
+	explainTiming := flag.Bool("explain-timing", false, "Print connect/TLS/time-to-first-byte breakdown for every response")
+	ignoreHeaders := flag.String("ignore-headers", "", "Comma-separated headers to ignore when comparing responses, in addition to volatile defaults (Date, Set-Cookie, ...)")
+	compareHeaders := flag.String("compare-headers", "", "Comma-separated headers to compare even though they are ignored as volatile by default")
+	compareMethod := flag.Bool("compare-baseline-method", false, "Compare GET and POST baselines to detect method-based routing before testing")
+	techniquesFlag := flag.String("techniques", "", "Comma-separated techniques to run (e.g. clte,tecl); valid: "+strings.Join(detector.AllTechniques, ", ")+" (default: all)")
+	auto := flag.Bool("auto", false, "Run only the techniques recommended for the fingerprinted stack")
+	failFast := flag.Bool("fail-fast", false, "Stop at the first target that fails instead of continuing with the rest")
+	exitZero := flag.Bool("exit-zero", false, "Exit 0 even when targets are flagged suspicious (errors still exit 1)")
+	concurrency := flag.Int("concurrency", 1, "Number of targets to scan in parallel")
+	seed := flag.Int64("seed", 0, "Seed for randomized behavior; replays a previous run exactly (default: time-based)")
+	output := flag.String("output", "", "Write the results of all targets to this file, in -output-format")
+	outputFormat := flag.String("output-format", "text", "Report format: text, json, jsonl, nuclei, sarif or html; without -output, formats other than text are written to stdout")
+	outputDir := flag.String("output-dir", "", "Also write each target's report to its own file (host_port.<ext>) in this directory, in -output-format")
+	storeBytes := flag.Int("store-response-bytes", models.DefaultStoreResponseBytes, "Bytes of each baseline/test response kept on results (0 keeps none, -1 keeps all)")
+	dryRun := flag.Bool("dry-run", false, "Print each technique's payload (CRLF shown as \\r\\n, with byte counts) instead of sending it; nothing is sent, not even a baseline")
+	redactBodies := flag.Bool("redact-bodies", false, "Replace response bodies in results and reports with their size, for sharing reports externally (headers and sent payloads are kept)")
+	storeFindings := flag.Bool("store-full-findings", false, "Keep full responses on suspicious results regardless of -store-response-bytes")
+	watchdog := flag.Duration("watchdog", 0, "Warn when a target makes no progress for this long and cancel it after twice as long (0 disables)")
+	serve := flag.String("serve", "", "Run as an HTTP API server on this address (e.g. :8080) instead of scanning targets")
 	_ = flag.Bool("advanced", false, "(deprecated)")
+	configFile := flag.String("config", "", "YAML or JSON scan profile setting any of these flags by name, e.g. \"techniques: [clte, tecl]\"; flags given on the command line win")
+	printConfig := flag.Bool("print-config", false, "Print the effective configuration (profile merged with flags) as YAML and exit")
+
+	// Proxy flags
+	proxyURL := flag.String("proxy", "", "Upstream proxy URL: http://, https:// or socks5:// (e.g. http://127.0.0.1:8080 for Burp, socks5://127.0.0.1:1080)")
+	proxyAuth := flag.String("proxy-auth", "", "Credentials for the upstream proxy (user:pass); basic auth for HTTP proxies, username/password for SOCKS5")
+	var proxyHeaders stringList
+	flag.Var(&proxyHeaders, "proxy-header", "Extra header for the proxy CONNECT request, e.g. \"Proxy-Authorization: Bearer ...\" (repeatable)")
+
+	// DNS flags
+	var resolvePins stringList
+	flag.Var(&resolvePins, "resolve", "Pin a host to an address, bypassing DNS: host:addr (repeatable)")
+	dnsTTL := flag.Duration("dns-ttl", sender.DefaultDNSCacheTTL, "How long resolved addresses are cached within a run")
 
 	// AI flags
 	useAI := flag.Bool("ai", false, "Enable AI-powered analysis")
-	aiBackend := flag.String("ai-backend", "openai", "AI backend: openai or ollama")
-	apiKey := flag.String("api-key", "", "OpenAI API key for AI analysis")
+	aiBackend := flag.String("ai-backend", "openai", "AI backend: openai, ollama or gemini")
+	apiKey := flag.String("api-key", "", "OpenAI or Gemini API key for AI analysis")
+	openaiModel := flag.String("openai-model", ai.DefaultOpenAIModel, "OpenAI model (gpt-4o, gpt-4o-mini, ...) or Azure deployment name")
+	openaiBaseURL := flag.String("openai-base-url", ai.DefaultOpenAIBaseURL, "Base URL of an OpenAI-compatible API (Azure OpenAI deployment, vLLM gateway, ...); the key is optional for non-default URLs")
 	ollamaEndpoint := flag.String("ollama-endpoint", "http://localhost:11434", "Ollama API endpoint")
 	ollamaModel := flag.String("ollama-model", "llama2", "Ollama model name (llama2, mistral, neural-chat, etc.)")
+	geminiModel := flag.String("gemini-model", "gemini-1.5-flash", "Gemini model name (gemini-1.5-flash, gemini-1.5-pro, etc.)")
+	aiMaxPrompt := flag.Int("ai-max-prompt-bytes", ai.DefaultMaxPromptBytes, "Cap on the size of each AI prompt; response headers and body snippets are shortened to fit (0 = no cap)")
+	aiCache := flag.String("ai-cache", "", "JSON file caching AI replies across runs (default: in-memory cache for this run only)")
+	aiCacheTTL := flag.Duration("ai-cache-ttl", 24*time.Hour, "Expire cached AI replies older than this (0 = never)")
+	aiTimeout := flag.Duration("ai-timeout", 0, "Timeout for each AI API call (0 = backend default: 30s, 60s for Ollama)")
+	aiSuggest := flag.Bool("ai-suggest", false, "After the standard tests, ask the AI for payload strategies based on the results and print them")
+	aiAdaptive := flag.Bool("ai-adaptive", false, "Like -ai-suggest, and also run the suggested techniques this scan skipped as a second round")
+	aiPrompts := flag.String("ai-prompts", "", "JSON file overriding the AI prompt templates (keys: analyze, suggest, report, identify)")
+
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(exitClean)
+		}
+		os.Exit(exitError)
+	}
+
+	// Merge the profile under the command line. -confirm is an alias, so
+	// either spelling on the command line keeps the profile's value out.
+	if *configFile != "" {
+		explicit := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) {
+			explicit[f.Name] = true
+		})
+		if explicit["confirm"] || explicit["confirm-runs"] {
+			explicit["confirm"], explicit["confirm-runs"] = true, true
+		}
+
+		profile, err := config.Load(*configFile)
+		if err != nil {
+			log.Fatalf("Invalid -config: %v", err)
+		}
+		if err := profile.Apply(flag.CommandLine, explicit, "config"); err != nil {
+			log.Fatalf("Invalid -config: %v", err)
+		}
+	}
 
-	flag.Parse()
+	level := logger.LevelNormal
+	switch {
+	case *quiet && (*verboseFlag || *debugFlag):
+		log.Fatal("-quiet cannot be combined with -v or -vv")
+	case *quiet:
+		level = logger.LevelQuiet
+	case *debugFlag:
+		level = logger.LevelDebug
+	case *verboseFlag:
+		level = logger.LevelVerbose
+	}
+	verbose := level >= logger.LevelVerbose
+	color := !*noColor && os.Getenv("NO_COLOR") == "" && logger.IsTerminal(os.Stdout)
+	lg := logger.New(os.Stdout, level, color)
+
+	if *printConfig {
+		effective := config.Effective(flag.CommandLine, "config", "print-config", "advanced", "confirm")
+		for _, secret := range []string{"api-key", "proxy-auth"} {
+			if v, ok := effective[secret].(string); ok && v != "" {
+				effective[secret] = "REDACTED"
+			}
+		}
+		if err := config.Write(os.Stdout, effective); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(exitClean)
+	}
+
+	// normalize splits a target string into host, port and TLS decision
+	// under -port and -https (see targets.Normalize).
+	normalize := func(raw string) (string, int, bool, error) {
+		return targets.Normalize(raw, *port, *https)
+	}
 
 	// Gather targets list
 	var targetList []string
@@ -43,8 +315,8 @@ func main() {
 	if *target != "" {
 		targetList = append(targetList, *target)
 	}
-	if *targets != "" {
-		for _, t := range strings.Split(*targets, ",") {
+	if *targetsFlag != "" {
+		for _, t := range strings.Split(*targetsFlag, ",") {
 			t = strings.TrimSpace(t)
 			if t != "" {
 				targetList = append(targetList, t)
@@ -52,15 +324,21 @@ func main() {
 		}
 	}
 	if *inputFile != "" {
-		f, err := os.Open(*inputFile)
-		if err != nil {
-			log.Fatalf("failed to open input file: %v", err)
+		var f *os.File
+		if *inputFile == "-" {
+			f = os.Stdin
+		} else {
+			var err error
+			f, err = os.Open(*inputFile)
+			if err != nil {
+				log.Fatalf("failed to open input file: %v", err)
+			}
+			defer f.Close()
 		}
-		defer f.Close()
 		scannerFile := bufio.NewScanner(f)
 		for scannerFile.Scan() {
 			line := strings.TrimSpace(scannerFile.Text())
-			if line != "" {
+			if line != "" && !strings.HasPrefix(line, "#") {
 				targetList = append(targetList, line)
 			}
 		}
@@ -77,10 +355,101 @@ func main() {
 		}
 	}
 
-	if len(targetList) == 0 {
+	// Expand CIDRs and bracket ranges, then pair hosts with -ports
+	var expanded []string
+	for _, t := range targetList {
+		list, err := targets.Expand(t)
+		if err != nil {
+			log.Fatalf("Invalid target %s: %v", t, err)
+		}
+		expanded = append(expanded, list...)
+	}
+	targetList = expanded
+
+	if *ports != "" {
+		portList, err := targets.ParsePorts(*ports)
+		if err != nil {
+			log.Fatalf("Invalid -ports: %v", err)
+		}
+		targetList = targets.WithPorts(targetList, portList)
+	}
+
+	// Drop duplicates so a target listed twice is only scanned once, even
+	// when written differently (example.com, EXAMPLE.com:80,
+	// http://example.com/). Targets that do not normalize are kept as
+	// written and reported when their turn comes.
+	seenTargets := make(map[string]bool)
+	uniqueTargets := targetList[:0]
+	for _, t := range targetList {
+		key := strings.ToLower(strings.TrimSuffix(t, "/"))
+		if host, p, useTLS, err := normalize(t); err == nil {
+			key = fmt.Sprintf("%s|%d|%t", strings.ToLower(host), p, useTLS)
+		}
+		if !seenTargets[key] {
+			seenTargets[key] = true
+			uniqueTargets = append(uniqueTargets, t)
+		}
+	}
+	targetList = uniqueTargets
+
+	if len(targetList) == 0 && *serve == "" {
 		log.Fatal("No targets provided. Use -target, -targets, -input-file, or pass targets as arguments")
 	}
 
+	// stateKey names a target in the -state checkpoint by its normalized
+	// URL, so a resumed run matches it however it is written.
+	stateKey := func(raw string) string {
+		host, p, useTLS, err := normalize(raw)
+		if err != nil {
+			return raw
+		}
+		scheme := "http"
+		if useTLS {
+			scheme = "https"
+		}
+		return fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(strings.ToLower(host), strconv.Itoa(p)))
+	}
+
+	var state *targets.State
+	if *restart && *statePath == "" {
+		log.Fatal("-restart requires -state")
+	}
+	if *statePath != "" {
+		if *serve != "" || *dryRun {
+			log.Fatal("-state cannot be combined with -serve or -dry-run")
+		}
+		if *restart {
+			state = targets.NewState(*statePath)
+		} else {
+			var err error
+			if state, err = targets.LoadState(*statePath); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		remaining := targetList[:0]
+		for _, t := range targetList {
+			if !state.Done(stateKey(t)) {
+				remaining = append(remaining, t)
+			}
+		}
+		if done := len(targetList) - len(remaining); done > 0 {
+			lg.Infof("[+] Skipping %d targets already completed in %s (use -restart to scan them again)\n", done, *statePath)
+		}
+		targetList = remaining
+		if len(targetList) == 0 {
+			lg.Infof("[+] Nothing left to scan\n")
+			os.Exit(exitClean)
+		}
+	}
+
+	if *maxTargets > 0 && len(targetList) > *maxTargets && !*yes && !*force {
+		log.Fatalf("%d targets exceeds -max-targets %d; pass -force to scan them all or raise the limit", len(targetList), *maxTargets)
+	}
+	if len(targetList) > 0 {
+		lg.Infof("[+] Targets to scan: %d\n", len(targetList))
+	}
+
 	if *port < 1 || *port > 65535 {
 		log.Fatal("Port must be between 1 and 65535")
 	}
@@ -89,109 +458,645 @@ func main() {
 		log.Fatal("Confidence threshold must be between 0.0 and 1.0")
 	}
 
+	if *confirm < 0 {
+		log.Fatal("Confirm runs must be zero or positive")
+	}
+
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	// -output used to take the format itself; keep accepting that
+	if (*output == "nuclei" || *output == "sarif") && !setFlags["output-format"] {
+		log.Printf("[!] -output %s is deprecated; use -output-format %s", *output, *output)
+		*outputFormat = *output
+		*output = ""
+	}
+	if _, ok := reportExtensions[*outputFormat]; !ok {
+		log.Fatalf("Unknown output format: %s (use text, json, jsonl, nuclei, sarif or html)", *outputFormat)
+	}
+
+	// A dry run records no results, so there is no report to write
+	if *dryRun && (*output != "" || *outputDir != "") {
+		log.Fatal("-dry-run cannot be combined with -output or -output-dir: nothing is sent, so there is no report")
+	}
+
+	// Create the output file up front so a bad path fails before scanning
+	var outputFile *os.File
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Cannot create output file: %v", err)
+		}
+		outputFile = f
+	}
+
+	if *pipelineBaseline < 0 {
+		log.Fatal("Pipeline baseline count must be zero or positive")
+	}
+
+	if *watchdog < 0 {
+		log.Fatal("Watchdog interval must be zero or positive")
+	}
+
+	if *concurrency < 1 {
+		log.Fatal("-concurrency must be at least 1")
+	}
+	if *step {
+		if *concurrency > 1 {
+			log.Fatal("-step cannot be combined with -concurrency")
+		}
+		if *serve != "" {
+			log.Fatal("-step cannot be combined with -serve")
+		}
+		// Refuse rather than hang waiting on input nobody can type
+		if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+			log.Fatal("-step requires an interactive terminal on stdin")
+		}
+	}
+
+	if (*proxyAuth != "" || len(proxyHeaders) > 0) && *proxyURL == "" {
+		log.Fatal("-proxy-auth and -proxy-header require -proxy")
+	}
+	if *proxyAuth != "" && !strings.Contains(*proxyAuth, ":") {
+		log.Fatal("-proxy-auth must be in user:pass form")
+	}
+
+	if (*clientCert == "") != (*clientKey == "") {
+		log.Fatal("-client-cert and -client-key must be given together")
+	}
+	if *clientCert != "" {
+		if _, err := sender.LoadClientCert(*clientCert, *clientKey); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *caCert != "" {
+		if _, err := sender.LoadCACert(*caCert); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// One resolver for the whole run so each host is looked up once per TTL
+	resolver := sender.NewResolver(*dnsTTL)
+	for _, pin := range resolvePins {
+		host, addr, ok := strings.Cut(pin, ":")
+		if !ok || host == "" {
+			log.Fatalf("Invalid -resolve %q (expected host:addr)", pin)
+		}
+		if err := resolver.Pin(host, addr); err != nil {
+			log.Fatalf("Invalid -resolve %q: %v", pin, err)
+		}
+	}
+
+	smuggleHeaders := make(map[string]string)
+	for _, h := range smuggleHeaderFlags {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			log.Fatalf("Invalid -smuggle-header %q: want \"Name: value\"", h)
+		}
+		smuggleHeaders[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	// Framing headers are set per test; letting them through would break
+	// the payloads being tested.
+	framingHeaders := []string{"Host", "Content-Length", "Transfer-Encoding", "Connection"}
+	requestHeaders := make(map[string]string)
+	for _, h := range headerFlags {
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			log.Fatalf("Invalid -header %q: want \"Name: value\"", h)
+		}
+		for _, f := range framingHeaders {
+			if strings.EqualFold(name, f) {
+				log.Fatalf("Invalid -header %q: %s is set by each test and cannot be overridden", h, f)
+			}
+		}
+		requestHeaders[name] = strings.TrimSpace(value)
+	}
+	if *userAgent != "" {
+		for name := range requestHeaders {
+			if strings.EqualFold(name, "User-Agent") {
+				delete(requestHeaders, name)
+			}
+		}
+		requestHeaders["User-Agent"] = *userAgent
+	}
+
+	if *smugglePath != "" && !strings.HasPrefix(*smugglePath, "/") {
+		*smugglePath = "/" + *smugglePath
+	}
+
+	var cl0Paths []string
+	for _, p := range cl0PathFlags {
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+		cl0Paths = append(cl0Paths, p)
+	}
+	if *pathsFile != "" {
+		f, err := os.Open(*pathsFile)
+		if err != nil {
+			log.Fatalf("failed to open paths file: %v", err)
+		}
+		pathScanner := bufio.NewScanner(f)
+		for pathScanner.Scan() {
+			line := strings.TrimSpace(pathScanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !strings.HasPrefix(line, "/") {
+				line = "/" + line
+			}
+			cl0Paths = append(cl0Paths, line)
+		}
+		f.Close()
+		if err := pathScanner.Err(); err != nil {
+			log.Fatalf("error reading paths file: %v", err)
+		}
+		if len(cl0Paths) > *pathsMax {
+			log.Printf("[!] Limiting CL.0 sweep to the first %d of %d paths", *pathsMax, len(cl0Paths))
+			cl0Paths = cl0Paths[:*pathsMax]
+		}
+	}
+
+	splitList := func(v string) []string {
+		var out []string
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				out = append(out, item)
+			}
+		}
+		return out
+	}
+
+	techniques, err := detector.ParseTechniques(splitList(*techniquesFlag))
+	if err != nil {
+		log.Fatalf("Invalid -techniques: %v", err)
+	}
+	if len(techniques) > 0 && *auto {
+		log.Fatal("-techniques cannot be combined with -auto")
+	}
+
+	if *fuzz != "" {
+		*fuzz = strings.ToLower(strings.TrimSpace(*fuzz))
+		valid := false
+		for _, t := range scanner.FuzzTechniques {
+			valid = valid || t == *fuzz
+		}
+		if !valid {
+			log.Fatalf("Invalid -fuzz %q (valid: %s)", *fuzz, strings.Join(scanner.FuzzTechniques, ", "))
+		}
+	}
+	if *fuzzVariants < 0 || *fuzzVariants > mutate.MaxVariants {
+		log.Fatalf("-fuzz-variants must be between 0 and %d", mutate.MaxVariants)
+	}
+
+	if *rate < 0 || *delay < 0 {
+		log.Fatal("-rate and -delay cannot be negative")
+	}
+	limiter := sender.NewLimiter(*rate)
+	if *delay > limiter.Interval() {
+		limiter = sender.NewDelayLimiter(*delay)
+	}
+
+	var blockStatuses []int
+	for _, v := range splitList(*blockStatusesFlag) {
+		code, err := strconv.Atoi(v)
+		if err != nil || code < 100 || code > 599 {
+			log.Fatalf("Invalid -block-statuses entry %q: expected a status code between 100 and 599", v)
+		}
+		blockStatuses = append(blockStatuses, code)
+	}
+
+	var weights detector.WeightsFile
+	if *weightsFile != "" {
+		w, err := detector.LoadWeightsFile(*weightsFile)
+		if err != nil {
+			log.Fatalf("Invalid -weights: %v", err)
+		}
+		weights = *w
+	}
+
+	if !setFlags["seed"] {
+		*seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(*seed))
+	lg.Infof("[+] Random seed: %d (replay with -seed %d)\n", *seed, *seed)
+
+	if (*aiSuggest || *aiAdaptive) && !*useAI {
+		log.Fatal("-ai-suggest and -ai-adaptive require -ai")
+	}
+
 	var aiProvider ai.Provider
 	if *useAI {
 		if *aiBackend == "openai" {
 			if *apiKey == "" {
 				*apiKey = os.Getenv("OPENAI_API_KEY")
 			}
-			if *apiKey == "" {
+			if *apiKey == "" && *openaiBaseURL == ai.DefaultOpenAIBaseURL {
 				log.Fatal("OpenAI backend requires -api-key or OPENAI_API_KEY environment variable")
 			}
-			aiProvider = ai.NewAIAnalyzer(*apiKey)
+			if u, err := url.Parse(*openaiBaseURL); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+				log.Fatalf("Invalid -openai-base-url %q: expected an http:// or https:// URL", *openaiBaseURL)
+			}
+			openai := ai.NewAIAnalyzerWithModel(*apiKey, *openaiModel)
+			openai.SetBaseURL(*openaiBaseURL)
+			aiProvider = openai
 		} else if *aiBackend == "ollama" {
 			aiProvider = ai.NewOllamaAnalyzer(*ollamaEndpoint, *ollamaModel)
+		} else if *aiBackend == "gemini" {
+			if *apiKey == "" {
+				*apiKey = os.Getenv("GEMINI_API_KEY")
+			}
+			if *apiKey == "" {
+				log.Fatal("Gemini backend requires -api-key or GEMINI_API_KEY environment variable")
+			}
+			aiProvider = ai.NewGeminiAnalyzer(*apiKey, *geminiModel)
 		} else {
-			log.Fatalf("Unknown AI backend: %s (use 'openai' or 'ollama')", *aiBackend)
+			log.Fatalf("Unknown AI backend: %s (use 'openai', 'ollama' or 'gemini')", *aiBackend)
 		}
-	}
 
-	// Helper to normalize target strings into host:port and tls decision
-	normalize := func(raw string) (string, int, bool, error) {
-		// Trim surrounding whitespace and trailing slash
-		raw = strings.TrimSpace(raw)
-		raw = strings.TrimSuffix(raw, "/")
+		if cs, ok := aiProvider.(ai.CacheSetter); ok {
+			if *aiCache != "" {
+				cache, err := ai.NewFileCache(*aiCache, *aiCacheTTL)
+				if err != nil {
+					log.Fatalf("Invalid -ai-cache: %v", err)
+				}
+				cs.SetCache(cache)
+			} else {
+				cs.SetCache(ai.NewMemoryCache(ai.DefaultCacheEntries, *aiCacheTTL))
+			}
+		}
 
-		// If it looks like a URL, parse it
-		if strings.Contains(raw, "://") {
-			u, err := url.Parse(raw)
+		if hs, ok := aiProvider.(ai.HTTPClientSetter); ok {
+			hs.SetTimeout(*aiTimeout)
+		}
+
+		if pl, ok := aiProvider.(ai.PromptLimiter); ok {
+			pl.SetMaxPromptBytes(*aiMaxPrompt)
+		}
+
+		if *aiPrompts != "" {
+			prompts, err := ai.LoadPromptSet(*aiPrompts)
 			if err != nil {
-				return "", 0, false, err
-			}
-			host := u.Host
-			useTLS := u.Scheme == "https"
-			h, p, err := net.SplitHostPort(host)
-			if err == nil {
-				pi, _ := strconv.Atoi(p)
-				return h, pi, useTLS, nil
+				log.Fatalf("Invalid -ai-prompts: %v", err)
 			}
-			// no explicit port
-			if h == "" {
-				h = host
-			}
-			if useTLS {
-				return h, 443, true, nil
+			if pc, ok := aiProvider.(ai.PromptCustomizer); ok {
+				if err := pc.SetPrompts(prompts); err != nil {
+					log.Fatalf("Invalid -ai-prompts: %v", err)
+				}
 			}
-			return h, 80, false, nil
 		}
+	}
 
-		// Raw host, maybe with :port
-		if strings.Contains(raw, ":") {
-			h, p, err := net.SplitHostPort(raw)
-			if err == nil {
-				pi, err := strconv.Atoi(p)
-				if err != nil {
-					return "", 0, false, err
-				}
-				// heuristics: if port == 443 assume TLS
-				useTLS := pi == 443
-				return h, pi, useTLS, nil
-			}
+	// The tool's own upstream dependencies must never be scanned; a
+	// fat-fingered target list could otherwise smuggle against them. Hosts
+	// are compared through the run's resolver, so -resolve pins apply.
+	protected := targets.NewProtected(resolver)
+	if *proxyURL != "" {
+		protected.Add("-proxy", *proxyURL)
+	}
+	if *useAI && *aiBackend == "ollama" {
+		protected.Add("-ollama-endpoint", *ollamaEndpoint)
+	}
+	if *useAI && *aiBackend == "openai" {
+		protected.Add("-openai-base-url", *openaiBaseURL)
+	}
+	if *useAI && *aiBackend == "gemini" {
+		protected.Add("Gemini API", "https://generativelanguage.googleapis.com")
+	}
+
+	lg.Verbosef("[+] Confidence threshold: %.1f%%\n", *confidence*100)
+	if *https {
+		lg.Verbosef("[+] Using HTTPS/TLS\n")
+		if *insecure {
+			lg.Verbosef("[+] WARNING: TLS certificate verification disabled\n")
+		}
+	}
+	if *sni != "" {
+		lg.Verbosef("[+] TLS server name: %s\n", *sni)
+	}
+	if *hostHeader != "" {
+		lg.Verbosef("[+] Host header: %s\n", *hostHeader)
+	}
+	if *clientCert != "" {
+		lg.Verbosef("[+] Client certificate: %s\n", *clientCert)
+	}
+	if *caCert != "" {
+		lg.Verbosef("[+] Verifying targets against CA: %s\n", *caCert)
+	}
+
+	if *confirm > 0 {
+		lg.Verbosef("[+] Confirmation runs per suspicious finding: %d\n", *confirm)
+	}
+
+	if state != nil {
+		lg.Verbosef("[+] Recording completed targets in %s\n", *statePath)
+	}
+
+	if *fuzz != "" {
+		lg.Verbosef("[+] Fuzzing %s with up to %d variants\n", *fuzz, *fuzzVariants)
+	}
+
+	if *proxyURL != "" {
+		lg.Verbosef("[+] Using upstream proxy: %s\n", *proxyURL)
+	}
+
+	if *useAI && aiProvider != nil {
+		lg.Verbosef("[+] AI-powered analysis enabled: %s\n", aiProvider.Name())
+	}
+	lg.Verbosef("\n")
+
+	baseOpts := scanner.Options{
+		Insecure:    *insecure,
+		SNI:         *sni,
+		HostHeader:  *hostHeader,
+		ClientCert:  *clientCert,
+		ClientKey:   *clientKey,
+		CACert:      *caCert,
+		Confidence:  *confidence,
+		AIProvider:  aiProvider,
+		ConfirmRuns: *confirm,
+		LogLevel:    level,
+		Color:       color,
+
+		Proxy:        *proxyURL,
+		ProxyAuth:    *proxyAuth,
+		ProxyHeaders: proxyHeaders,
+
+		Watchdog:         *watchdog,
+		PipelineBaseline: *pipelineBaseline,
+		ProbeCount:       *probeCount,
+		Rand:             rng,
+
+		CL0Paths:   cl0Paths,
+		SweepDelay: *sweepDelay,
+		HTTP2:      *http2,
+
+		TimingSamples:   *timingSamples,
+		BaselineSamples: *baselineSamples,
+		Exhaustive:      *exhaustive,
+		ChunkSweep:      *chunkSweep,
+		ChunkExtension:  *chunkExtension,
+		Fuzz:            *fuzz,
+		FuzzVariants:    *fuzzVariants,
+		AISuggest:       *aiSuggest,
+		AIAdaptive:      *aiAdaptive,
+
+		SmuggleMethod:  *smuggleMethod,
+		SmugglePath:    *smugglePath,
+		SmuggleHeaders: smuggleHeaders,
+
+		ConnectTimeout:    *connectTimeout,
+		ReadTimeout:       *readTimeout,
+		FirstByteTimeout:  *firstByteTimeout,
+		BlockStatuses:     blockStatuses,
+		SignalWeights:     weights.Weights,
+		SeverityOverrides: weights.Severity,
+
+		Techniques:            techniques,
+		AutoTechniques:        *auto,
+		ExplainTiming:         *explainTiming,
+		CompareBaselineMethod: *compareMethod,
+		Resolver:              resolver,
+
+		IgnoreHeaders:  splitList(*ignoreHeaders),
+		CompareHeaders: splitList(*compareHeaders),
+
+		StoreResponseBytes:   storeBytes,
+		KeepFindingResponses: *storeFindings,
+		RedactBodies:         *redactBodies,
+		DryRun:               *dryRun,
+		RequestHeaders:       requestHeaders,
+		Limiter:              limiter,
+	}
+	if *step {
+		baseOpts.Step = scanner.PromptStep(os.Stdin, os.Stdout)
+	}
+
+	if *serve != "" {
+		lg.Printf("[+] API server listening on %s (POST /scan, GET /healthz)\n", *serve)
+		log.Fatal(http.ListenAndServe(*serve, apiserver.NewHandler(baseOpts).SetProtected(protected)))
+	}
+
+	// Under -v, keep a live throughput line on stderr when it is a terminal
+	stopStats := func() {}
+	if verbose {
+		if fi, err := os.Stderr.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+			baseOpts.Stats = sender.NewStats()
+			stopStats = baseOpts.Stats.Report(os.Stderr, time.Second)
 		}
+	}
+	defer stopStats()
+
+	// Completed targets, in input order, for the aggregated report
+	var reports []targetReport
+
+	// checkpoint records a finished target in the -state file as soon as
+	// its worker is done with it, so a crash loses at most the targets
+	// still in flight.
+	checkpoint := func(raw string, ts targets.TargetState) {
+		if state == nil {
+			return
+		}
+		if err := state.Record(stateKey(raw), ts); err != nil {
+			log.Printf("[!] %v", err)
+		}
+	}
 
-		// Default port/transport from flags
-		if *port == 443 || *https {
-			return raw, 443, true, nil
+	// Scan targets with a pool of workers. Each target gets its own
+	// scanner and a random source derived from the seed, so a run replays
+	// identically at any concurrency. With more than one worker, a target's
+	// output is buffered and printed, with its findings, in input order.
+	type targetScan struct {
+		host    string
+		port    int
+		useTLS  bool
+		out     io.Writer
+		buf     *lockedBuffer
+		scanner *scanner.Scanner
+		skip    string
+		err     error
+		done    chan struct{}
+	}
+
+	jobs := make([]*targetScan, len(targetList))
+	for i := range jobs {
+		jobs[i] = &targetScan{out: os.Stdout, done: make(chan struct{})}
+		if *concurrency > 1 {
+			jobs[i].buf = &lockedBuffer{}
+			jobs[i].out = jobs[i].buf
 		}
-		return raw, *port, *https, nil
 	}
 
-	if *verbose {
-		fmt.Printf("[+] Confidence threshold: %.1f%%\n", *confidence*100)
-		if *https {
-			fmt.Printf("[+] Using HTTPS/TLS\n")
-			if *insecure {
-				fmt.Printf("[+] WARNING: TLS certificate verification disabled\n")
+	// A failure normally just skips that target; with -fail-fast it stops
+	// the targets not yet started. Targets already running finish and are
+	// reported.
+	abort := make(chan struct{})
+	var abortOnce sync.Once
+	abortRun := func() { abortOnce.Do(func() { close(abort) }) }
+
+	var started int64
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				job := jobs[i]
+				raw := targetList[i]
+
+				select {
+				case <-abort:
+					close(job.done)
+					continue
+				default:
+				}
+
+				baseOpts.Stats.SetQueued(len(targetList) - int(atomic.AddInt64(&started, 1)))
+
+				host, p, useTLS, err := normalize(raw)
+				if err != nil {
+					job.skip = fmt.Sprintf("Skipping target %s: normalization error: %v", raw, err)
+					checkpoint(raw, targets.TargetState{Outcome: targets.OutcomeSkipped, Error: err.Error()})
+					close(job.done)
+					continue
+				}
+				if ep, ok := protected.Match(context.Background(), host, p); ok {
+					job.skip = fmt.Sprintf("Refusing to scan %s: it is the configured %s (%s)", raw, ep.Role, ep)
+					checkpoint(raw, targets.TargetState{Outcome: targets.OutcomeSkipped, Error: "protected endpoint: " + ep.Role})
+					close(job.done)
+					continue
+				}
+				job.host, job.port, job.useTLS = host, p, useTLS
+
+				jobLog := logger.New(job.out, level, color)
+				jobLog.Verbosef("\n============================================================\n")
+				jobLog.Verbosef("Scanning target: %s (port: %d, tls: %t)\n", host, p, useTLS)
+				jobLog.Verbosef("============================================================\n")
+
+				opts := baseOpts
+				opts.UseTLS = useTLS
+				opts.Output = job.out
+				opts.Rand = rand.New(rand.NewSource(*seed + int64(i)))
+
+				job.scanner, job.err = scanner.RunScan(host, p, opts)
+				switch {
+				case job.err != nil:
+					checkpoint(raw, targets.TargetState{Outcome: targets.OutcomeFailed, Error: job.err.Error()})
+				case job.scanner.VulnerableCount() > 0:
+					checkpoint(raw, targets.TargetState{Outcome: targets.OutcomeVulnerable, Findings: job.scanner.VulnerableCount()})
+				default:
+					checkpoint(raw, targets.TargetState{Outcome: targets.OutcomeClean})
+				}
+				if job.err != nil && *failFast {
+					abortRun()
+				}
+				close(job.done)
+			}
+		}()
+	}
+	go func() {
+		defer close(queue)
+		for i := range jobs {
+			select {
+			case queue <- i:
+			case <-abort:
+				for _, job := range jobs[i:] {
+					close(job.done)
+				}
+				return
 			}
 		}
+	}()
 
-		if *useAI && aiProvider != nil {
-			fmt.Printf("[+] AI-powered analysis enabled: %s\n", aiProvider.Name())
+	failed := 0
+	for _, job := range jobs {
+		<-job.done
+		if job.buf != nil {
+			os.Stdout.Write(job.buf.Bytes())
 		}
-		fmt.Println()
-	}
 
-	// Iterate targets sequentially
-	for _, raw := range targetList {
-		host, p, useTLS, err := normalize(raw)
-		if err != nil {
-			log.Printf("[!] Skipping target %s: normalization error: %v", raw, err)
+		if job.skip != "" {
+			log.Printf("[!] %s", job.skip)
+			continue
+		}
+		if job.err != nil {
+			log.Printf("[!] Scan failed for %s: %v", job.host, job.err)
+			failed++
+			continue
+		}
+		if job.scanner == nil {
+			// not started because of -fail-fast
 			continue
 		}
 
-		if *verbose {
-			fmt.Printf("\n============================================================\n")
-			fmt.Printf("Scanning target: %s (port: %d, tls: %t)\n", host, p, useTLS)
-			fmt.Printf("============================================================\n")
+		scheme := "http"
+		if job.useTLS {
+			scheme = "https"
 		}
+		rep := targetReport{
+			url:     fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(job.host, strconv.Itoa(job.port))),
+			scanner: job.scanner,
+		}
+		reports = append(reports, rep)
 
-		// Use temporary variables for this iteration
-		t := host
-		pp := p
-		thttps := useTLS
+		if *outputDir != "" {
+			f, err := utils.CreateReportFile(*outputDir, job.host, job.port, reportExtensions[*outputFormat])
+			if err != nil {
+				log.Printf("[!] Failed to create report file for %s: %v", job.host, err)
+				continue
+			}
+			err = writeReport(f, *outputFormat, []targetReport{rep})
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				log.Printf("[!] Failed to write report file for %s: %v", job.host, err)
+			} else {
+				lg.Verbosef("[+] Report written to %s\n", f.Name())
+			}
+		}
+	}
+	wg.Wait()
 
-		if err := scanner.RunFullScan(t, pp, thttps, *insecure, *confidence, aiProvider); err != nil {
-			log.Fatalf("[!] Scan failed for %s: %v", t, err)
+	if outputFile != nil {
+		err := writeReport(outputFile, *outputFormat, reports)
+		if cerr := outputFile.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			log.Printf("[!] Failed to write %s: %v", *output, err)
+			failed++
+		} else {
+			lg.Infof("[+] Results written to %s (%s)\n", *output, *outputFormat)
 		}
+	} else if *outputFormat != "text" {
+		if err := writeReport(os.Stdout, *outputFormat, reports); err != nil {
+			log.Printf("[!] Failed to write %s output: %v", *outputFormat, err)
+		}
+	}
+
+	if c, ok := aiProvider.(io.Closer); ok {
+		c.Close()
+	}
+
+	if failed > 0 {
+		log.Printf("[!] %d of %d targets failed", failed, len(jobs))
+		stopStats()
+		os.Exit(exitError)
+	}
+
+	vulnerable := 0
+	for _, r := range reports {
+		vulnerable += r.scanner.VulnerableCount()
+	}
+	if vulnerable > 0 && !*exitZero {
+		stopStats()
+		os.Exit(exitSuspicious)
 	}
 }
@@ -1,3 +1,10 @@
 module smuggler
 
 go 1.21
+
+require (
+	golang.org/x/net v0.20.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/text v0.14.0 // indirect
@@ -7,14 +7,26 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
 
+// DefaultOpenAIModel and DefaultOpenAIBaseURL are used unless overridden.
+const (
+	DefaultOpenAIModel   = "gpt-3.5-turbo"
+	DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+)
+
 type AIAnalyzer struct {
-	apiKey string
-	model  string
-	client *http.Client
+	apiKey         string
+	model          string
+	baseURL        string
+	client         *http.Client
+	prompts        *PromptSet
+	maxPromptBytes int
+	jsonRetries    int
+	cache          Cache
 }
 
 type AnalysisResult struct {
@@ -35,17 +47,101 @@ type PayloadSuggestion struct {
 }
 
 func NewAIAnalyzer(apiKey string) *AIAnalyzer {
+	return NewAIAnalyzerWithModel(apiKey, DefaultOpenAIModel)
+}
+
+// NewAIAnalyzerWithModel creates an OpenAI analyzer using model (for
+// example gpt-4o or gpt-4o-mini); an empty model uses DefaultOpenAIModel.
+func NewAIAnalyzerWithModel(apiKey, model string) *AIAnalyzer {
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+
 	return &AIAnalyzer{
-		apiKey: apiKey,
-		model:  "gpt-3.5-turbo",
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		apiKey:         apiKey,
+		model:          model,
+		baseURL:        DefaultOpenAIBaseURL,
+		client:         newHTTPClient(DefaultTimeout),
+		prompts:        DefaultPrompts(),
+		maxPromptBytes: DefaultMaxPromptBytes,
+		jsonRetries:    DefaultJSONRetries,
+		cache:          NewMemoryCache(DefaultCacheEntries, 0),
 	}
 }
 
 func (a *AIAnalyzer) Name() string {
-	return "OpenAI"
+	return fmt.Sprintf("OpenAI (%s)", a.model)
+}
+
+// SetBaseURL points the analyzer at an OpenAI-compatible API, such as an
+// Azure OpenAI deployment or a local vLLM gateway. Requests go to
+// <base>/chat/completions, keeping any query string (e.g. Azure's
+// api-version). An empty URL restores DefaultOpenAIBaseURL.
+func (a *AIAnalyzer) SetBaseURL(base string) {
+	if base == "" {
+		base = DefaultOpenAIBaseURL
+	}
+	a.baseURL = base
+}
+
+// BaseURL returns the API base URL requests are sent to.
+func (a *AIAnalyzer) BaseURL() string {
+	return a.baseURL
+}
+
+// SetPrompts replaces the prompt templates used for every request.
+func (a *AIAnalyzer) SetPrompts(p *PromptSet) error {
+	if p.templates == nil {
+		if err := p.compile(); err != nil {
+			return err
+		}
+	}
+	a.prompts = p
+	return nil
+}
+
+// SetMaxPromptBytes caps the size of each prompt; the response snippets are
+// shortened first. Zero or less removes the cap.
+func (a *AIAnalyzer) SetMaxPromptBytes(n int) {
+	a.maxPromptBytes = n
+}
+
+// SetJSONRetries sets how many times a reply that is not valid JSON is
+// re-requested with a stricter instruction. Negative values mean none.
+func (a *AIAnalyzer) SetJSONRetries(n int) {
+	if n < 0 {
+		n = 0
+	}
+	a.jsonRetries = n
+}
+
+// SetCache replaces the reply cache; nil disables caching.
+func (a *AIAnalyzer) SetCache(c Cache) {
+	a.cache = c
+}
+
+// SetHTTPClient replaces the client used for API calls, for example to
+// route them through a proxy or a custom transport. nil restores the
+// default client.
+func (a *AIAnalyzer) SetHTTPClient(c *http.Client) {
+	if c == nil {
+		c = newHTTPClient(DefaultTimeout)
+	}
+	a.client = c
+}
+
+// SetTimeout bounds each API call, including reading the reply. Zero or
+// less keeps the current timeout.
+func (a *AIAnalyzer) SetTimeout(d time.Duration) {
+	if d > 0 {
+		a.client.Timeout = d
+	}
+}
+
+// Close releases the idle connections kept for reuse between calls.
+func (a *AIAnalyzer) Close() error {
+	a.client.CloseIdleConnections()
+	return nil
 }
 
 // ---------- PUBLIC METHODS ----------
@@ -56,22 +152,13 @@ func (a *AIAnalyzer) AnalyzeResponses(
 	testType string,
 ) (*AnalysisResult, error) {
 
-	prompt := fmt.Sprintf(
-		`Analyze if these HTTP responses indicate request smuggling:
-
-Test: %s
-Baseline Status: %v, Body: %v bytes
-Test Status: %v, Body: %v bytes
-
-Respond with valid JSON only:
-{"is_vulnerable": bool, "techniques": [], "confidence": 0.0, "reasoning": "", "suspicious_signals": [], "recommendations": []}`,
-		testType,
-		baseline["status"], baseline["body_len"],
-		testResponse["status"], testResponse["body_len"],
-	)
+	prompt, err := a.prompts.analyzePrompt(a.maxPromptBytes, testType, baseline, testResponse)
+	if err != nil {
+		return nil, err
+	}
 
 	result := &AnalysisResult{}
-	err := a.callOpenAIJSON(prompt, result)
+	err = a.callOpenAIJSON(ctx, prompt, result)
 	return result, err
 }
 
@@ -81,14 +168,16 @@ func (a *AIAnalyzer) SuggestPayloads(
 	previousResults map[string]interface{},
 ) ([]*PayloadSuggestion, error) {
 
-	prompt := fmt.Sprintf(
-		`Given target %v and previous results %v, suggest the top 2 HTTP Request Smuggling attack payloads.
-Respond with JSON array only.`,
-		targetInfo, previousResults,
-	)
+	prompt, err := a.prompts.render("suggest", PromptData{
+		Target:          targetInfo,
+		PreviousResults: previousResults,
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	var out []*PayloadSuggestion
-	err := a.callOpenAIJSON(prompt, &out)
+	err = a.callOpenAIJSON(ctx, prompt, &out)
 	return out, err
 }
 
@@ -98,12 +187,14 @@ func (a *AIAnalyzer) GenerateReport(
 	allResponses []map[string]interface{},
 ) (string, error) {
 
-	prompt := fmt.Sprintf(
-		`Create a brief security assessment for HTTP Request Smuggling scan: %v`,
-		scanResults,
-	)
+	prompt, err := a.prompts.render("report", PromptData{
+		ScanResults: scanResults,
+	})
+	if err != nil {
+		return "", err
+	}
 
-	return a.callOpenAIString(prompt)
+	return a.callOpenAIString(ctx, prompt)
 }
 
 func (a *AIAnalyzer) IdentifyTechnique(
@@ -111,11 +202,12 @@ func (a *AIAnalyzer) IdentifyTechnique(
 	allTestResults map[string]map[string]interface{},
 ) (string, float64, error) {
 
-	prompt := fmt.Sprintf(
-		`Based on test results %v, identify the most likely smuggling technique.
-Respond with JSON only: {"most_likely_technique":"CL.TE","confidence":0.85}`,
-		allTestResults,
-	)
+	prompt, err := a.prompts.render("identify", PromptData{
+		Results: allTestResults,
+	})
+	if err != nil {
+		return "", 0, err
+	}
 
 	type Result struct {
 		Technique  string  `json:"most_likely_technique"`
@@ -123,7 +215,7 @@ Respond with JSON only: {"most_likely_technique":"CL.TE","confidence":0.85}`,
 	}
 
 	r := &Result{}
-	err := a.callOpenAIJSON(prompt, r)
+	err = a.callOpenAIJSON(ctx, prompt, r)
 	if err != nil {
 		return "", 0, err
 	}
@@ -133,29 +225,24 @@ Respond with JSON only: {"most_likely_technique":"CL.TE","confidence":0.85}`,
 
 // ---------- INTERNAL CORE ----------
 
-func (a *AIAnalyzer) callOpenAIJSON(prompt string, dest interface{}) error {
+func (a *AIAnalyzer) callOpenAIJSON(ctx context.Context, prompt string, dest interface{}) error {
 
-	raw, err := a.callOpenAI(prompt, true)
-	if err != nil {
-		return err
-	}
-
-	raw = cleanJSON(raw)
-
-	if err := json.Unmarshal([]byte(raw), dest); err != nil {
-		return fmt.Errorf("failed to parse AI JSON: %w", err)
-	}
-
-	return nil
+	return callJSONWithRetry(ctx, a.Name(), prompt, a.jsonRetries, a.cache, dest,
+		func(ctx context.Context, prompt string) (string, error) {
+			return a.callOpenAI(ctx, prompt, true)
+		})
 }
 
-func (a *AIAnalyzer) callOpenAIString(prompt string) (string, error) {
-	return a.callOpenAI(prompt, false)
+func (a *AIAnalyzer) callOpenAIString(ctx context.Context, prompt string) (string, error) {
+	return cachedCall(a.cache, cacheKey(a.Name(), "text", prompt), func() (string, error) {
+		return a.callOpenAI(ctx, prompt, false)
+	})
 }
 
-func (a *AIAnalyzer) callOpenAI(prompt string, strictJSON bool) (string, error) {
+func (a *AIAnalyzer) callOpenAI(ctx context.Context, prompt string, strictJSON bool) (string, error) {
 
-	if a.apiKey == "" {
+	// self-hosted gateways often need no key; api.openai.com always does
+	if a.apiKey == "" && a.baseURL == DefaultOpenAIBaseURL {
 		return "", fmt.Errorf("missing API key")
 	}
 
@@ -179,9 +266,16 @@ func (a *AIAnalyzer) callOpenAI(prompt string, strictJSON bool) (string, error)
 		return "", err
 	}
 
-	req, err := http.NewRequest(
+	endpoint, err := url.Parse(a.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid OpenAI base URL %q: %w", a.baseURL, err)
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/chat/completions"
+
+	req, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
-		"https://api.openai.com/v1/chat/completions",
+		endpoint.String(),
 		bytes.NewReader(data),
 	)
 	if err != nil {
@@ -189,7 +283,11 @@ func (a *AIAnalyzer) callOpenAI(prompt string, strictJSON bool) (string, error)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	if strings.HasSuffix(endpoint.Hostname(), ".openai.azure.com") {
+		req.Header.Set("api-key", a.apiKey)
+	} else if a.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	}
 
 	resp, err := a.client.Do(req)
 	if err != nil {
@@ -221,16 +319,3 @@ func (a *AIAnalyzer) callOpenAI(prompt string, strictJSON bool) (string, error)
 
 	return apiResp.Choices[0].Message.Content, nil
 }
-
-// ---------- HELPERS ----------
-
-// removes ```json wrappers
-func cleanJSON(in string) string {
-	in = strings.TrimSpace(in)
-
-	in = strings.TrimPrefix(in, "```json")
-	in = strings.TrimPrefix(in, "```")
-	in = strings.TrimSuffix(in, "```")
-
-	return strings.TrimSpace(in)
-}
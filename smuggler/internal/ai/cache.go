@@ -0,0 +1,223 @@
+package ai
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache stores model replies so identical prompts are not paid for twice.
+// Implementations must be safe for concurrent use, since one provider is
+// shared by every target of a scan.
+type Cache interface {
+	Get(key string) (string, bool)
+	Set(key, value string)
+}
+
+// CacheSetter is implemented by providers that consult a Cache.
+type CacheSetter interface {
+	SetCache(c Cache)
+}
+
+// DefaultCacheEntries is the capacity of the in-memory cache providers
+// start with.
+const DefaultCacheEntries = 256
+
+// cacheKey hashes the parts into a fixed-size key, so prompts never end up
+// in cache files verbatim.
+func cacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cachedCall returns the reply cached under key, or calls call and caches
+// a non-empty reply. A nil cache always calls.
+func cachedCall(cache Cache, key string, call func() (string, error)) (string, error) {
+	if cache != nil {
+		if v, ok := cache.Get(key); ok {
+			return v, nil
+		}
+	}
+
+	reply, err := call()
+	if err == nil && cache != nil && reply != "" {
+		cache.Set(key, reply)
+	}
+	return reply, err
+}
+
+// ---------- MEMORY ----------
+
+// MemoryCache is a least-recently-used in-memory Cache.
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key    string
+	value  string
+	stored time.Time
+}
+
+// NewMemoryCache keeps at most capacity entries, evicting the least
+// recently used. Entries older than ttl are ignored; a ttl of zero keeps
+// them for the life of the process.
+func NewMemoryCache(capacity int, ttl time.Duration) *MemoryCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &MemoryCache{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *MemoryCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	e := el.Value.(*memoryEntry)
+	if c.ttl > 0 && time.Since(e.stored) > c.ttl {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+func (c *MemoryCache) Set(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value = &memoryEntry{key: key, value: value, stored: time.Now()}
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&memoryEntry{key: key, value: value, stored: time.Now()})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+// ---------- FILE ----------
+
+// FileCache is a Cache persisted as a JSON file, so replies survive across
+// runs while a scan is being tuned. The whole file is rewritten on every
+// Set; it is meant for hundreds of entries, not millions.
+type FileCache struct {
+	mu      sync.Mutex
+	path    string
+	ttl     time.Duration
+	entries map[string]fileEntry
+}
+
+type fileEntry struct {
+	Value  string    `json:"value"`
+	Stored time.Time `json:"stored"`
+}
+
+// NewFileCache loads the cache at path, creating it on the first Set if it
+// does not exist. Entries older than ttl are dropped; a ttl of zero keeps
+// them indefinitely.
+func NewFileCache(path string, ttl time.Duration) (*FileCache, error) {
+	c := &FileCache{
+		path:    path,
+		ttl:     ttl,
+		entries: make(map[string]fileEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &c.entries); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	for k, e := range c.entries {
+		if c.expired(e) {
+			delete(c.entries, k)
+		}
+	}
+	return c, nil
+}
+
+func (c *FileCache) expired(e fileEntry) bool {
+	return c.ttl > 0 && time.Since(e.Stored) > c.ttl
+}
+
+func (c *FileCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || c.expired(e) {
+		return "", false
+	}
+	return e.Value, true
+}
+
+// Set stores the entry and rewrites the file. A failed write leaves the
+// entry cached in memory for the rest of the run.
+func (c *FileCache) Set(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = fileEntry{Value: value, Stored: time.Now()}
+	c.save()
+}
+
+// save writes the entries to a temporary file and renames it over the
+// cache, so a crash never leaves a truncated file behind.
+func (c *FileCache) save() error {
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
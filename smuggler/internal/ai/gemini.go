@@ -0,0 +1,296 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GeminiAnalyzer is a Provider backed by Google's Generative Language API.
+type GeminiAnalyzer struct {
+	apiKey         string
+	model          string
+	endpoint       string
+	client         *http.Client
+	prompts        *PromptSet
+	maxPromptBytes int
+	jsonRetries    int
+	cache          Cache
+}
+
+func NewGeminiAnalyzer(apiKey, model string) *GeminiAnalyzer {
+	if model == "" {
+		model = "gemini-1.5-flash"
+	}
+
+	return &GeminiAnalyzer{
+		apiKey:         apiKey,
+		model:          model,
+		endpoint:       "https://generativelanguage.googleapis.com/v1beta",
+		client:         newHTTPClient(DefaultTimeout),
+		prompts:        DefaultPrompts(),
+		maxPromptBytes: DefaultMaxPromptBytes,
+		jsonRetries:    DefaultJSONRetries,
+		cache:          NewMemoryCache(DefaultCacheEntries, 0),
+	}
+}
+
+func (g *GeminiAnalyzer) Name() string {
+	return fmt.Sprintf("Gemini (%s)", g.model)
+}
+
+// SetPrompts replaces the prompt templates used for every request.
+func (g *GeminiAnalyzer) SetPrompts(p *PromptSet) error {
+	if p.templates == nil {
+		if err := p.compile(); err != nil {
+			return err
+		}
+	}
+	g.prompts = p
+	return nil
+}
+
+// SetMaxPromptBytes caps the size of each prompt; the response snippets are
+// shortened first. Zero or less removes the cap.
+func (g *GeminiAnalyzer) SetMaxPromptBytes(n int) {
+	g.maxPromptBytes = n
+}
+
+// SetJSONRetries sets how many times a reply that is not valid JSON is
+// re-requested with a stricter instruction. Negative values mean none.
+func (g *GeminiAnalyzer) SetJSONRetries(n int) {
+	if n < 0 {
+		n = 0
+	}
+	g.jsonRetries = n
+}
+
+// SetCache replaces the reply cache; nil disables caching.
+func (g *GeminiAnalyzer) SetCache(c Cache) {
+	g.cache = c
+}
+
+// SetHTTPClient replaces the client used for API calls, for example to
+// route them through a proxy or a custom transport. nil restores the
+// default client.
+func (g *GeminiAnalyzer) SetHTTPClient(c *http.Client) {
+	if c == nil {
+		c = newHTTPClient(DefaultTimeout)
+	}
+	g.client = c
+}
+
+// SetTimeout bounds each API call, including reading the reply. Zero or
+// less keeps the current timeout.
+func (g *GeminiAnalyzer) SetTimeout(d time.Duration) {
+	if d > 0 {
+		g.client.Timeout = d
+	}
+}
+
+// Close releases the idle connections kept for reuse between calls.
+func (g *GeminiAnalyzer) Close() error {
+	g.client.CloseIdleConnections()
+	return nil
+}
+
+// ---------- PUBLIC ----------
+
+func (g *GeminiAnalyzer) AnalyzeResponses(
+	ctx context.Context,
+	baseline, testResponse map[string]interface{},
+	testType string,
+) (*AnalysisResult, error) {
+
+	prompt, err := g.prompts.analyzePrompt(g.maxPromptBytes, testType, baseline, testResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AnalysisResult{}
+	err = g.callGeminiJSON(ctx, prompt, result)
+	return result, err
+}
+
+func (g *GeminiAnalyzer) SuggestPayloads(
+	ctx context.Context,
+	targetInfo map[string]string,
+	previousResults map[string]interface{},
+) ([]*PayloadSuggestion, error) {
+
+	prompt, err := g.prompts.render("suggest", PromptData{
+		Target:          targetInfo,
+		PreviousResults: previousResults,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*PayloadSuggestion
+	err = g.callGeminiJSON(ctx, prompt, &out)
+	return out, err
+}
+
+func (g *GeminiAnalyzer) GenerateReport(
+	ctx context.Context,
+	scanResults map[string]interface{},
+	allResponses []map[string]interface{},
+) (string, error) {
+
+	prompt, err := g.prompts.render("report", PromptData{
+		ScanResults: scanResults,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return cachedCall(g.cache, cacheKey(g.Name(), "text", prompt), func() (string, error) {
+		return g.callGemini(ctx, prompt, false)
+	})
+}
+
+func (g *GeminiAnalyzer) IdentifyTechnique(
+	ctx context.Context,
+	allTestResults map[string]map[string]interface{},
+) (string, float64, error) {
+
+	prompt, err := g.prompts.render("identify", PromptData{
+		Results: allTestResults,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	type Result struct {
+		Technique  string  `json:"most_likely_technique"`
+		Confidence float64 `json:"confidence"`
+	}
+
+	r := &Result{}
+	err = g.callGeminiJSON(ctx, prompt, r)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return r.Technique, r.Confidence, nil
+}
+
+// ---------- CORE ----------
+
+func (g *GeminiAnalyzer) callGeminiJSON(ctx context.Context, prompt string, dest interface{}) error {
+
+	return callJSONWithRetry(ctx, g.Name(), prompt, g.jsonRetries, g.cache, dest,
+		func(ctx context.Context, prompt string) (string, error) {
+			return g.callGemini(ctx, prompt, true)
+		})
+}
+
+// callGemini sends prompt as a single user turn and returns the text of
+// the first candidate. strictJSON asks the API for a JSON response.
+func (g *GeminiAnalyzer) callGemini(ctx context.Context, prompt string, strictJSON bool) (string, error) {
+
+	if g.apiKey == "" {
+		return "", fmt.Errorf("missing API key")
+	}
+
+	systemMsg := "You are a security analyst."
+	generationConfig := map[string]interface{}{
+		"temperature":     0.3,
+		"maxOutputTokens": 700,
+	}
+	if strictJSON {
+		systemMsg = "You are a security analyst. Respond with valid JSON only."
+		generationConfig["responseMimeType"] = "application/json"
+	}
+
+	payload := map[string]interface{}{
+		"systemInstruction": map[string]interface{}{
+			"parts": []map[string]string{{"text": systemMsg}},
+		},
+		"contents": []map[string]interface{}{
+			{
+				"role":  "user",
+				"parts": []map[string]string{{"text": prompt}},
+			},
+		},
+		"generationConfig": generationConfig,
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", g.endpoint, url.PathEscape(g.model))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", g.apiKey)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Gemini request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	var apiResp struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		PromptFeedback struct {
+			BlockReason string `json:"blockReason"`
+		} `json:"promptFeedback"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("Gemini API error %d: %s", resp.StatusCode, string(body))
+		}
+		return "", fmt.Errorf("failed to parse Gemini response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		msg := apiResp.Error.Message
+		if msg == "" {
+			msg = string(body)
+		}
+		return "", fmt.Errorf("Gemini API error %d: %s", resp.StatusCode, msg)
+	}
+
+	if apiResp.PromptFeedback.BlockReason != "" {
+		return "", fmt.Errorf("Gemini blocked the prompt: %s", apiResp.PromptFeedback.BlockReason)
+	}
+
+	if len(apiResp.Candidates) == 0 {
+		return "", fmt.Errorf("no AI response")
+	}
+
+	var text strings.Builder
+	for _, part := range apiResp.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("empty Gemini response (finish reason %s)", apiResp.Candidates[0].FinishReason)
+	}
+
+	return text.String(), nil
+}
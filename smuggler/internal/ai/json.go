@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// parseJSONReply extracts the first JSON value from a model reply, cleans
+// it up and decodes it into dest. Models often wrap JSON in markdown
+// fences or prose, or add comments and trailing commas; every provider
+// goes through here so they all tolerate the same slips. The cleaned text
+// is returned for error messages.
+func parseJSONReply(reply string, dest interface{}) (string, error) {
+	raw := cleanupJSON(extractJSON(reply))
+	return raw, json.Unmarshal([]byte(raw), dest)
+}
+
+// extracts first balanced JSON block
+func extractJSON(input string) string {
+
+	input = strings.TrimSpace(input)
+	input = strings.TrimPrefix(input, "```json")
+	input = strings.TrimPrefix(input, "```")
+	input = strings.TrimSuffix(input, "```")
+
+	start := strings.IndexAny(input, "{[")
+	if start == -1 {
+		return input
+	}
+
+	depth := 0
+
+	for i := start; i < len(input); i++ {
+		switch input[i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return input[start : i+1]
+			}
+		}
+	}
+
+	return input[start:]
+}
+
+// removes comments and trailing commas outside strings
+func cleanupJSON(s string) string {
+
+	var out []byte
+	inString := false
+	escape := false
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+
+		if ch == '"' && !escape {
+			inString = !inString
+		}
+
+		if ch == '\\' && inString {
+			escape = !escape
+		} else {
+			escape = false
+		}
+
+		if !inString && ch == '/' && i+1 < len(s) && s[i+1] == '/' {
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+			i--
+			continue
+		}
+
+		if !inString && ch == '/' && i+1 < len(s) && s[i+1] == '*' {
+			end := strings.Index(s[i+2:], "*/")
+			if end == -1 {
+				break
+			}
+			i += end + 3
+			continue
+		}
+
+		if !inString && ch == ',' {
+			j := i + 1
+			for j < len(s) &&
+				(s[j] == ' ' || s[j] == '\n' ||
+					s[j] == '\t' || s[j] == '\r') {
+				j++
+			}
+			if j < len(s) &&
+				(s[j] == '}' || s[j] == ']') {
+				continue
+			}
+		}
+
+		out = append(out, ch)
+	}
+
+	return string(out)
+}
@@ -0,0 +1,30 @@
+package ai
+
+import "testing"
+
+func TestParseJSONReplyFenced(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+	}{
+		{"json fence", "```json\n{\"technique\": \"CL.TE\", \"confidence\": 0.8}\n```"},
+		{"bare fence", "```\n{\"technique\": \"CL.TE\", \"confidence\": 0.8}\n```"},
+		{"fence after prose", "Here is the analysis:\n```json\n{\"technique\": \"CL.TE\", \"confidence\": 0.8}\n```\nLet me know."},
+		{"trailing comma and comment", "```json\n{\n  \"technique\": \"CL.TE\", // most likely\n  \"confidence\": 0.8,\n}\n```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dest struct {
+				Technique  string  `json:"technique"`
+				Confidence float64 `json:"confidence"`
+			}
+			raw, err := parseJSONReply(tt.reply, &dest)
+			if err != nil {
+				t.Fatalf("parse failed: %v (cleaned: %q)", err, raw)
+			}
+			if dest.Technique != "CL.TE" || dest.Confidence != 0.8 {
+				t.Errorf("got %+v, want CL.TE at 0.8", dest)
+			}
+		})
+	}
+}
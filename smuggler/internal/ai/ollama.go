@@ -7,14 +7,17 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 )
 
 type OllamaAnalyzer struct {
-	endpoint string
-	model    string
-	client   *http.Client
+	endpoint       string
+	model          string
+	client         *http.Client
+	prompts        *PromptSet
+	maxPromptBytes int
+	jsonRetries    int
+	cache          Cache
 }
 
 func NewOllamaAnalyzer(endpoint, model string) *OllamaAnalyzer {
@@ -26,11 +29,13 @@ func NewOllamaAnalyzer(endpoint, model string) *OllamaAnalyzer {
 	}
 
 	return &OllamaAnalyzer{
-		endpoint: endpoint,
-		model:    model,
-		client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		endpoint:       endpoint,
+		model:          model,
+		client:         newHTTPClient(DefaultOllamaTimeout),
+		prompts:        DefaultPrompts(),
+		maxPromptBytes: DefaultMaxPromptBytes,
+		jsonRetries:    DefaultJSONRetries,
+		cache:          NewMemoryCache(DefaultCacheEntries, 0),
 	}
 }
 
@@ -38,6 +43,61 @@ func (o *OllamaAnalyzer) Name() string {
 	return fmt.Sprintf("Ollama (%s)", o.model)
 }
 
+// SetPrompts replaces the prompt templates used for every request.
+func (o *OllamaAnalyzer) SetPrompts(p *PromptSet) error {
+	if p.templates == nil {
+		if err := p.compile(); err != nil {
+			return err
+		}
+	}
+	o.prompts = p
+	return nil
+}
+
+// SetMaxPromptBytes caps the size of each prompt; the response snippets are
+// shortened first. Zero or less removes the cap.
+func (o *OllamaAnalyzer) SetMaxPromptBytes(n int) {
+	o.maxPromptBytes = n
+}
+
+// SetJSONRetries sets how many times a reply that is not valid JSON is
+// re-requested with a stricter instruction. Negative values mean none.
+func (o *OllamaAnalyzer) SetJSONRetries(n int) {
+	if n < 0 {
+		n = 0
+	}
+	o.jsonRetries = n
+}
+
+// SetCache replaces the reply cache; nil disables caching.
+func (o *OllamaAnalyzer) SetCache(c Cache) {
+	o.cache = c
+}
+
+// SetHTTPClient replaces the client used for Ollama calls, for example to
+// route them through a proxy or a custom transport. nil restores the
+// default client.
+func (o *OllamaAnalyzer) SetHTTPClient(c *http.Client) {
+	if c == nil {
+		c = newHTTPClient(DefaultOllamaTimeout)
+	}
+	o.client = c
+}
+
+// SetTimeout bounds each Ollama call, including reading the reply. Zero or
+// less keeps the current timeout.
+func (o *OllamaAnalyzer) SetTimeout(d time.Duration) {
+	if d > 0 {
+		o.client.Timeout = d
+	}
+}
+
+// Close releases the idle connections kept for reuse between calls.
+func (o *OllamaAnalyzer) Close() error {
+	o.client.CloseIdleConnections()
+	return nil
+}
+
 // ---------- PUBLIC ----------
 
 func (o *OllamaAnalyzer) AnalyzeResponses(
@@ -46,19 +106,13 @@ func (o *OllamaAnalyzer) AnalyzeResponses(
 	testType string,
 ) (*AnalysisResult, error) {
 
-	prompt := fmt.Sprintf(`You are a security expert analyzing HTTP responses for request smuggling vulnerabilities.
-
-Test Type: %s
-Baseline: Status=%v, Body=%v bytes
-Test Response: Status=%v, Body=%v bytes
-
-Output ONLY valid JSON.`,
-		testType,
-		baseline["status"], baseline["body_len"],
-		testResponse["status"], testResponse["body_len"])
+	prompt, err := o.prompts.analyzePrompt(o.maxPromptBytes, testType, baseline, testResponse)
+	if err != nil {
+		return nil, err
+	}
 
 	result := &AnalysisResult{}
-	err := o.callOllamaJSON(prompt, result)
+	err = o.callOllamaJSON(ctx, prompt, result)
 	return result, err
 }
 
@@ -68,16 +122,16 @@ func (o *OllamaAnalyzer) SuggestPayloads(
 	previousResults map[string]interface{},
 ) ([]*PayloadSuggestion, error) {
 
-	prompt := fmt.Sprintf(
-		`Suggest HTTP Request Smuggling payloads.
-Target: %v
-Previous Results: %v
-Output JSON array only.`,
-		targetInfo, previousResults,
-	)
+	prompt, err := o.prompts.render("suggest", PromptData{
+		Target:          targetInfo,
+		PreviousResults: previousResults,
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	var out []*PayloadSuggestion
-	err := o.callOllamaJSON(prompt, &out)
+	err = o.callOllamaJSON(ctx, prompt, &out)
 	return out, err
 }
 
@@ -87,12 +141,14 @@ func (o *OllamaAnalyzer) GenerateReport(
 	allResponses []map[string]interface{},
 ) (string, error) {
 
-	prompt := fmt.Sprintf(
-		`Create a brief security assessment for HTTP Request Smuggling scan: %v`,
-		scanResults,
-	)
+	prompt, err := o.prompts.render("report", PromptData{
+		ScanResults: scanResults,
+	})
+	if err != nil {
+		return "", err
+	}
 
-	return o.callOllamaString(prompt)
+	return o.callOllamaString(ctx, prompt)
 }
 
 func (o *OllamaAnalyzer) IdentifyTechnique(
@@ -100,12 +156,12 @@ func (o *OllamaAnalyzer) IdentifyTechnique(
 	allTestResults map[string]map[string]interface{},
 ) (string, float64, error) {
 
-	prompt := fmt.Sprintf(
-		`Identify most likely request smuggling technique.
-Results: %v
-Return JSON only: {"most_likely_technique":"CL.TE","confidence":0.85}`,
-		allTestResults,
-	)
+	prompt, err := o.prompts.render("identify", PromptData{
+		Results: allTestResults,
+	})
+	if err != nil {
+		return "", 0, err
+	}
 
 	type Result struct {
 		Technique  string  `json:"most_likely_technique"`
@@ -113,7 +169,7 @@ Return JSON only: {"most_likely_technique":"CL.TE","confidence":0.85}`,
 	}
 
 	r := &Result{}
-	err := o.callOllamaJSON(prompt, r)
+	err = o.callOllamaJSON(ctx, prompt, r)
 	if err != nil {
 		return "", 0, err
 	}
@@ -123,28 +179,18 @@ Return JSON only: {"most_likely_technique":"CL.TE","confidence":0.85}`,
 
 // ---------- CORE ----------
 
-func (o *OllamaAnalyzer) callOllamaJSON(prompt string, dest interface{}) error {
+func (o *OllamaAnalyzer) callOllamaJSON(ctx context.Context, prompt string, dest interface{}) error {
 
-	raw, err := o.callOllama(prompt)
-	if err != nil {
-		return err
-	}
-
-	raw = extractJSON(raw)
-	raw = cleanupJSON(raw)
-
-	if err := json.Unmarshal([]byte(raw), dest); err != nil {
-		return fmt.Errorf("failed to parse JSON from Ollama: %w\nResponse: %s", err, raw)
-	}
-
-	return nil
+	return callJSONWithRetry(ctx, o.Name(), prompt, o.jsonRetries, o.cache, dest, o.callOllama)
 }
 
-func (o *OllamaAnalyzer) callOllamaString(prompt string) (string, error) {
-	return o.callOllama(prompt)
+func (o *OllamaAnalyzer) callOllamaString(ctx context.Context, prompt string) (string, error) {
+	return cachedCall(o.cache, cacheKey(o.Name(), "text", prompt), func() (string, error) {
+		return o.callOllama(ctx, prompt)
+	})
 }
 
-func (o *OllamaAnalyzer) callOllama(prompt string) (string, error) {
+func (o *OllamaAnalyzer) callOllama(ctx context.Context, prompt string) (string, error) {
 
 	payload := map[string]interface{}{
 		"model":  o.model,
@@ -156,7 +202,10 @@ func (o *OllamaAnalyzer) callOllama(prompt string) (string, error) {
 
 	url := fmt.Sprintf("%s/api/generate", o.endpoint)
 
-	req, _ := http.NewRequest("POST", url, bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := o.client.Do(req)
@@ -187,74 +236,3 @@ func (o *OllamaAnalyzer) callOllama(prompt string) (string, error) {
 
 	return apiResp.Response, nil
 }
-
-// ---------- HELPERS ----------
-
-// extracts first balanced JSON block
-func extractJSON(input string) string {
-
-	input = strings.TrimSpace(input)
-	input = strings.TrimPrefix(input, "```json")
-	input = strings.TrimPrefix(input, "```")
-	input = strings.TrimSuffix(input, "```")
-
-	start := strings.IndexAny(input, "{[")
-	if start == -1 {
-		return input
-	}
-
-	depth := 0
-
-	for i := start; i < len(input); i++ {
-		switch input[i] {
-		case '{', '[':
-			depth++
-		case '}', ']':
-			depth--
-			if depth == 0 {
-				return input[start : i+1]
-			}
-		}
-	}
-
-	return input[start:]
-}
-
-// removes trailing commas safely
-func cleanupJSON(s string) string {
-
-	var out []byte
-	inString := false
-	escape := false
-
-	for i := 0; i < len(s); i++ {
-		ch := s[i]
-
-		if ch == '"' && !escape {
-			inString = !inString
-		}
-
-		if ch == '\\' && inString {
-			escape = !escape
-		} else {
-			escape = false
-		}
-
-		if !inString && ch == ',' {
-			j := i + 1
-			for j < len(s) &&
-				(s[j] == ' ' || s[j] == '\n' ||
-					s[j] == '\t' || s[j] == '\r') {
-				j++
-			}
-			if j < len(s) &&
-				(s[j] == '}' || s[j] == ']') {
-				continue
-			}
-		}
-
-		out = append(out, ch)
-	}
-
-	return string(out)
-}
@@ -0,0 +1,206 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// PromptData is the value every prompt template is rendered with. Each
+// prompt uses only the fields relevant to it; the rest are zero.
+type PromptData struct {
+	// AnalyzeResponses; headers are "Name: value" lines and bodies are
+	// leading snippets.
+	TestType        string
+	BaselineStatus  interface{}
+	BaselineBodyLen interface{}
+	BaselineHeaders interface{}
+	BaselineBody    interface{}
+	TestStatus      interface{}
+	TestBodyLen     interface{}
+	TestHeaders     interface{}
+	TestBody        interface{}
+
+	// SuggestPayloads
+	Target          map[string]string
+	PreviousResults map[string]interface{}
+
+	// GenerateReport
+	ScanResults map[string]interface{}
+
+	// IdentifyTechnique
+	Results map[string]map[string]interface{}
+}
+
+// PromptSet holds the text/template sources of the prompts sent to AI
+// providers. All providers render the same set, so tuning a prompt changes
+// every backend consistently.
+type PromptSet struct {
+	Analyze  string `json:"analyze,omitempty"`
+	Suggest  string `json:"suggest,omitempty"`
+	Report   string `json:"report,omitempty"`
+	Identify string `json:"identify,omitempty"`
+
+	templates map[string]*template.Template
+}
+
+// DefaultPrompts returns the built-in prompt set.
+func DefaultPrompts() *PromptSet {
+	p := &PromptSet{
+		Analyze: `Analyze if these HTTP responses indicate request smuggling:
+
+Test: {{.TestType}}
+Baseline Status: {{.BaselineStatus}}, Body: {{.BaselineBodyLen}} bytes
+{{- with .BaselineHeaders}}
+Baseline Headers:
+{{.}}{{end}}
+{{- with .BaselineBody}}
+Baseline Body:
+{{.}}
+{{end}}
+Test Status: {{.TestStatus}}, Body: {{.TestBodyLen}} bytes
+{{- with .TestHeaders}}
+Test Headers:
+{{.}}{{end}}
+{{- with .TestBody}}
+Test Body:
+{{.}}
+{{end}}
+
+Respond with valid JSON only:
+{"is_vulnerable": bool, "techniques": [], "confidence": 0.0, "reasoning": "", "suspicious_signals": [], "recommendations": []}`,
+
+		Suggest: `Given target {{.Target}} and previous results {{.PreviousResults}}, suggest the top 2 HTTP Request Smuggling attack payloads.
+Name each technique as one of: CL.TE, TE.CL, Mixed-TE, TE.TE, CL.CL, Obfuscated-TE, Bare-CR, Chunk-Ext, CL.TE-GPOST, Segmented, Header-Injection, CL.0, H2.CL, H2.TE.
+Respond with JSON array only: [{"technique":"TE.CL","description":"...","payload_strategy":"...","priority":"high","rationale":"..."}]`,
+
+		Report: `Create a brief security assessment for HTTP Request Smuggling scan: {{.ScanResults}}`,
+
+		Identify: `Based on test results {{.Results}}, identify the most likely smuggling technique.
+Respond with JSON only: {"most_likely_technique":"CL.TE","confidence":0.85}`,
+	}
+	if err := p.compile(); err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// DefaultMaxPromptBytes caps rendered prompts unless a provider is
+// configured otherwise (see PromptLimiter).
+const DefaultMaxPromptBytes = 8 * 1024
+
+// analyzePrompt renders the analyze prompt for a baseline/test pair. When
+// the result exceeds maxBytes (if positive), the body snippets and then the
+// header blocks are shortened before the prompt itself is cut, so the
+// response instructions at its end survive whenever possible.
+func (p *PromptSet) analyzePrompt(maxBytes int, testType string, baseline, testResponse map[string]interface{}) (string, error) {
+	data := PromptData{
+		TestType:        testType,
+		BaselineStatus:  baseline["status"],
+		BaselineBodyLen: baseline["body_len"],
+		BaselineHeaders: baseline["headers"],
+		BaselineBody:    baseline["body"],
+		TestStatus:      testResponse["status"],
+		TestBodyLen:     testResponse["body_len"],
+		TestHeaders:     testResponse["headers"],
+		TestBody:        testResponse["body"],
+	}
+
+	prompt, err := p.render("analyze", data)
+	if err != nil || maxBytes <= 0 || len(prompt) <= maxBytes {
+		return prompt, err
+	}
+
+	for _, field := range []*interface{}{&data.BaselineBody, &data.TestBody, &data.BaselineHeaders, &data.TestHeaders} {
+		s, ok := (*field).(string)
+		if !ok {
+			continue
+		}
+		const marker = "\n[... truncated]"
+		keep := len(s) - (len(prompt) - maxBytes) - len(marker)
+		if keep <= 0 {
+			*field = ""
+		} else {
+			*field = s[:keep] + marker
+		}
+		if prompt, err = p.render("analyze", data); err != nil || len(prompt) <= maxBytes {
+			return prompt, err
+		}
+	}
+
+	return prompt[:maxBytes], nil
+}
+
+// LoadPromptSet reads a JSON prompt set from path. Prompts missing from
+// the file keep their defaults. Every template is parsed and test-rendered,
+// so a typo in a placeholder fails here rather than mid-scan.
+func LoadPromptSet(path string) (*PromptSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var custom PromptSet
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	p := DefaultPrompts()
+	if strings.TrimSpace(custom.Analyze) != "" {
+		p.Analyze = custom.Analyze
+	}
+	if strings.TrimSpace(custom.Suggest) != "" {
+		p.Suggest = custom.Suggest
+	}
+	if strings.TrimSpace(custom.Report) != "" {
+		p.Report = custom.Report
+	}
+	if strings.TrimSpace(custom.Identify) != "" {
+		p.Identify = custom.Identify
+	}
+
+	if err := p.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// compile parses every prompt and renders it once with empty data to
+// reject references to fields PromptData does not have.
+func (p *PromptSet) compile() error {
+	sources := map[string]string{
+		"analyze":  p.Analyze,
+		"suggest":  p.Suggest,
+		"report":   p.Report,
+		"identify": p.Identify,
+	}
+
+	p.templates = make(map[string]*template.Template, len(sources))
+	for name, src := range sources {
+		t, err := template.New(name).Option("missingkey=error").Parse(src)
+		if err != nil {
+			return fmt.Errorf("prompt %q: %w", name, err)
+		}
+		if err := t.Execute(&strings.Builder{}, PromptData{}); err != nil {
+			return fmt.Errorf("prompt %q: %w", name, err)
+		}
+		p.templates[name] = t
+	}
+	return nil
+}
+
+// render executes the named prompt with data.
+func (p *PromptSet) render(name string, data PromptData) (string, error) {
+	t, ok := p.templates[name]
+	if !ok {
+		return "", fmt.Errorf("unknown prompt %q", name)
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("render %s prompt: %w", name, err)
+	}
+	return b.String(), nil
+}
@@ -1,9 +1,28 @@
 package ai
 
-import "context"
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout bounds each call to a hosted API (OpenAI, Gemini).
+// DefaultOllamaTimeout is longer because local models can take a while to
+// load and generate.
+const (
+	DefaultTimeout       = 30 * time.Second
+	DefaultOllamaTimeout = 60 * time.Second
+)
+
+// newHTTPClient returns the client a provider keeps for all of its calls,
+// so connections to the API are reused.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout}
+}
 
 // Provider defines a common interface for AI backends
-// (OpenAI, Ollama, future local models, etc.)
+// (OpenAI, Ollama, Gemini, future local models, etc.)
 type Provider interface {
 
 	// AnalyzeResponses analyzes HTTP responses for smuggling patterns.
@@ -37,9 +56,52 @@ type Provider interface {
 	Name() string
 }
 
+// PromptCustomizer is implemented by providers whose prompts can be
+// replaced with a user-supplied PromptSet.
+type PromptCustomizer interface {
+	SetPrompts(p *PromptSet) error
+}
+
+// PromptLimiter is implemented by providers that cap the size of the
+// prompts they send.
+type PromptLimiter interface {
+	SetMaxPromptBytes(n int)
+}
+
+// HTTPClientSetter is implemented by providers that call their API over
+// HTTP and let the client or its timeout be replaced.
+type HTTPClientSetter interface {
+	SetHTTPClient(c *http.Client)
+	SetTimeout(d time.Duration)
+}
+
 // Compile-time interface validation.
 // Ensures implementations always satisfy Provider.
 var (
 	_ Provider = (*AIAnalyzer)(nil)
 	_ Provider = (*OllamaAnalyzer)(nil)
+	_ Provider = (*GeminiAnalyzer)(nil)
+
+	_ PromptCustomizer = (*AIAnalyzer)(nil)
+	_ PromptCustomizer = (*OllamaAnalyzer)(nil)
+	_ PromptCustomizer = (*GeminiAnalyzer)(nil)
+
+	_ PromptLimiter = (*AIAnalyzer)(nil)
+	_ PromptLimiter = (*OllamaAnalyzer)(nil)
+	_ PromptLimiter = (*GeminiAnalyzer)(nil)
+
+	_ Cache = (*MemoryCache)(nil)
+	_ Cache = (*FileCache)(nil)
+
+	_ CacheSetter = (*AIAnalyzer)(nil)
+	_ CacheSetter = (*OllamaAnalyzer)(nil)
+	_ CacheSetter = (*GeminiAnalyzer)(nil)
+
+	_ HTTPClientSetter = (*AIAnalyzer)(nil)
+	_ HTTPClientSetter = (*OllamaAnalyzer)(nil)
+	_ HTTPClientSetter = (*GeminiAnalyzer)(nil)
+
+	_ io.Closer = (*AIAnalyzer)(nil)
+	_ io.Closer = (*OllamaAnalyzer)(nil)
+	_ io.Closer = (*GeminiAnalyzer)(nil)
 )
@@ -0,0 +1,69 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultJSONRetries is how many times a provider re-prompts after a reply
+// that does not parse as JSON.
+const DefaultJSONRetries = 2
+
+// strictJSONReminder is appended to the prompt when re-asking after a reply
+// that did not parse.
+const strictJSONReminder = "\n\nYour previous reply was not valid JSON. Return only minified JSON, no prose, no markdown and no code fences."
+
+// callJSONWithRetry sends prompt through call and decodes the cleaned-up
+// reply into dest. A reply that does not parse is re-requested up to
+// retries more times with strictJSONReminder appended; transport and API
+// errors are returned at once. name identifies the provider (and model)
+// in errors and cache keys. A reply that parses is cached under the
+// original prompt, and a cached reply is used without calling at all.
+func callJSONWithRetry(
+	ctx context.Context,
+	name, prompt string,
+	retries int,
+	cache Cache,
+	dest interface{},
+	call func(ctx context.Context, prompt string) (string, error),
+) error {
+
+	key := cacheKey(name, "json", prompt)
+	if cache != nil {
+		if reply, ok := cache.Get(key); ok {
+			if _, err := parseJSONReply(reply, dest); err == nil {
+				return nil
+			}
+		}
+	}
+
+	var raw string
+	var parseErr error
+
+	attempts := 0
+	for attempts <= retries {
+		attempts++
+
+		p := prompt
+		if attempts > 1 {
+			p += strictJSONReminder
+		}
+
+		reply, err := call(ctx, p)
+		if err != nil {
+			return err
+		}
+
+		if raw, parseErr = parseJSONReply(reply, dest); parseErr == nil {
+			if cache != nil {
+				cache.Set(key, reply)
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return fmt.Errorf("failed to parse JSON from %s after %d attempts: %w\nResponse: %s", name, attempts, parseErr, raw)
+}
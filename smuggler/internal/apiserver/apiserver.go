@@ -0,0 +1,187 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"smuggler/internal/detector"
+	"smuggler/internal/models"
+	"smuggler/internal/scanner"
+	"smuggler/internal/targets"
+)
+
+// ScanRequest is the body of POST /scan.
+type ScanRequest struct {
+	Target  string      `json:"target"`
+	Options ScanOptions `json:"options"`
+}
+
+// ScanOptions are the per-request overrides of the server's base options.
+type ScanOptions struct {
+	Port        int     `json:"port,omitempty"`
+	TLS         bool    `json:"tls,omitempty"`
+	Insecure    bool    `json:"insecure,omitempty"`
+	Confidence  float64 `json:"confidence,omitempty"`
+	ConfirmRuns int     `json:"confirm_runs,omitempty"`
+}
+
+// Event is a single JSON line streamed back while a scan runs.
+type Event struct {
+	Type   string                    `json:"type"` // result, report, error
+	Target string                    `json:"target"`
+	Result *models.ScanResult        `json:"result,omitempty"`
+	Report *detector.DetectionReport `json:"report,omitempty"`
+	Error  string                    `json:"error,omitempty"`
+}
+
+// Handler exposes the scanner over HTTP. POST /scan runs a scan and streams
+// newline-delimited JSON events; GET /healthz reports liveness.
+type Handler struct {
+	base      scanner.Options
+	protected *targets.Protected
+	mux       *http.ServeMux
+}
+
+// NewHandler creates a Handler whose scans start from base (proxy, AI
+// provider, timeouts) with per-request overrides applied on top.
+func NewHandler(base scanner.Options) *Handler {
+	h := &Handler{
+		base: base,
+		mux:  http.NewServeMux(),
+	}
+	h.mux.HandleFunc("/scan", h.handleScan)
+	h.mux.HandleFunc("/healthz", h.handleHealth)
+	return h
+}
+
+// SetProtected refuses scans of the endpoints in p, as the CLI does for
+// its own target list.
+func (h *Handler) SetProtected(p *targets.Protected) *Handler {
+	h.protected = p
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, `{"status":"ok"}`)
+}
+
+func (h *Handler) handleScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Target == "" {
+		http.Error(w, "target is required", http.StatusBadRequest)
+		return
+	}
+
+	// The target may be a URL or host:port, as on the command line; the
+	// port and tls options only apply when it names neither.
+	defaultPort := req.Options.Port
+	if defaultPort == 0 {
+		defaultPort = 80
+		if req.Options.TLS {
+			defaultPort = 443
+		}
+	}
+	if defaultPort < 1 || defaultPort > 65535 {
+		http.Error(w, "port must be between 1 and 65535", http.StatusBadRequest)
+		return
+	}
+	host, port, useTLS, err := targets.Normalize(req.Target, defaultPort, req.Options.TLS)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid target: %v", err), http.StatusBadRequest)
+		return
+	}
+	if ep, ok := h.protected.Match(r.Context(), host, port); ok {
+		http.Error(w, fmt.Sprintf("refusing to scan %s: it is the configured %s (%s)", req.Target, ep.Role, ep), http.StatusForbidden)
+		return
+	}
+
+	opts := h.base
+	opts.UseTLS = useTLS
+	opts.Insecure = req.Options.Insecure
+	if req.Options.Confidence > 0 {
+		opts.Confidence = req.Options.Confidence
+	}
+	if req.Options.ConfirmRuns > 0 {
+		opts.ConfirmRuns = req.Options.ConfirmRuns
+	}
+	// Each scan gets its own random source; a Rand is not safe to share.
+	opts.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	// The scan stops when the client goes away, and its progress goes
+	// nowhere: results reach the client as events.
+	opts.Context = r.Context()
+	opts.Output = io.Discard
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	stream := newEventStream(w)
+	opts.ResultHandler = func(result *models.ScanResult) {
+		stream.send(Event{Type: "result", Target: req.Target, Result: result})
+	}
+
+	s, err := scanner.RunScan(host, port, opts)
+	if err != nil {
+		stream.send(Event{Type: "error", Target: req.Target, Error: err.Error()})
+		return
+	}
+
+	stream.send(Event{Type: "report", Target: req.Target, Report: s.GetReport()})
+}
+
+// eventStream writes JSON events one per line, flushing after each so
+// clients see results as soon as each technique completes.
+type eventStream struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	flusher http.Flusher
+}
+
+func newEventStream(w http.ResponseWriter) *eventStream {
+	f, _ := w.(http.Flusher)
+	return &eventStream{enc: json.NewEncoder(w), flusher: f}
+}
+
+func (s *eventStream) send(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.Result != nil {
+		prepareResult(e.Result)
+	}
+	if err := s.enc.Encode(e); err != nil {
+		return
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+// prepareResult copies error values into their serializable fields.
+func prepareResult(sr *models.ScanResult) {
+	if sr.BaselineResponse != nil && sr.BaselineResponse.Error != nil {
+		sr.BaselineResponse.ErrorString = sr.BaselineResponse.Error.Error()
+	}
+	if sr.TestResponse != nil && sr.TestResponse.Error != nil {
+		sr.TestResponse.ErrorString = sr.TestResponse.Error.Error()
+	}
+}
@@ -0,0 +1,115 @@
+package apiserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"smuggler/internal/detector"
+	"smuggler/internal/scanner"
+	"smuggler/internal/sender"
+	"smuggler/internal/targets"
+)
+
+func TestHandleScanRefusesProtectedTarget(t *testing.T) {
+	protected := targets.NewProtected(sender.NewResolver(0))
+	protected.Add("-proxy", "http://127.0.0.1:8080")
+	h := NewHandler(scanner.Options{}).SetProtected(protected)
+
+	body := `{"target": "127.0.0.1", "options": {"port": 8080}}`
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scan", strings.NewReader(body)))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if !strings.Contains(rec.Body.String(), "-proxy") {
+		t.Errorf("body %q does not name the protected endpoint", rec.Body.String())
+	}
+}
+
+// targetServer answers every request with a fixed 200 and closes the
+// connection, and returns its address.
+func targetServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+				conn.Read(make([]byte, 64*1024))
+				io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nok")
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestHandleScanStreamsEvents(t *testing.T) {
+	addr := targetServer(t)
+	h := NewHandler(scanner.Options{
+		Techniques:  []string{detector.TechCLTE},
+		ReadTimeout: time.Second,
+	})
+
+	// A URL target carries its own port, so options.port is only a default.
+	body := `{"target": "http://` + addr + `/", "options": {"port": 9}}`
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scan", strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+
+	var events []Event
+	lines := bufio.NewScanner(rec.Body)
+	for lines.Scan() {
+		var e Event
+		if err := json.Unmarshal(lines.Bytes(), &e); err != nil {
+			t.Fatalf("line %q is not an event: %v", lines.Text(), err)
+		}
+		events = append(events, e)
+	}
+	if len(events) < 2 {
+		t.Fatalf("got %d events, want results followed by a report", len(events))
+	}
+	for _, e := range events[:len(events)-1] {
+		if e.Type != "result" || e.Result == nil {
+			t.Errorf("event %+v, want a result", e)
+		}
+	}
+	if last := events[len(events)-1]; last.Type != "report" || last.Report == nil {
+		t.Errorf("last event %+v, want the report", last)
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewHandler(scanner.Options{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var body struct{ Status string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Status != "ok" {
+		t.Errorf("body = %q, want {\"status\":\"ok\"}", rec.Body.String())
+	}
+}
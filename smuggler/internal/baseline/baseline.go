@@ -10,32 +10,80 @@ import (
 	"smuggler/internal/sender"
 )
 
+// DefaultVolatileHeaders change on every response and are ignored when
+// comparing headers, so they do not produce detection signals.
+var DefaultVolatileHeaders = []string{
+	"Date",
+	"Age",
+	"Expires",
+	"Set-Cookie",
+	"X-Request-Id",
+	"X-Amzn-RequestId",
+	"X-Amz-Cf-Id",
+	"CF-Ray",
+	"Report-To",
+	"NEL",
+	"ETag",
+	"Last-Modified",
+}
+
 // Manager handles baseline requests and comparisons.
 type Manager struct {
-	sender *sender.RawSender
-	host   string
-	port   int
+	sender         *sender.RawSender
+	target         *models.Target
+	ignoredHeaders map[string]bool
+	headers        map[string]string
+
+	// reference and samples are set by CaptureBaselineN; comparisons
+	// against reference use the sampled timing.
+	reference *models.HTTPResponse
+	samples   *models.BaselineSamples
 }
 
-func NewManager(s *sender.RawSender, host string, port int) *Manager {
-	return &Manager{
-		sender: s,
-		host:   host,
-		port:   port,
+func NewManager(s *sender.RawSender, target *models.Target) *Manager {
+	m := &Manager{
+		sender:         s,
+		target:         target,
+		ignoredHeaders: make(map[string]bool),
 	}
+	m.IgnoreHeaders(DefaultVolatileHeaders...)
+	return m
+}
+
+// SetHeaders sets extra headers (User-Agent, Cookie, Authorization, ...)
+// sent with every baseline request.
+func (m *Manager) SetHeaders(headers map[string]string) *Manager {
+	m.headers = headers
+	return m
+}
+
+// IgnoreHeaders adds headers to the set excluded from header comparison.
+func (m *Manager) IgnoreHeaders(names ...string) *Manager {
+	for _, name := range names {
+		m.ignoredHeaders[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	return m
+}
+
+// CompareHeaders removes headers from the ignore set, so changes to them
+// are reported even if they are volatile by default.
+func (m *Manager) CompareHeaders(names ...string) *Manager {
+	for _, name := range names {
+		delete(m.ignoredHeaders, strings.ToLower(strings.TrimSpace(name)))
+	}
+	return m
 }
 
 // ---------- Baseline ----------
 
 func (m *Manager) CaptureBaseline() (*models.HTTPResponse, error) {
 
-	gen := payload.NewGenerator(m.host, m.port)
+	gen := payload.NewGenerator(m.target).AddHeaders(m.headers)
 	gen.AddHeader("Connection", "close")
 
 	payloadStr := gen.GenerateBaseline()
-	target := fmt.Sprintf("%s:%d", m.host, m.port)
 
-	resp, err := m.sender.SendRequest(target, payloadStr)
+	resp, err := m.sender.SendRequest(m.target.Addr(), payloadStr)
 	if err != nil {
 		return resp, fmt.Errorf("failed to capture baseline: %w", err)
 	}
@@ -43,6 +91,118 @@ func (m *Manager) CaptureBaseline() (*models.HTTPResponse, error) {
 	return resp, nil
 }
 
+// CaptureBaselineN sends n baseline requests and returns a reference
+// response built from them: the inlier whose timing is the median, with
+// TimingMS set to that median. Outliers are discarded by the interquartile
+// range rule so one slow sample does not skew every later timing
+// comparison. The per-sample values are returned and kept for
+// CompareResponses. Failed samples are skipped; it fails only if all do.
+func (m *Manager) CaptureBaselineN(n int) (*models.HTTPResponse, *models.BaselineSamples, error) {
+	if n < 1 {
+		n = 1
+	}
+
+	var responses []*models.HTTPResponse
+	var lastErr error
+	for i := 0; i < n; i++ {
+		resp, err := m.CaptureBaseline()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		responses = append(responses, resp)
+	}
+	if len(responses) == 0 {
+		return nil, nil, lastErr
+	}
+
+	samples := &models.BaselineSamples{}
+	for _, r := range responses {
+		samples.StatusCodes = append(samples.StatusCodes, r.StatusCode)
+		samples.TimingsMS = append(samples.TimingsMS, r.TimingMS)
+		samples.BodySizes = append(samples.BodySizes, len(r.Body))
+	}
+	samples.Outliers = timingOutliers(samples.TimingsMS)
+
+	var inliers []*models.HTTPResponse
+	var timings []int64
+	var statuses, sizes []int
+	for i, r := range responses {
+		if samples.Outliers[i] {
+			continue
+		}
+		inliers = append(inliers, r)
+		timings = append(timings, r.TimingMS)
+		statuses = append(statuses, r.StatusCode)
+		sizes = append(sizes, len(r.Body))
+	}
+
+	samples.Timing = models.NewTimingStats(timings)
+	samples.MedianStatus = medianInt(statuses)
+	samples.MedianBodySize = medianInt(sizes)
+
+	sort.SliceStable(inliers, func(i, j int) bool { return inliers[i].TimingMS < inliers[j].TimingMS })
+	ref := *inliers[len(inliers)/2]
+	ref.TimingMS = samples.Timing.MedianMS
+
+	m.reference = &ref
+	m.samples = samples
+	return &ref, samples, nil
+}
+
+// timingOutliers flags timings outside 1.5 interquartile ranges of the
+// quartiles. Fewer than four samples have no meaningful quartiles, so none
+// are flagged.
+func timingOutliers(timings []int64) []bool {
+	out := make([]bool, len(timings))
+	if len(timings) < 4 {
+		return out
+	}
+
+	sorted := append([]int64(nil), timings...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	q1 := sorted[len(sorted)/4]
+	q3 := sorted[(3*len(sorted))/4]
+	iqr := q3 - q1
+	low, high := float64(q1)-1.5*float64(iqr), float64(q3)+1.5*float64(iqr)
+
+	for i, t := range timings {
+		out[i] = float64(t) < low || float64(t) > high
+	}
+	return out
+}
+
+// medianInt returns the median of values, or 0 for none.
+func medianInt(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// CaptureMethodBaseline captures a benign baseline sent with method, so
+// responses to different methods can be compared. Methods other than GET
+// and HEAD carry an empty body.
+func (m *Manager) CaptureMethodBaseline(method string) (*models.HTTPResponse, error) {
+	gen := payload.NewGenerator(m.target).AddHeaders(m.headers)
+	gen.SetMethod(method)
+	if method != "GET" && method != "HEAD" {
+		gen.AddHeader("Content-Type", "application/x-www-form-urlencoded")
+		gen.AddHeader("Content-Length", "0")
+	}
+
+	payloadStr := gen.GenerateBaseline()
+
+	resp, err := m.sender.SendRequest(m.target.Addr(), payloadStr)
+	if err != nil {
+		return resp, fmt.Errorf("failed to capture %s baseline: %w", method, err)
+	}
+
+	return resp, nil
+}
+
 // ---------- Comparison ----------
 
 func (m *Manager) CompareResponses(
@@ -64,7 +224,9 @@ func (m *Manager) CompareResponses(
 	}
 
 	// ---------- Status ----------
-	if baseline.StatusCode != test.StatusCode {
+	// A malformed status line has no code to compare; it is reported as
+	// such below rather than as a change to status 0.
+	if baseline.StatusCode != test.StatusCode && !test.MalformedStatus && !baseline.MalformedStatus {
 		comparison.StatusCodeChanged = true
 		comparison.OldStatusCode = baseline.StatusCode
 		comparison.NewStatusCode = test.StatusCode
@@ -76,9 +238,22 @@ func (m *Manager) CompareResponses(
 		)
 	}
 
+	if test.MalformedStatus && !baseline.MalformedStatus && test.StatusLine != "" {
+		comparison.MalformedStatusAppeared = true
+		comparison.NewStatusLine = test.StatusLine
+
+		comparison.Changes = append(
+			comparison.Changes,
+			fmt.Sprintf("Malformed status line: %q", test.StatusLine),
+		)
+	}
+
 	// ---------- Timing ----------
 	timingDiff := test.TimingMS - baseline.TimingMS
 	comparison.TimingDiffMS = timingDiff
+	if baseline == m.reference && m.samples != nil && len(m.samples.Timing.SamplesMS) > 1 {
+		comparison.BaselineTiming = m.samples.Timing
+	}
 
 	if baseline.TimingMS > 0 &&
 		(timingDiff > 100 || timingDiff < -100) {
@@ -107,7 +282,7 @@ func (m *Manager) CompareResponses(
 	}
 
 	// ---------- Headers ----------
-	analyzeHeaderChanges(baseline, test, comparison)
+	m.analyzeHeaderChanges(baseline, test, comparison)
 
 	// ---------- Body ----------
 	if baseline.Body != test.Body {
@@ -143,41 +318,97 @@ func (m *Manager) CompareResponses(
 
 // ---------- Header Analysis ----------
 
-func normalizeHeaderMap(src map[string]string) map[string]string {
-	out := make(map[string]string)
+func normalizeHeaderMap(src map[string][]string, ignored map[string]bool) map[string][]string {
+	out := make(map[string][]string)
 
 	for k, v := range src {
-		out[strings.ToLower(k)] = v
+		k = strings.ToLower(k)
+		if ignored[k] {
+			continue
+		}
+		out[k] = append(out[k], v...)
+	}
+
+	return out
+}
+
+// equalValues reports whether two headers carry the same values in the
+// same order; a header repeated in only one response counts as changed.
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyTimingStats replaces the single-shot timing difference in
+// comparison with the difference of the sampled medians.
+func (m *Manager) ApplyTimingStats(comparison *models.BaselineComparison, baseline, test *models.TimingStats) {
+	comparison.BaselineTiming = baseline
+	comparison.TestTiming = test
+	comparison.TimingDiffMS = test.MedianMS - baseline.MedianMS
+
+	if !comparison.TimingSeparated() {
+		comparison.Changes = append(comparison.Changes,
+			fmt.Sprintf("Timing difference within noise (test %s, baseline %s)", test, baseline))
 	}
+}
 
+// filterHeaderOrder drops ignored headers from a header order.
+func filterHeaderOrder(order []string, ignored map[string]bool) []string {
+	out := make([]string, 0, len(order))
+	for _, k := range order {
+		if !ignored[strings.ToLower(k)] {
+			out = append(out, k)
+		}
+	}
 	return out
 }
 
-func analyzeHeaderChanges(
+func (m *Manager) analyzeHeaderChanges(
 	baseline, test *models.HTTPResponse,
 	comparison *models.BaselineComparison,
 ) {
 
-	baseHeaders := normalizeHeaderMap(baseline.Headers)
-	testHeaders := normalizeHeaderMap(test.Headers)
+	baseHeaders := normalizeHeaderMap(baseline.Headers, m.ignoredHeaders)
+	testHeaders := normalizeHeaderMap(test.Headers, m.ignoredHeaders)
 
 	for key, baseVal := range baseHeaders {
 
 		testVal, exists := testHeaders[key]
 
 		if !exists {
-			comparison.HeadersRemoved[key] = baseVal
-		} else if baseVal != testVal {
-			comparison.HeadersModified[key] = testVal
+			comparison.HeadersRemoved[key] = strings.Join(baseVal, ", ")
+		} else if !equalValues(baseVal, testVal) {
+			comparison.HeadersModified[key] = strings.Join(testVal, ", ")
 		}
 	}
 
 	for key, testVal := range testHeaders {
 		if _, exists := baseHeaders[key]; !exists {
-			comparison.HeadersAdded[key] = testVal
+			comparison.HeadersAdded[key] = strings.Join(testVal, ", ")
 		}
 	}
 
+	if headerOrderChanged(
+		filterHeaderOrder(baseline.HeaderOrder, m.ignoredHeaders),
+		filterHeaderOrder(test.HeaderOrder, m.ignoredHeaders),
+	) {
+		comparison.HeaderOrderChanged = true
+		comparison.Changes = append(comparison.Changes, "Header order changed")
+	}
+
+	_, serverModified := comparison.HeadersModified["server"]
+	if comparison.HeaderOrderChanged || serverModified {
+		comparison.BackendChanged = true
+		comparison.Changes = append(comparison.Changes, "Backend fingerprint changed")
+	}
+
 	if len(comparison.HeadersAdded) > 0 {
 		comparison.Changes = append(
 			comparison.Changes,
@@ -203,6 +434,41 @@ func analyzeHeaderChanges(
 	}
 }
 
+// headerOrderChanged reports whether the headers common to both orders
+// appear in a different relative sequence.
+func headerOrderChanged(baseOrder, testOrder []string) bool {
+	inBase := make(map[string]bool)
+	for _, k := range baseOrder {
+		inBase[strings.ToLower(k)] = true
+	}
+	inTest := make(map[string]bool)
+	for _, k := range testOrder {
+		inTest[strings.ToLower(k)] = true
+	}
+
+	common := func(order []string, other map[string]bool) []string {
+		seen := make(map[string]bool)
+		out := make([]string, 0, len(order))
+		for _, k := range order {
+			k = strings.ToLower(k)
+			if other[k] && !seen[k] {
+				seen[k] = true
+				out = append(out, k)
+			}
+		}
+		return out
+	}
+
+	a := common(baseOrder, inTest)
+	b := common(testOrder, inBase)
+	for i := range a {
+		if a[i] != b[i] {
+			return true
+		}
+	}
+	return false
+}
+
 func getHeaderKeys(headers map[string]string) []string {
 	keys := make([]string, 0, len(headers))
 	for k := range headers {
@@ -233,7 +499,7 @@ func (m *Manager) IsSuspicious(comparison *models.BaselineComparison) bool {
 		return true
 	}
 
-	if comparison.TimingDiffMS < -50 {
+	if comparison.TimingDiffMS < -50 && comparison.TimingSeparated() {
 		return true
 	}
 
@@ -0,0 +1,89 @@
+package baseline
+
+import (
+	"testing"
+
+	"smuggler/internal/models"
+	"smuggler/internal/sender"
+)
+
+// parse builds a response from raw bytes the way the sender does.
+func parse(t *testing.T, raw string) *models.HTTPResponse {
+	t.Helper()
+	responses := sender.SplitResponses(raw)
+	if len(responses) == 0 {
+		t.Fatalf("no response parsed from %q", raw)
+	}
+	return responses[0]
+}
+
+func TestCompareResponsesMalformedStatus(t *testing.T) {
+	ok := "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"
+
+	tests := []struct {
+		name       string
+		baseline   string
+		test       string
+		appeared   bool
+		statusLine string
+		changed    bool
+	}{
+		{"smuggled method as status", ok, "HTTP/1.1 GPOST\r\nContent-Length: 0\r\n\r\n", true, "HTTP/1.1 GPOST", false},
+		{"request line echoed", ok, "GPOST / HTTP/1.1\r\n\r\n", true, "GPOST / HTTP/1.1", false},
+		{"short code", ok, "HTTP/1.1 20 OK\r\nContent-Length: 0\r\n\r\n", true, "HTTP/1.1 20 OK", false},
+		{"html without status line", ok, "<html>\r\n\r\n", true, "<html>", false},
+		{"numeric status change", ok, "HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n", false, "", true},
+		{"already malformed in baseline", "HTTP/1.1 GPOST\r\n\r\n", "HTTP/1.1 GPOST\r\n\r\n", false, "", false},
+	}
+
+	m := NewManager(nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := m.CompareResponses(parse(t, tt.baseline), parse(t, tt.test))
+			if c.MalformedStatusAppeared != tt.appeared {
+				t.Errorf("MalformedStatusAppeared = %v, want %v", c.MalformedStatusAppeared, tt.appeared)
+			}
+			if c.NewStatusLine != tt.statusLine {
+				t.Errorf("NewStatusLine = %q, want %q", c.NewStatusLine, tt.statusLine)
+			}
+			if c.StatusCodeChanged != tt.changed {
+				t.Errorf("StatusCodeChanged = %v, want %v", c.StatusCodeChanged, tt.changed)
+			}
+		})
+	}
+}
+
+func TestCompareResponsesHeaderOrder(t *testing.T) {
+	base := parse(t, "HTTP/1.1 200 OK\r\nServer: a\r\nX-One: 1\r\nX-Two: 2\r\nContent-Length: 0\r\n\r\n")
+	same := parse(t, "HTTP/1.1 200 OK\r\nServer: a\r\nX-One: 1\r\nX-Two: 2\r\nContent-Length: 0\r\n\r\n")
+	swapped := parse(t, "HTTP/1.1 200 OK\r\nServer: a\r\nX-Two: 2\r\nX-One: 1\r\nContent-Length: 0\r\n\r\n")
+
+	m := NewManager(nil, nil)
+	if c := m.CompareResponses(base, same); c.HeaderOrderChanged {
+		t.Error("HeaderOrderChanged set for identical header order")
+	}
+	c := m.CompareResponses(base, swapped)
+	if !c.HeaderOrderChanged || !c.BackendChanged {
+		t.Errorf("HeaderOrderChanged = %v, BackendChanged = %v; want both set for swapped headers",
+			c.HeaderOrderChanged, c.BackendChanged)
+	}
+}
+
+func TestCompareResponsesIgnoresDate(t *testing.T) {
+	base := parse(t, "HTTP/1.1 200 OK\r\nDate: Mon, 12 Oct 2026 10:00:00 GMT\r\nServer: a\r\nContent-Length: 2\r\n\r\nok")
+	test := parse(t, "HTTP/1.1 200 OK\r\nDate: Mon, 12 Oct 2026 10:00:07 GMT\r\nServer: a\r\nContent-Length: 2\r\n\r\nok")
+
+	m := NewManager(nil, nil)
+	c := m.CompareResponses(base, test)
+	if len(c.Changes) != 0 || len(c.HeadersModified) != 0 || c.BackendChanged {
+		t.Errorf("Date-only difference produced changes %v, modified headers %v", c.Changes, c.HeadersModified)
+	}
+	if m.IsSuspicious(c) {
+		t.Error("Date-only difference reported as suspicious")
+	}
+
+	m.CompareHeaders("date")
+	if c := m.CompareResponses(base, test); c.HeadersModified["date"] == "" {
+		t.Error("Date change not reported after CompareHeaders(\"date\")")
+	}
+}
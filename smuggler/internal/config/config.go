@@ -0,0 +1,177 @@
+// Package config loads scan profiles: YAML or JSON files holding values
+// for the command-line flags, so a combination of techniques, thresholds,
+// headers, timeouts and AI backend can be reused across engagements.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Repeatable is implemented by flags that may be given several times, such
+// as -header. A list in a profile sets them once per element, and their
+// values are dumped as lists.
+type Repeatable interface {
+	flag.Value
+	Values() []string
+}
+
+// Config is a scan profile. Keys are flag names without the leading dash
+// and values are what would follow the flag on the command line, e.g.
+//
+//	techniques: [clte, tecl]
+//	confidence: 0.7
+//	read-timeout: 15s
+//	header:
+//	  - "Cookie: session=abc"
+//
+// A list sets a repeatable flag once per element and is joined with
+// commas for any other flag.
+type Config struct {
+	Path   string
+	Values map[string]interface{}
+}
+
+// Load reads a profile from path. JSON is accepted as well as YAML.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	c := &Config{Path: path, Values: make(map[string]interface{}, len(values))}
+	for key, value := range values {
+		c.Values[strings.TrimLeft(key, "-")] = value
+	}
+	return c, nil
+}
+
+// Apply sets the flags of fs named in the profile, skipping those in
+// explicit (the flags given on the command line, which win). skip lists
+// flags a profile may not set, such as the one naming the profile itself.
+// Unknown flags and values a flag rejects are reported with the profile
+// path.
+func (c *Config) Apply(fs *flag.FlagSet, explicit map[string]bool, skip ...string) error {
+	keys := make([]string, 0, len(c.Values))
+	for key := range c.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, s := range skip {
+			if key == s {
+				return fmt.Errorf("%s: %q cannot be set in a profile", c.Path, key)
+			}
+		}
+
+		f := fs.Lookup(key)
+		if f == nil {
+			return fmt.Errorf("%s: unknown flag %q", c.Path, key)
+		}
+		if explicit[key] {
+			continue
+		}
+
+		values, err := flagValues(c.Values[key])
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", c.Path, key, err)
+		}
+		if _, ok := f.Value.(Repeatable); !ok && len(values) > 1 {
+			values = []string{strings.Join(values, ",")}
+		}
+		for _, v := range values {
+			if err := fs.Set(key, v); err != nil {
+				return fmt.Errorf("%s: %s: %w", c.Path, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// flagValues turns a decoded YAML value into flag arguments.
+func flagValues(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			s, err := scalar(e)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	default:
+		s, err := scalar(v)
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+}
+
+func scalar(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("expected a string, number, boolean or list, got %T", v)
+}
+
+// Effective returns the current value of every flag in fs except those in
+// skip, in the form Load reads: lists for repeatable flags, typed values
+// for the standard ones.
+func Effective(fs *flag.FlagSet, skip ...string) map[string]interface{} {
+	out := make(map[string]interface{})
+	fs.VisitAll(func(f *flag.Flag) {
+		for _, s := range skip {
+			if f.Name == s {
+				return
+			}
+		}
+		switch v := f.Value.(type) {
+		case Repeatable:
+			values := v.Values()
+			if values == nil {
+				values = []string{}
+			}
+			out[f.Name] = values
+		case flag.Getter:
+			if d, ok := v.Get().(time.Duration); ok {
+				out[f.Name] = d.String()
+			} else {
+				out[f.Name] = v.Get()
+			}
+		default:
+			out[f.Name] = f.Value.String()
+		}
+	})
+	return out
+}
+
+// Write dumps values as YAML, keys sorted.
+func Write(w io.Writer, values map[string]interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(values); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -2,6 +2,7 @@ package detector
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"smuggler/internal/models"
@@ -10,6 +11,9 @@ import (
 // Detector analyzes baseline comparisons to identify HTTP request smuggling vulnerabilities.
 type Detector struct {
 	confidenceThreshold float64
+	blockStatuses       map[int]bool
+	weights             SignalWeights
+	severities          SeverityOverrides
 }
 
 func NewDetector() *Detector {
@@ -29,8 +33,65 @@ func (d *Detector) SetConfidenceThreshold(threshold float64) *Detector {
 	return d
 }
 
+// SetBlockStatuses sets the status codes a target uses for generic block
+// pages (e.g. 403, 429). A test answered with one of them is classified
+// as blocked rather than analyzed, since the status change says nothing
+// about how the request was parsed.
+func (d *Detector) SetBlockStatuses(codes []int) *Detector {
+	d.blockStatuses = make(map[int]bool, len(codes))
+	for _, c := range codes {
+		d.blockStatuses[c] = true
+	}
+	return d
+}
+
+// IsBlockStatus reports whether code is a configured block status.
+func (d *Detector) IsBlockStatus(code int) bool {
+	return d.blockStatuses[code]
+}
+
+// BlockedReason describes a result classified as blocked.
+func BlockedReason(code int) string {
+	return fmt.Sprintf("Blocked/Inconclusive: test request was answered with block status %d, so no desync evidence could be gathered", code)
+}
+
 // ---------- Helpers ----------
 
+// Signal is one piece of detection evidence. Code is a stable identifier
+// (e.g. "status-5xx") used for fingerprinting; Weight is what the signal
+// adds to the confidence; Detail is the human-readable description with
+// the measured values.
+type Signal struct {
+	Code   string
+	Weight float64
+	Detail string
+}
+
+// signalCodes returns the distinct signal codes, sorted.
+func signalCodes(signals []Signal) []string {
+	seen := make(map[string]bool)
+	codes := make([]string, 0, len(signals))
+	for _, s := range signals {
+		if !seen[s.Code] {
+			seen[s.Code] = true
+			codes = append(codes, s.Code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// ReasonCode summarizes a finding as its technique family and the sorted
+// signal codes that fired, e.g. "CL.TE:connection-closed+status-5xx". It
+// contains no measured values, so it is stable across runs until the
+// target's behavior materially changes.
+func ReasonCode(technique string, codes []string) string {
+	if len(codes) == 0 {
+		return TechniqueFamily(technique)
+	}
+	return TechniqueFamily(technique) + ":" + strings.Join(codes, "+")
+}
+
 func headerExistsCaseInsensitive(headers map[string]string, target string) bool {
 	for k := range headers {
 		if strings.EqualFold(k, target) {
@@ -40,32 +101,117 @@ func headerExistsCaseInsensitive(headers map[string]string, target string) bool
 	return false
 }
 
+// responseFramingSignals reports conflicting framing headers in the test
+// response that the baseline did not have: several different
+// Content-Length values, or Transfer-Encoding alongside Content-Length.
+// A front-end relaying such a response has let malformed framing through,
+// and a client or cache reading it may desync in turn.
+func responseFramingSignals(comparison *models.BaselineComparison) []Signal {
+	test := comparison.Test
+	if test == nil {
+		return nil
+	}
+
+	var signals []Signal
+
+	lengths := distinctHeaderValues(test, "Content-Length")
+	if len(lengths) > 1 && (comparison.Baseline == nil || len(distinctHeaderValues(comparison.Baseline, "Content-Length")) < 2) {
+		signals = append(signals, Signal{"response-dual-cl", 0.30,
+			fmt.Sprintf("Response carries conflicting Content-Length values (Content-Length: %s)", strings.Join(lengths, " / "))})
+	}
+
+	te := test.HeaderValues("Transfer-Encoding")
+	baselineBoth := comparison.Baseline != nil &&
+		len(comparison.Baseline.HeaderValues("Transfer-Encoding")) > 0 &&
+		len(comparison.Baseline.HeaderValues("Content-Length")) > 0
+	if len(te) > 0 && len(lengths) > 0 && !baselineBoth {
+		signals = append(signals, Signal{"response-te-cl", 0.25,
+			fmt.Sprintf("Response carries both Transfer-Encoding: %s and Content-Length: %s",
+				strings.Join(te, ", "), strings.Join(lengths, " / "))})
+	}
+
+	return signals
+}
+
+// distinctHeaderValues returns the distinct values of the named header in
+// the order first seen, splitting comma-separated lists.
+func distinctHeaderValues(resp *models.HTTPResponse, name string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, v := range resp.HeaderValues(name) {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part != "" && !seen[part] {
+				seen[part] = true
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
 func finalizeResult(
 	d *Detector,
 	result *models.ScanResult,
-	confidence float64,
 	strongSignal bool,
 	comparison *models.BaselineComparison,
 	technique string,
-	signals []string,
+	signals []Signal,
 ) *models.ScanResult {
 
+	if comparison.Test != nil && d.IsBlockStatus(comparison.Test.StatusCode) {
+		result.Blocked = true
+		result.Signals = []string{"blocked"}
+		result.ReasonCode = ReasonCode(result.Technique, result.Signals)
+		result.ResponseTimeDiff = comparison.TimingDiffMS
+		result.Reason = BlockedReason(comparison.Test.StatusCode)
+		return result
+	}
+
+	d.applyWeights(technique, signals)
+
+	confidence := 0.0
+	for _, s := range signals {
+		confidence += s.Weight
+	}
 	if confidence > 1.0 {
 		confidence = 1.0
 	}
 
+	result.Signals = signalCodes(signals)
+	result.ResponseTimeDiff = comparison.TimingDiffMS
+	result.TestTiming = comparison.TestTiming
+	result.BaselineTiming = comparison.BaselineTiming
+
+	if note, ok := WAFResponse(comparison.Test); ok {
+		downgraded := confidence * wafConfidenceFactor
+		result.WAFDetected = true
+		result.WAFNote = note
+		result.Signals = append(result.Signals, "waf")
+		sort.Strings(result.Signals)
+		result.ReasonCode = ReasonCode(result.Technique, result.Signals)
+		result.Confidence = downgraded
+		result.ConfidenceScore = downgraded
+
+		var reason strings.Builder
+		reason.WriteString(WAFReason(note, confidence, downgraded))
+		if len(signals) > 0 {
+			reason.WriteString("\nSignals observed:\n")
+			writeSignalArithmetic(&reason, signals)
+		}
+		result.Reason = reason.String()
+		return result
+	}
+
+	result.ReasonCode = ReasonCode(result.Technique, result.Signals)
+	result.Confidence = confidence
 	result.ConfidenceScore = confidence
 	result.Suspicious = strongSignal && confidence >= d.confidenceThreshold
-	result.ResponseTimeDiff = comparison.TimingDiffMS
 
 	if result.Suspicious {
 		result.Reason = d.buildExplanation(technique, confidence, signals)
 	} else {
-		result.Reason = fmt.Sprintf(
-			"Insufficient evidence (confidence: %.1f%% < %.1f%%)",
-			confidence*100,
-			d.confidenceThreshold*100,
-		)
+		result.Reason = d.buildShortfall(confidence, strongSignal, signals)
 	}
 
 	return result
@@ -81,46 +227,54 @@ func (d *Detector) AnalyzeCLTE(target string, comparison *models.BaselineCompari
 		TestResponse:     comparison.Test,
 	}
 
-	confidence := 0.0
-	signals := []string{}
+	signals := []Signal{}
 	strongSignal := false
 
 	if comparison.StatusCodeChanged && comparison.NewStatusCode == 400 {
-		confidence += 0.25
 		strongSignal = true
-		signals = append(signals, "Backend returned 400 (malformed request detection)")
+		signals = append(signals, Signal{"status-400", 0.25, "Backend returned 400 (malformed request detection)"})
 	}
 
 	if comparison.StatusCodeChanged && comparison.NewStatusCode >= 500 {
-		confidence += 0.35
 		strongSignal = true
-		signals = append(signals, "Backend returned 5xx error (possible parser confusion)")
+		signals = append(signals, Signal{"status-5xx", 0.35, "Backend returned 5xx error (possible parser confusion)"})
 	}
 
-	if comparison.TimingDiffMS < -30 {
-		confidence += 0.15
+	if comparison.MalformedStatusAppeared {
+		strongSignal = true
 		signals = append(signals,
-			fmt.Sprintf("Response %d ms faster (possible early rejection)", -comparison.TimingDiffMS))
+			Signal{"malformed-status", 0.45, fmt.Sprintf("Non-numeric status line %q (smuggled bytes reached the response)", comparison.NewStatusLine)})
+	}
+
+	if comparison.TimingDiffMS < -30 && comparison.TimingSeparated() {
+		signals = append(signals,
+			Signal{"timing-faster", 0.15, fmt.Sprintf("Response %d ms faster (possible early rejection)", -comparison.TimingDiffMS)})
 	}
 
 	if comparison.ConnectionBehaviorChanged && comparison.NewConnectionClosed {
-		confidence += 0.20
 		strongSignal = true
-		signals = append(signals, "Server closed connection (possible state confusion)")
+		signals = append(signals, Signal{"connection-closed", 0.20, "Server closed connection (possible state confusion)"})
 	}
 
 	if comparison.BodyChanged && comparison.BodySizeDiff < -200 {
-		confidence += 0.15
 		signals = append(signals,
-			fmt.Sprintf("Response body %d bytes smaller (possible content absorption)", -comparison.BodySizeDiff))
+			Signal{"body-shrunk", 0.15, fmt.Sprintf("Response body %d bytes smaller (possible content absorption)", -comparison.BodySizeDiff)})
 	}
 
 	if headerExistsCaseInsensitive(comparison.HeadersRemoved, "Transfer-Encoding") {
-		confidence += 0.10
-		signals = append(signals, "Transfer-Encoding header removed by backend")
+		signals = append(signals, Signal{"te-removed", 0.10, "Transfer-Encoding header removed by backend"})
 	}
 
-	return finalizeResult(d, result, confidence, strongSignal, comparison, "CL.TE", signals)
+	if comparison.BackendChanged {
+		signals = append(signals, Signal{"backend-changed", 0.15, "Response fingerprint changed (header order/Server) - possibly routed to a different backend"})
+	}
+
+	if framing := responseFramingSignals(comparison); len(framing) > 0 {
+		strongSignal = true
+		signals = append(signals, framing...)
+	}
+
+	return finalizeResult(d, result, strongSignal, comparison, "CL.TE", signals)
 }
 
 // ---------- TE.CL ----------
@@ -133,46 +287,54 @@ func (d *Detector) AnalyzeTECL(target string, comparison *models.BaselineCompari
 		TestResponse:     comparison.Test,
 	}
 
-	confidence := 0.0
-	signals := []string{}
+	signals := []Signal{}
 	strongSignal := false
 
 	if comparison.StatusCodeChanged && comparison.NewStatusCode == 400 {
-		confidence += 0.25
 		strongSignal = true
-		signals = append(signals, "Backend returned 400 (parsing error)")
+		signals = append(signals, Signal{"status-400", 0.25, "Backend returned 400 (parsing error)"})
 	}
 
 	if comparison.StatusCodeChanged && comparison.NewStatusCode >= 500 {
-		confidence += 0.35
 		strongSignal = true
-		signals = append(signals, "Backend returned 5xx error (server confusion)")
+		signals = append(signals, Signal{"status-5xx", 0.35, "Backend returned 5xx error (server confusion)"})
+	}
+
+	if comparison.MalformedStatusAppeared {
+		strongSignal = true
+		signals = append(signals,
+			Signal{"malformed-status", 0.45, fmt.Sprintf("Non-numeric status line %q (smuggled bytes reached the response)", comparison.NewStatusLine)})
 	}
 
-	if comparison.TimingDiffMS > 1000 {
-		confidence += 0.25
+	if comparison.TimingDiffMS > 1000 && comparison.TimingSeparated() {
 		signals = append(signals,
-			fmt.Sprintf("Response %d ms slower (possible chunk reassembly delay)", comparison.TimingDiffMS))
+			Signal{"timing-slower", 0.25, fmt.Sprintf("Response %d ms slower (possible chunk reassembly delay)", comparison.TimingDiffMS)})
 	}
 
 	if comparison.ConnectionBehaviorChanged && comparison.NewConnectionClosed {
-		confidence += 0.20
 		strongSignal = true
-		signals = append(signals, "Server closed connection (chunked parsing failure)")
+		signals = append(signals, Signal{"connection-closed", 0.20, "Server closed connection (chunked parsing failure)"})
 	}
 
 	if comparison.BodyChanged {
-		confidence += 0.10
 		signals = append(signals,
-			fmt.Sprintf("Response body changed by %d bytes", comparison.BodySizeDiff))
+			Signal{"body-changed", 0.10, fmt.Sprintf("Response body changed by %d bytes", comparison.BodySizeDiff)})
 	}
 
 	if headerExistsCaseInsensitive(comparison.HeadersAdded, "Content-Length") {
-		confidence += 0.10
-		signals = append(signals, "Content-Length header added by backend")
+		signals = append(signals, Signal{"cl-added", 0.10, "Content-Length header added by backend"})
+	}
+
+	if comparison.BackendChanged {
+		signals = append(signals, Signal{"backend-changed", 0.15, "Response fingerprint changed (header order/Server) - possibly routed to a different backend"})
 	}
 
-	return finalizeResult(d, result, confidence, strongSignal, comparison, "TE.CL", signals)
+	if framing := responseFramingSignals(comparison); len(framing) > 0 {
+		strongSignal = true
+		signals = append(signals, framing...)
+	}
+
+	return finalizeResult(d, result, strongSignal, comparison, "TE.CL", signals)
 }
 
 // ---------- Mixed TE ----------
@@ -185,29 +347,137 @@ func (d *Detector) AnalyzeMixedTE(target string, comparison *models.BaselineComp
 		TestResponse:     comparison.Test,
 	}
 
-	confidence := 0.0
-	signals := []string{}
+	signals := []Signal{}
+	strongSignal := false
+
+	if comparison.StatusCodeChanged && comparison.NewStatusCode == 400 {
+		strongSignal = true
+		signals = append(signals, Signal{"status-400", 0.30, "Backend rejected mixed TE header"})
+	}
+
+	if comparison.StatusCodeChanged && comparison.NewStatusCode >= 500 {
+		strongSignal = true
+		signals = append(signals, Signal{"status-5xx", 0.40, "Server error from TE header ambiguity"})
+	}
+
+	if comparison.MalformedStatusAppeared {
+		strongSignal = true
+		signals = append(signals,
+			Signal{"malformed-status", 0.45, fmt.Sprintf("Non-numeric status line %q (smuggled bytes reached the response)", comparison.NewStatusLine)})
+	}
+
+	if comparison.ConnectionBehaviorChanged && comparison.NewConnectionClosed {
+		strongSignal = true
+		signals = append(signals, Signal{"connection-closed", 0.20, "Connection reset (TE parser confusion)"})
+	}
+
+	if comparison.BackendChanged {
+		signals = append(signals, Signal{"backend-changed", 0.15, "Response fingerprint changed (header order/Server) - possibly routed to a different backend"})
+	}
+
+	if framing := responseFramingSignals(comparison); len(framing) > 0 {
+		strongSignal = true
+		signals = append(signals, framing...)
+	}
+
+	return finalizeResult(d, result, strongSignal, comparison, "Mixed-TE", signals)
+}
+
+// ---------- TE.TE ----------
+
+func (d *Detector) AnalyzeTETE(target string, comparison *models.BaselineComparison) *models.ScanResult {
+	result := &models.ScanResult{
+		Target:           target,
+		Technique:        "TE.TE",
+		BaselineResponse: comparison.Baseline,
+		TestResponse:     comparison.Test,
+	}
+
+	signals := []Signal{}
 	strongSignal := false
 
 	if comparison.StatusCodeChanged && comparison.NewStatusCode == 400 {
-		confidence += 0.30
 		strongSignal = true
-		signals = append(signals, "Backend rejected mixed TE header")
+		signals = append(signals, Signal{"status-400", 0.30, "Backend rejected duplicate Transfer-Encoding header"})
 	}
 
 	if comparison.StatusCodeChanged && comparison.NewStatusCode >= 500 {
-		confidence += 0.40
 		strongSignal = true
-		signals = append(signals, "Server error from TE header ambiguity")
+		signals = append(signals, Signal{"status-5xx", 0.40, "Server error from duplicate Transfer-Encoding header"})
+	}
+
+	if comparison.MalformedStatusAppeared {
+		strongSignal = true
+		signals = append(signals,
+			Signal{"malformed-status", 0.45, fmt.Sprintf("Non-numeric status line %q (smuggled bytes reached the response)", comparison.NewStatusLine)})
+	}
+
+	if comparison.TimingDiffMS > 1000 && comparison.TimingSeparated() {
+		signals = append(signals,
+			Signal{"timing-slower", 0.25, fmt.Sprintf("Response %d ms slower (one server waited for a body the other considered complete)", comparison.TimingDiffMS)})
 	}
 
 	if comparison.ConnectionBehaviorChanged && comparison.NewConnectionClosed {
-		confidence += 0.20
 		strongSignal = true
-		signals = append(signals, "Connection reset (TE parser confusion)")
+		signals = append(signals, Signal{"connection-closed", 0.20, "Connection reset (duplicate TE parser confusion)"})
 	}
 
-	return finalizeResult(d, result, confidence, strongSignal, comparison, "Mixed-TE", signals)
+	if comparison.BackendChanged {
+		signals = append(signals, Signal{"backend-changed", 0.15, "Response fingerprint changed (header order/Server) - possibly routed to a different backend"})
+	}
+
+	return finalizeResult(d, result, strongSignal, comparison, "TE.TE", signals)
+}
+
+// ---------- CL.CL ----------
+
+func (d *Detector) AnalyzeDualCL(target string, comparison *models.BaselineComparison) *models.ScanResult {
+	result := &models.ScanResult{
+		Target:           target,
+		Technique:        "CL.CL",
+		BaselineResponse: comparison.Baseline,
+		TestResponse:     comparison.Test,
+	}
+
+	signals := []Signal{}
+	strongSignal := false
+
+	if comparison.StatusCodeChanged && comparison.NewStatusCode == 400 {
+		strongSignal = true
+		signals = append(signals, Signal{"status-400", 0.30, "Server rejected conflicting Content-Length headers"})
+	}
+
+	if comparison.StatusCodeChanged && comparison.NewStatusCode >= 500 {
+		strongSignal = true
+		signals = append(signals, Signal{"status-5xx", 0.40, "Server error from conflicting Content-Length headers"})
+	}
+
+	if comparison.MalformedStatusAppeared {
+		strongSignal = true
+		signals = append(signals,
+			Signal{"malformed-status", 0.45, fmt.Sprintf("Non-numeric status line %q (smuggled bytes reached the response)", comparison.NewStatusLine)})
+	}
+
+	if comparison.TimingDiffMS > 1000 && comparison.TimingSeparated() {
+		signals = append(signals,
+			Signal{"timing-slower", 0.25, fmt.Sprintf("Response %d ms slower (one server waited for the longer Content-Length)", comparison.TimingDiffMS)})
+	}
+
+	if comparison.ConnectionBehaviorChanged && comparison.NewConnectionClosed {
+		strongSignal = true
+		signals = append(signals, Signal{"connection-closed", 0.20, "Connection reset (Content-Length disagreement)"})
+	}
+
+	if comparison.BodyChanged {
+		signals = append(signals,
+			Signal{"body-changed", 0.10, fmt.Sprintf("Response body changed by %d bytes", comparison.BodySizeDiff)})
+	}
+
+	if comparison.BackendChanged {
+		signals = append(signals, Signal{"backend-changed", 0.15, "Response fingerprint changed (header order/Server) - possibly routed to a different backend"})
+	}
+
+	return finalizeResult(d, result, strongSignal, comparison, "CL.CL", signals)
 }
 
 // ---------- Obfuscated TE ----------
@@ -220,51 +490,212 @@ func (d *Detector) AnalyzeObfuscatedTE(target string, comparison *models.Baselin
 		TestResponse:     comparison.Test,
 	}
 
-	confidence := 0.0
-	signals := []string{}
+	signals := []Signal{}
 	strongSignal := false
 
 	if comparison.StatusCodeChanged && comparison.NewStatusCode == 400 {
-		confidence += 0.25
 		strongSignal = true
-		signals = append(signals, "Backend returned 400 (obfuscated TE rejection or malformed request)")
+		signals = append(signals, Signal{"status-400", 0.25, "Backend returned 400 (obfuscated TE rejection or malformed request)"})
 	}
 
 	if comparison.StatusCodeChanged && comparison.NewStatusCode >= 500 {
-		confidence += 0.35
 		strongSignal = true
-		signals = append(signals, "Backend returned 5xx error (TE obfuscation parser confusion)")
+		signals = append(signals, Signal{"status-5xx", 0.35, "Backend returned 5xx error (TE obfuscation parser confusion)"})
 	}
 
-	if comparison.TimingDiffMS < -30 {
-		confidence += 0.15
+	if comparison.MalformedStatusAppeared {
+		strongSignal = true
 		signals = append(signals,
-			fmt.Sprintf("Response %d ms faster (obfuscated TE caused early rejection)", -comparison.TimingDiffMS))
+			Signal{"malformed-status", 0.45, fmt.Sprintf("Non-numeric status line %q (smuggled bytes reached the response)", comparison.NewStatusLine)})
+	}
+
+	if comparison.TimingDiffMS < -30 && comparison.TimingSeparated() {
+		signals = append(signals,
+			Signal{"timing-faster", 0.15, fmt.Sprintf("Response %d ms faster (obfuscated TE caused early rejection)", -comparison.TimingDiffMS)})
 	}
 
 	if comparison.ConnectionBehaviorChanged && comparison.NewConnectionClosed {
-		confidence += 0.20
 		strongSignal = true
-		signals = append(signals, "Server closed connection (TE obfuscation parser failure)")
+		signals = append(signals, Signal{"connection-closed", 0.20, "Server closed connection (TE obfuscation parser failure)"})
 	}
 
 	if comparison.BodyChanged && comparison.BodySizeDiff < -200 {
-		confidence += 0.15
 		signals = append(signals,
-			fmt.Sprintf("Response body %d bytes smaller (obfuscated TE caused content absorption)", -comparison.BodySizeDiff))
+			Signal{"body-shrunk", 0.15, fmt.Sprintf("Response body %d bytes smaller (obfuscated TE caused content absorption)", -comparison.BodySizeDiff)})
 	}
 
 	if headerExistsCaseInsensitive(comparison.HeadersRemoved, "Transfer-Encoding") {
-		confidence += 0.10
-		signals = append(signals, "Transfer-Encoding header removed (backend rejected obfuscation)")
+		signals = append(signals, Signal{"te-removed", 0.10, "Transfer-Encoding header removed (backend rejected obfuscation)"})
 	}
 
-	return finalizeResult(d, result, confidence, strongSignal, comparison, "Obfuscated-TE", signals)
+	if comparison.BackendChanged {
+		signals = append(signals, Signal{"backend-changed", 0.15, "Response fingerprint changed (header order/Server) - possibly routed to a different backend"})
+	}
+
+	return finalizeResult(d, result, strongSignal, comparison, "Obfuscated-TE", signals)
+}
+
+// ---------- Chunk extensions ----------
+
+// AnalyzeChunkExtension inspects the response to a CL.TE-framed request
+// whose chunked body carries a chunk extension or trailer. A back-end that
+// reads the size line or trailer section differently from the front-end
+// rejects the body or treats part of it as the next request.
+func (d *Detector) AnalyzeChunkExtension(target string, comparison *models.BaselineComparison) *models.ScanResult {
+	result := &models.ScanResult{
+		Target:           target,
+		Technique:        "Chunk-Ext",
+		BaselineResponse: comparison.Baseline,
+		TestResponse:     comparison.Test,
+	}
+
+	signals := []Signal{}
+	strongSignal := false
+
+	if comparison.StatusCodeChanged && comparison.NewStatusCode == 400 {
+		strongSignal = true
+		signals = append(signals, Signal{"status-400", 0.25, "Backend returned 400 (chunk extension or trailer rejected)"})
+	}
+
+	if comparison.StatusCodeChanged && comparison.NewStatusCode >= 500 {
+		strongSignal = true
+		signals = append(signals, Signal{"status-5xx", 0.35, "Backend returned 5xx error (chunked body parser confusion)"})
+	}
+
+	if comparison.MalformedStatusAppeared {
+		strongSignal = true
+		signals = append(signals,
+			Signal{"malformed-status", 0.45, fmt.Sprintf("Non-numeric status line %q (smuggled bytes reached the response)", comparison.NewStatusLine)})
+	}
+
+	if comparison.TimingDiffMS > 1000 && comparison.TimingSeparated() {
+		signals = append(signals,
+			Signal{"timing-slower", 0.15, fmt.Sprintf("Response %d ms slower (backend waiting for the rest of a chunk)", comparison.TimingDiffMS)})
+	}
+
+	if comparison.TimingDiffMS < -30 && comparison.TimingSeparated() {
+		signals = append(signals,
+			Signal{"timing-faster", 0.15, fmt.Sprintf("Response %d ms faster (chunk extension caused early rejection)", -comparison.TimingDiffMS)})
+	}
+
+	if comparison.ConnectionBehaviorChanged && comparison.NewConnectionClosed {
+		strongSignal = true
+		signals = append(signals, Signal{"connection-closed", 0.20, "Server closed connection (chunked body parser failure)"})
+	}
+
+	if comparison.BodyChanged && comparison.BodySizeDiff < -200 {
+		signals = append(signals,
+			Signal{"body-shrunk", 0.15, fmt.Sprintf("Response body %d bytes smaller (chunk boundaries read differently)", -comparison.BodySizeDiff)})
+	}
+
+	if comparison.BackendChanged {
+		signals = append(signals, Signal{"backend-changed", 0.15, "Response fingerprint changed (header order/Server) - possibly routed to a different backend"})
+	}
+
+	if framing := responseFramingSignals(comparison); len(framing) > 0 {
+		strongSignal = true
+		signals = append(signals, framing...)
+	}
+
+	return finalizeResult(d, result, strongSignal, comparison, "Chunk-Ext", signals)
+}
+
+// ---------- CL.0 ----------
+
+// AnalyzeCL0 inspects the response to a follow-up request sent on the same
+// connection as a CL.0 attack. If the back-end ignored the attack body, the
+// follow-up is answered as the smuggled request for marker.
+func (d *Detector) AnalyzeCL0(target string, comparison *models.BaselineComparison, marker string) *models.ScanResult {
+	result := &models.ScanResult{
+		Target:           target,
+		Technique:        "CL.0",
+		BaselineResponse: comparison.Baseline,
+		TestResponse:     comparison.Test,
+	}
+
+	signals := []Signal{}
+	strongSignal := false
+
+	if comparison.StatusCodeChanged && comparison.NewStatusCode == 404 {
+		strongSignal = true
+		signals = append(signals, Signal{"followup-404", 0.50, "Follow-up request answered with 404 (smuggled path served instead)"})
+	} else if comparison.StatusCodeChanged && comparison.NewStatusCode != 0 {
+		strongSignal = true
+		signals = append(signals,
+			Signal{"followup-status", 0.25, fmt.Sprintf("Follow-up status changed %d -> %d", comparison.OldStatusCode, comparison.NewStatusCode)})
+	}
+
+	if marker != "" && comparison.Test != nil &&
+		strings.Contains(comparison.Test.Body, marker) {
+		strongSignal = true
+		signals = append(signals, Signal{"marker-reflected", 0.35, "Follow-up response reflects the smuggled request path"})
+	}
+
+	if comparison.BackendChanged {
+		signals = append(signals, Signal{"backend-changed", 0.15, "Response fingerprint changed (header order/Server) - possibly routed to a different backend"})
+	}
+
+	return finalizeResult(d, result, strongSignal, comparison, "CL.0", signals)
+}
+
+// ---------- HTTP/2 downgrade ----------
+
+// AnalyzeH2CL analyzes the follow-up to an H2.CL probe: comparison pairs
+// the HTTP/2 baseline with the response to a benign request sent after the
+// attack, and marker is the path of the smuggled request.
+func (d *Detector) AnalyzeH2CL(target string, comparison *models.BaselineComparison, marker string) *models.ScanResult {
+	return d.analyzeH2Downgrade(target, comparison, marker, "H2.CL")
+}
+
+// AnalyzeH2TE is AnalyzeH2CL for the H2.TE probe.
+func (d *Detector) AnalyzeH2TE(target string, comparison *models.BaselineComparison, marker string) *models.ScanResult {
+	return d.analyzeH2Downgrade(target, comparison, marker, "H2.TE")
+}
+
+func (d *Detector) analyzeH2Downgrade(target string, comparison *models.BaselineComparison, marker, technique string) *models.ScanResult {
+	result := &models.ScanResult{
+		Target:           target,
+		Technique:        technique,
+		BaselineResponse: comparison.Baseline,
+		TestResponse:     comparison.Test,
+	}
+
+	signals := []Signal{}
+	strongSignal := false
+
+	if comparison.StatusCodeChanged && comparison.NewStatusCode == 404 {
+		strongSignal = true
+		signals = append(signals, Signal{"followup-404", 0.50, "Follow-up request answered with 404 (smuggled path served instead)"})
+	} else if comparison.StatusCodeChanged && comparison.NewStatusCode >= 500 {
+		strongSignal = true
+		signals = append(signals,
+			Signal{"followup-5xx", 0.30, fmt.Sprintf("Follow-up answered with %d (back-end connection desynced)", comparison.NewStatusCode)})
+	} else if comparison.StatusCodeChanged && comparison.NewStatusCode != 0 {
+		signals = append(signals,
+			Signal{"followup-status", 0.25, fmt.Sprintf("Follow-up status changed %d -> %d", comparison.OldStatusCode, comparison.NewStatusCode)})
+	}
+
+	if marker != "" && comparison.Test != nil &&
+		strings.Contains(comparison.Test.Body, marker) {
+		strongSignal = true
+		signals = append(signals, Signal{"marker-reflected", 0.35, "Follow-up response reflects the smuggled request path"})
+	}
+
+	if comparison.TimingDiffMS > 1000 && comparison.TimingSeparated() {
+		signals = append(signals,
+			Signal{"timing-slower", 0.20, fmt.Sprintf("Follow-up %d ms slower (back-end waiting on leftover body)", comparison.TimingDiffMS)})
+	}
+
+	if comparison.BackendChanged {
+		signals = append(signals, Signal{"backend-changed", 0.15, "Response fingerprint changed (header order/Server) - possibly routed to a different backend"})
+	}
+
+	return finalizeResult(d, result, strongSignal, comparison, technique, signals)
 }
 
 // ---------- Explanation ----------
 
-func (d *Detector) buildExplanation(technique string, confidence float64, signals []string) string {
+func (d *Detector) buildExplanation(technique string, confidence float64, signals []Signal) string {
 	var explanation strings.Builder
 
 	explanation.WriteString(
@@ -276,63 +707,244 @@ func (d *Detector) buildExplanation(technique string, confidence float64, signal
 		explanation.WriteString("  - Behavioral anomaly detected\n")
 	}
 
-	for _, s := range signals {
-		explanation.WriteString(fmt.Sprintf("  - %s\n", s))
+	writeSignalArithmetic(&explanation, signals)
+	explanation.WriteString(
+		fmt.Sprintf("  = %.2f (threshold %.2f)\n", confidence, d.confidenceThreshold),
+	)
+
+	return explanation.String()
+}
+
+// buildShortfall explains a clean result: the signals that did fire, their
+// total, and how far it fell short of the threshold.
+func (d *Detector) buildShortfall(confidence float64, strongSignal bool, signals []Signal) string {
+	var explanation strings.Builder
+
+	if confidence >= d.confidenceThreshold && !strongSignal {
+		explanation.WriteString(fmt.Sprintf(
+			"Insufficient evidence (confidence: %.1f%%, but only weak signals)",
+			confidence*100,
+		))
+	} else {
+		explanation.WriteString(fmt.Sprintf(
+			"Insufficient evidence (confidence: %.1f%% < %.1f%%)",
+			confidence*100,
+			d.confidenceThreshold*100,
+		))
+	}
+
+	if len(signals) == 0 {
+		return explanation.String()
+	}
+
+	explanation.WriteString("\nSignals observed:\n")
+	writeSignalArithmetic(&explanation, signals)
+
+	if confidence >= d.confidenceThreshold && !strongSignal {
+		explanation.WriteString(fmt.Sprintf(
+			"  = %.2f (threshold %.2f reached, but no strong signal)\n",
+			confidence, d.confidenceThreshold,
+		))
+	} else {
+		explanation.WriteString(fmt.Sprintf(
+			"  = %.2f (%.2f short of threshold %.2f)\n",
+			confidence, d.confidenceThreshold-confidence, d.confidenceThreshold,
+		))
 	}
 
 	return explanation.String()
 }
 
+// writeSignalArithmetic lists each signal with its weight and the running
+// total after it.
+func writeSignalArithmetic(b *strings.Builder, signals []Signal) {
+	total := 0.0
+	for _, s := range signals {
+		total += s.Weight
+		b.WriteString(fmt.Sprintf("  +%.2f %s (running total %.2f)\n", s.Weight, s.Detail, total))
+	}
+}
+
 // ---------- Report ----------
 
 type DetectionReport struct {
 	Target              string
 	TotalTests          int
 	Vulnerable          int
+	Blocked             int
+	WAF                 int
 	Suspicious          []*models.ScanResult
 	NonSuspicious       []*models.ScanResult
 	HighestConfidence   float64
 	MostLikelyTechnique string
+
+	// AITechnique and AIConfidence are the AI provider's pick of the most
+	// likely technique, empty when no provider was consulted.
+	AITechnique  string
+	AIConfidence float64
+
+	// Variants maps a technique family (e.g. "Obfuscated-TE") to the
+	// suspicious variants of it (e.g. "Obfuscated-TE[cow]").
+	Variants map[string][]string
+
+	// Health is the post-scan health check, nil if it was not run.
+	Health *models.HealthCheck
+
+	// Notes is advice from the stack fingerprint (see StackNotes).
+	Notes []string
+}
+
+// TechniqueFamily strips the variant suffix from a technique name, so
+// "Obfuscated-TE[x-chunked]" and "Obfuscated-TE[cow]" group together.
+func TechniqueFamily(technique string) string {
+	if i := strings.Index(technique, "["); i > 0 && strings.HasSuffix(technique, "]") {
+		return technique[:i]
+	}
+	return technique
 }
 
+// Dedup collapses results describing the same finding, keyed by target,
+// technique and reason code, so confirmation runs and retries do not
+// inflate the vulnerable count. The highest-confidence instance is kept,
+// in the position of the first occurrence, with Observations set to the
+// number of results it stands for.
+func Dedup(results []*models.ScanResult) []*models.ScanResult {
+	type key struct{ target, technique, reason string }
+
+	index := make(map[key]int, len(results))
+	counts := make([]int, 0, len(results))
+	out := make([]*models.ScanResult, 0, len(results))
+
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		k := key{r.Target, r.Technique, r.ReasonCode}
+		i, seen := index[k]
+		if !seen {
+			index[k] = len(out)
+			out = append(out, r)
+			counts = append(counts, 1)
+			continue
+		}
+		counts[i]++
+		if r.GetConfidence() > out[i].GetConfidence() {
+			out[i] = r
+		}
+	}
+
+	for i, r := range out {
+		r.Observations = counts[i]
+	}
+	return out
+}
+
+// GenerateReport summarizes results for target. Suspicious results are
+// ordered by confidence, strongest first, and the strongest one sets
+// MostLikelyTechnique and HighestConfidence.
 func (d *Detector) GenerateReport(target string, results ...*models.ScanResult) *DetectionReport {
 	report := &DetectionReport{
 		Target:        target,
 		TotalTests:    len(results),
 		Suspicious:    make([]*models.ScanResult, 0),
 		NonSuspicious: make([]*models.ScanResult, 0),
+		Variants:      make(map[string][]string),
 	}
 
-	highest := 0.0
-
 	for _, result := range results {
 		if result.Suspicious {
 			report.Vulnerable++
 			report.Suspicious = append(report.Suspicious, result)
 
-			if result.ConfidenceScore > highest {
-				highest = result.ConfidenceScore
-				report.HighestConfidence = highest
-				report.MostLikelyTechnique = result.Technique
+			family := TechniqueFamily(result.Technique)
+			if family != result.Technique {
+				report.Variants[family] = append(report.Variants[family], result.Technique)
 			}
 		} else {
+			if result.Blocked {
+				report.Blocked++
+			}
+			if result.WAFDetected {
+				report.WAF++
+			}
 			report.NonSuspicious = append(report.NonSuspicious, result)
 		}
 	}
 
+	// Strongest finding first; ties keep the order the tests ran in.
+	sort.SliceStable(report.Suspicious, func(i, j int) bool {
+		return report.Suspicious[i].GetConfidence() > report.Suspicious[j].GetConfidence()
+	})
+	if len(report.Suspicious) > 0 {
+		top := report.Suspicious[0]
+		report.HighestConfidence = top.GetConfidence()
+		report.MostLikelyTechnique = top.Technique
+	}
+
 	return report
 }
 
+// aiAgreement describes how the AI's pick relates to the detector's.
+func (r *DetectionReport) aiAgreement() string {
+	if r.MostLikelyTechnique == "" {
+		return "detector found nothing"
+	}
+	if NormalizeTechnique(TechniqueFamily(r.AITechnique)) == NormalizeTechnique(TechniqueFamily(r.MostLikelyTechnique)) {
+		return "agrees with detector"
+	}
+	return "detector chose " + r.MostLikelyTechnique
+}
+
+// NormalizeTechnique lowercases s and drops punctuation, so "CL.TE" and "cl-te"
+// compare equal.
+func NormalizeTechnique(s string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(s) {
+		if ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
 // String returns a human-readable representation of the detection report.
 func (r *DetectionReport) String() string {
 	var b strings.Builder
 	fmt.Fprintf(&b, "Detection report for %s\n", r.Target)
 	fmt.Fprintf(&b, "Total tests: %d\n", r.TotalTests)
 	fmt.Fprintf(&b, "Vulnerable: %d\n", r.Vulnerable)
+	if r.Blocked > 0 {
+		fmt.Fprintf(&b, "Blocked (inconclusive): %d\n", r.Blocked)
+	}
+	if r.WAF > 0 {
+		fmt.Fprintf(&b, "WAF/rate-limit responses: %d (slow down with -rate/-delay or scan from another source IP)\n", r.WAF)
+	}
 	fmt.Fprintf(&b, "Highest confidence: %.2f\n", r.HighestConfidence)
 	if r.MostLikelyTechnique != "" {
 		fmt.Fprintf(&b, "Most likely technique: %s\n", r.MostLikelyTechnique)
 	}
+	if r.AITechnique != "" {
+		fmt.Fprintf(&b, "AI most likely technique: %s (%.0f%% confidence, %s)\n",
+			r.AITechnique, r.AIConfidence*100, r.aiAgreement())
+	}
+	families := make([]string, 0, len(r.Variants))
+	for family := range r.Variants {
+		families = append(families, family)
+	}
+	sort.Strings(families)
+	for _, family := range families {
+		fmt.Fprintf(&b, "%s variants: %s\n", family, strings.Join(r.Variants[family], ", "))
+	}
+	if r.Health != nil {
+		if r.Health.Healthy {
+			fmt.Fprintf(&b, "Post-scan health: OK (%s)\n", r.Health.Detail)
+		} else {
+			fmt.Fprintf(&b, "Post-scan health: WARNING - %s\n", r.Health.Detail)
+		}
+	}
+	for _, note := range r.Notes {
+		fmt.Fprintf(&b, "Note: %s\n", note)
+	}
 
 	if len(r.Suspicious) > 0 {
 		b.WriteString("\nSuspicious results:\n")
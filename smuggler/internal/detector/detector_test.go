@@ -0,0 +1,143 @@
+package detector
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"smuggler/internal/models"
+)
+
+func TestDedup(t *testing.T) {
+	result := func(target, technique, reason string, confidence float64) *models.ScanResult {
+		return &models.ScanResult{Target: target, Technique: technique, ReasonCode: reason, Confidence: confidence}
+	}
+
+	first := result("a:80", "CL.TE", "CL.TE:status-5xx", 0.4)
+	strongest := result("a:80", "CL.TE", "CL.TE:status-5xx", 0.8)
+	weaker := result("a:80", "CL.TE", "CL.TE:status-5xx", 0.6)
+	otherReason := result("a:80", "CL.TE", "CL.TE:connection-closed", 0.5)
+	otherTarget := result("b:80", "CL.TE", "CL.TE:status-5xx", 0.5)
+	otherTechnique := result("a:80", "TE.CL", "TE.CL:status-5xx", 0.5)
+
+	out := Dedup([]*models.ScanResult{first, otherReason, strongest, nil, otherTarget, weaker, otherTechnique})
+
+	want := []struct {
+		result       *models.ScanResult
+		observations int
+	}{
+		{strongest, 3},
+		{otherReason, 1},
+		{otherTarget, 1},
+		{otherTechnique, 1},
+	}
+	if len(out) != len(want) {
+		t.Fatalf("Dedup returned %d results, want %d", len(out), len(want))
+	}
+	for i, w := range want {
+		if out[i] != w.result {
+			t.Errorf("result %d = %s %s %.1f, want %s %s %.1f", i,
+				out[i].Target, out[i].ReasonCode, out[i].Confidence,
+				w.result.Target, w.result.ReasonCode, w.result.Confidence)
+		}
+		if out[i].Observations != w.observations {
+			t.Errorf("result %d Observations = %d, want %d", i, out[i].Observations, w.observations)
+		}
+	}
+}
+
+// status400Comparison is a test answered with 400 on a closed connection:
+// status-400 (0.25) and connection-closed (0.20), just under the default
+// 0.5 threshold.
+func status400Comparison() *models.BaselineComparison {
+	return &models.BaselineComparison{
+		Baseline:                  &models.HTTPResponse{StatusCode: 200},
+		Test:                      &models.HTTPResponse{StatusCode: 400},
+		StatusCodeChanged:         true,
+		OldStatusCode:             200,
+		NewStatusCode:             400,
+		ConnectionBehaviorChanged: true,
+		NewConnectionClosed:       true,
+	}
+}
+
+func TestSignalWeightsChangeVerdict(t *testing.T) {
+	tests := []struct {
+		name       string
+		weights    SignalWeights
+		suspicious bool
+	}{
+		{"built-in weights", nil, false},
+		{"raised signal", SignalWeights{"status-400": 0.4}, true},
+		{"raised for this technique", SignalWeights{"CL.TE:status-400": 0.4}, true},
+		{"raised for another technique", SignalWeights{"TE.CL:status-400": 0.4}, false},
+		{"technique key wins", SignalWeights{"status-400": 0.4, "CL.TE:status-400": 0.1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDetector().SetSignalWeights(tt.weights)
+			r := d.AnalyzeCLTE("a:80", status400Comparison())
+			if r.Suspicious != tt.suspicious {
+				t.Errorf("Suspicious = %v (confidence %.2f), want %v", r.Suspicious, r.GetConfidence(), tt.suspicious)
+			}
+		})
+	}
+}
+
+func TestAnalyzersSetConfidenceScore(t *testing.T) {
+	// A 503 on a closed connection: status-5xx plus connection-closed.
+	comparison := &models.BaselineComparison{
+		Baseline:                  &models.HTTPResponse{StatusCode: 200},
+		Test:                      &models.HTTPResponse{StatusCode: 503},
+		StatusCodeChanged:         true,
+		OldStatusCode:             200,
+		NewStatusCode:             503,
+		ConnectionBehaviorChanged: true,
+		NewConnectionClosed:       true,
+	}
+
+	d := NewDetector()
+	tests := []struct {
+		name    string
+		analyze func(string, *models.BaselineComparison) *models.ScanResult
+		want    float64
+	}{
+		{"CL.TE", d.AnalyzeCLTE, 0.35 + 0.20},
+		{"TE.CL", d.AnalyzeTECL, 0.35 + 0.20},
+		{"Mixed-TE", d.AnalyzeMixedTE, 0.40 + 0.20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.analyze("a:80", comparison)
+			if !r.Suspicious {
+				t.Fatalf("not flagged: %s", r.Reason)
+			}
+			if math.Abs(r.ConfidenceScore-tt.want) > 1e-9 {
+				t.Errorf("ConfidenceScore = %v, want the signal sum %v", r.ConfidenceScore, tt.want)
+			}
+			if r.Confidence != r.ConfidenceScore {
+				t.Errorf("Confidence = %v, ConfidenceScore = %v; want them equal", r.Confidence, r.ConfidenceScore)
+			}
+		})
+	}
+}
+
+func TestReportVariantsSorted(t *testing.T) {
+	report := &DetectionReport{
+		Target: "a:80",
+		Variants: map[string][]string{
+			"TE.TE":         {"TE.TE[space]"},
+			"Chunk-Ext":     {"Chunk-Ext[lf]", "Chunk-Ext[long]"},
+			"Obfuscated-TE": {"Obfuscated-TE[x-chunked]"},
+		},
+	}
+
+	want := "Chunk-Ext variants: Chunk-Ext[lf], Chunk-Ext[long]\n" +
+		"Obfuscated-TE variants: Obfuscated-TE[x-chunked]\n" +
+		"TE.TE variants: TE.TE[space]\n"
+	for i := 0; i < 10; i++ {
+		if out := report.String(); !strings.Contains(out, want) {
+			t.Fatalf("variants not listed in family order:\n%s", out)
+		}
+	}
+}
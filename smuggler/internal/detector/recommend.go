@@ -0,0 +1,146 @@
+package detector
+
+import (
+	"fmt"
+	"strings"
+
+	"smuggler/internal/models"
+)
+
+// Technique identifiers used for selecting which tests run.
+const (
+	TechCLTE         = "clte"
+	TechTECL         = "tecl"
+	TechMixedTE      = "mixed-te"
+	TechTETE         = "te-te"
+	TechDualCL       = "cl-cl"
+	TechObfuscatedTE = "obfuscated-te"
+	TechChunkExt     = "chunk-ext"
+	TechGPOST        = "clte-gpost"
+	TechCL0          = "cl0"
+	TechH2CL         = "h2-cl"
+	TechH2TE         = "h2-te"
+
+	TechHeaderInjection = "header-injection"
+	TechSegmented       = "segmented"
+)
+
+// AllTechniques lists every selectable technique in the order they run.
+var AllTechniques = []string{
+	TechCLTE,
+	TechTECL,
+	TechMixedTE,
+	TechTETE,
+	TechDualCL,
+	TechObfuscatedTE,
+	TechChunkExt,
+	TechGPOST,
+	TechSegmented,
+	TechHeaderInjection,
+	TechCL0,
+	TechH2CL,
+	TechH2TE,
+}
+
+// ParseTechniques lower-cases and trims technique identifiers, dropping
+// empty ones, and rejects any that are not in AllTechniques.
+func ParseTechniques(ids []string) ([]string, error) {
+	known := make(map[string]bool, len(AllTechniques))
+	for _, t := range AllTechniques {
+		known[t] = true
+	}
+
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" {
+			continue
+		}
+		if !known[id] {
+			return nil, fmt.Errorf("unknown technique %q (valid: %s)", id, strings.Join(AllTechniques, ", "))
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+// Recommendation is a technique suggested by the stack fingerprint.
+type Recommendation struct {
+	Technique string
+	Rationale string
+}
+
+// ExplainRecommendations applies known front-end/back-end heuristics to the
+// fingerprint and returns the techniques most likely to succeed, each with
+// the reason it was chosen.
+func ExplainRecommendations(info models.StackInfo) []Recommendation {
+	server := strings.ToLower(info.Server)
+	recs := []Recommendation{}
+	seen := map[string]bool{}
+
+	add := func(tech, why string) {
+		if seen[tech] {
+			return
+		}
+		seen[tech] = true
+		recs = append(recs, Recommendation{Technique: tech, Rationale: why})
+	}
+
+	switch {
+	case strings.Contains(server, "nginx"), strings.Contains(server, "openresty"):
+		add(TechTECL, "nginx front-ends honor Transfer-Encoding, so a CL-trusting back-end desyncs as TE.CL")
+		add(TechObfuscatedTE, "nginx rejects some TE obfuscations the back-end may accept")
+	case strings.Contains(server, "apache"), strings.Contains(server, "httpd"):
+		add(TechCLTE, "Apache is commonly a CL-trusting front-end ahead of chunked-aware back-ends")
+		add(TechDualCL, "some Apache modules pick a different one of duplicate Content-Length headers than the back-end")
+		add(TechMixedTE, "Apache's handling of duplicate Transfer-Encoding differs from most back-ends")
+	case strings.Contains(server, "cloudfront"),
+		strings.Contains(server, "akamai"),
+		strings.Contains(server, "cloudflare"),
+		strings.Contains(server, "varnish"),
+		strings.Contains(server, "haproxy"):
+		add(TechObfuscatedTE, "CDN/caching proxies normalize TE differently from origin servers")
+		add(TechMixedTE, "CDN/caching proxies often pick a different Transfer-Encoding header than the origin")
+		add(TechTETE, "CDN/caching proxies may drop a malformed duplicate Transfer-Encoding the origin honors")
+		add(TechCLTE, "CDNs frequently forward by Content-Length to chunked-aware origins")
+		add(TechChunkExt, "CDN/caching proxies forward chunk extensions and trailers the origin may parse differently")
+	case strings.Contains(server, "iis"), strings.Contains(server, "microsoft"):
+		add(TechCL0, "IIS is known to ignore bodies on some static endpoints (CL.0)")
+		add(TechCLTE, "IIS back-ends honor chunked encoding behind CL-trusting front-ends")
+	default:
+		add(TechCLTE, "unknown stack: CL.TE is the most common desync")
+		add(TechTECL, "unknown stack: TE.CL is the complementary classic desync")
+	}
+
+	if info.PipelineSupported {
+		add(TechGPOST, "connection reuse confirmed, so request poisoning is viable")
+		add(TechHeaderInjection, "connection reuse confirmed, so smuggled responses can reach other requests")
+	}
+
+	if info.ALPN == "h2" {
+		add(TechH2CL, "the front-end negotiates h2, so it may downgrade to HTTP/1.1 with a client-supplied content-length")
+		add(TechH2TE, "the front-end negotiates h2, so it may forward a transfer-encoding header to an HTTP/1.1 back-end")
+	}
+
+	return recs
+}
+
+// StackNotes returns advice drawn from the fingerprint that the results
+// alone do not show. h2Tested reports whether the HTTP/2 techniques ran.
+func StackNotes(info models.StackInfo, h2Tested bool) []string {
+	var notes []string
+	if info.ALPN == "h2" && !h2Tested {
+		notes = append(notes, "Server negotiates HTTP/2 (ALPN h2): test HTTP/2 downgrade smuggling with -http2 (h2-cl, h2-te)")
+	}
+	return notes
+}
+
+// RecommendTechniques returns the identifiers of the recommended techniques.
+func RecommendTechniques(info models.StackInfo) []string {
+	recs := ExplainRecommendations(info)
+	out := make([]string, 0, len(recs))
+	for _, r := range recs {
+		out = append(out, r.Technique)
+	}
+	return out
+}
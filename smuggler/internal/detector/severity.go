@@ -0,0 +1,68 @@
+package detector
+
+import (
+	"strings"
+
+	"smuggler/internal/models"
+)
+
+// SeverityOverrides fixes the severity of suspicious findings per
+// technique family ("CL.TE") or technique ("Obfuscated-TE[vtab]"); the
+// exact technique wins over its family.
+type SeverityOverrides map[string]models.Severity
+
+// SetSeverityOverrides replaces the built-in severity of suspicious
+// findings for the listed techniques; nil restores the built-in mapping.
+func (d *Detector) SetSeverityOverrides(o SeverityOverrides) *Detector {
+	d.severities = o
+	return d
+}
+
+// Severity ranks result for triage. Results that are not suspicious are
+// Info. Suspicious ones take a configured override if any; otherwise
+// proven poisoning (GPOST, header injection) is Critical, timing-only
+// evidence is Low, and the rest follow the confidence: High from 0.8,
+// Medium from 0.5, Low below.
+func (d *Detector) Severity(result *models.ScanResult) models.Severity {
+	if result == nil || !result.Suspicious {
+		return models.SeverityInfo
+	}
+
+	if sev, ok := d.severities[result.Technique]; ok {
+		return sev
+	}
+	if sev, ok := d.severities[TechniqueFamily(result.Technique)]; ok {
+		return sev
+	}
+
+	switch TechniqueFamily(result.Technique) {
+	case "CL.TE-GPOST", "Header-Injection":
+		return models.SeverityCritical
+	}
+
+	if timingOnly(result.Signals) {
+		return models.SeverityLow
+	}
+
+	switch conf := result.GetConfidence(); {
+	case conf >= 0.8:
+		return models.SeverityHigh
+	case conf >= 0.5:
+		return models.SeverityMedium
+	default:
+		return models.SeverityLow
+	}
+}
+
+// timingOnly reports whether every signal code is a timing signal.
+func timingOnly(codes []string) bool {
+	if len(codes) == 0 {
+		return false
+	}
+	for _, c := range codes {
+		if !strings.HasPrefix(c, "timing-") {
+			return false
+		}
+	}
+	return true
+}
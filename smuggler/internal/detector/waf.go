@@ -0,0 +1,97 @@
+package detector
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"smuggler/internal/models"
+)
+
+// wafConfidenceFactor scales the confidence of a result whose test
+// response looks like a WAF or rate-limit answer. Its status, body and
+// timing describe the filter rather than the back-end's parser.
+const wafConfidenceFactor = 0.5
+
+// wafStatuses are the statuses WAFs and CDNs use for block and challenge
+// pages. A body marker only counts on one of them, so a normal page that
+// embeds a CAPTCHA widget is not mistaken for a block.
+var wafStatuses = map[int]bool{
+	http.StatusForbidden:          true,
+	http.StatusNotAcceptable:      true,
+	http.StatusTooManyRequests:    true,
+	http.StatusServiceUnavailable: true,
+}
+
+// wafBodyMarkers are lower-case substrings of block and challenge pages,
+// with who serves them.
+var wafBodyMarkers = []struct {
+	marker string
+	source string
+}{
+	{"attention required! | cloudflare", "Cloudflare"},
+	{"cf-chl-", "Cloudflare challenge"},
+	{"challenge-platform", "Cloudflare challenge"},
+	{"the requested url was rejected", "F5 BIG-IP ASM"},
+	{"incapsula incident", "Imperva"},
+	{"_incapsula_resource", "Imperva"},
+	{"sucuri website firewall", "Sucuri"},
+	{"you don't have permission to access", "Akamai"},
+	{"mod_security", "ModSecurity"},
+	{"modsecurity", "ModSecurity"},
+	{"aws waf", "AWS WAF"},
+	{"request blocked", "WAF"},
+	{"access denied", "WAF"},
+	{"captcha", "CAPTCHA"},
+	{"are you a robot", "CAPTCHA"},
+}
+
+// wafHeaders are response headers WAFs set on blocks and challenges.
+var wafHeaders = []struct {
+	name   string
+	source string
+}{
+	{"cf-mitigated", "Cloudflare challenge"},
+	{"x-amzn-waf-action", "AWS WAF"},
+	{"x-sucuri-block", "Sucuri"},
+}
+
+// WAFResponse reports whether resp looks like a WAF block, challenge page
+// or rate limit rather than the application's answer, and describes what
+// gave it away: a 429, a Retry-After header on an error, a WAF header, or
+// a block status whose body carries a known block-page or CAPTCHA marker.
+func WAFResponse(resp *models.HTTPResponse) (string, bool) {
+	if resp == nil || resp.StatusCode < 400 {
+		return "", false
+	}
+
+	for _, h := range wafHeaders {
+		if v := resp.Header(h.name); v != "" {
+			return fmt.Sprintf("%d with %s header %s: %s", resp.StatusCode, h.source, h.name, v), true
+		}
+	}
+	if v := resp.Header("Retry-After"); v != "" {
+		return fmt.Sprintf("%d with Retry-After: %s (rate limited)", resp.StatusCode, v), true
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return "429 Too Many Requests (rate limited)", true
+	}
+	if !wafStatuses[resp.StatusCode] {
+		return "", false
+	}
+
+	body := strings.ToLower(resp.Body)
+	for _, m := range wafBodyMarkers {
+		if strings.Contains(body, m.marker) {
+			return fmt.Sprintf("%d page matching %s marker %q", resp.StatusCode, m.source, m.marker), true
+		}
+	}
+	return "", false
+}
+
+// WAFReason describes a result whose confidence was downgraded because the
+// test response looked like a WAF or rate limit.
+func WAFReason(note string, from, to float64) string {
+	return fmt.Sprintf("WAF/rate limit: %s; confidence downgraded from %.1f%% to %.1f%% and not flagged. "+
+		"Slow down (-rate, -delay) or scan from another source IP", note, from*100, to*100)
+}
@@ -0,0 +1,156 @@
+package detector
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"smuggler/internal/models"
+)
+
+// SignalCodes lists the codes of every signal the analyzers emit.
+var SignalCodes = []string{
+	"backend-changed",
+	"body-changed",
+	"body-shrunk",
+	"cl-added",
+	"connection-closed",
+	"followup-404",
+	"followup-5xx",
+	"followup-status",
+	"malformed-status",
+	"marker-reflected",
+	"response-dual-cl",
+	"response-te-cl",
+	"status-400",
+	"status-5xx",
+	"te-removed",
+	"timing-faster",
+	"timing-slower",
+}
+
+// SignalWeights overrides what detection signals add to the confidence.
+// Keys are signal codes ("status-5xx"), or a technique family and code
+// ("TE.CL:status-5xx") to override one technique only; the latter wins
+// when both match. Signals not listed keep their built-in weight.
+type SignalWeights map[string]float64
+
+// Validate checks that every key names a known signal code and every
+// weight lies in [0, 1].
+func (w SignalWeights) Validate() error {
+	known := make(map[string]bool, len(SignalCodes))
+	for _, c := range SignalCodes {
+		known[c] = true
+	}
+
+	for key, weight := range w {
+		code := key
+		if i := strings.LastIndex(key, ":"); i >= 0 {
+			if i == 0 {
+				return fmt.Errorf("weight %q: empty technique", key)
+			}
+			code = key[i+1:]
+		}
+		if !known[code] {
+			return fmt.Errorf("weight %q: unknown signal code %q (known: %s)", key, code, strings.Join(SignalCodes, ", "))
+		}
+		if math.IsNaN(weight) || weight < 0 || weight > 1 {
+			return fmt.Errorf("weight %q: %v is outside [0, 1]", key, weight)
+		}
+	}
+	return nil
+}
+
+// weight returns the configured weight of code for technique, or def.
+func (w SignalWeights) weight(technique, code string, def float64) float64 {
+	if v, ok := w[TechniqueFamily(technique)+":"+code]; ok {
+		return v
+	}
+	if v, ok := w[code]; ok {
+		return v
+	}
+	return def
+}
+
+// WeightsFile is the content of a weights file: a JSON object mapping
+// signal codes (optionally prefixed with "Technique:") to weights, plus an
+// optional "severity" object mapping techniques to severities, e.g.
+//
+//	{"timing-slower": 0.1, "severity": {"CL.TE": "critical"}}
+type WeightsFile struct {
+	Weights  SignalWeights
+	Severity SeverityOverrides
+}
+
+// LoadWeightsFile reads and validates a weights file.
+func LoadWeightsFile(path string) (*WeightsFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	f := &WeightsFile{Weights: make(SignalWeights, len(raw))}
+	for key, value := range raw {
+		if key == "severity" {
+			var names map[string]string
+			if err := json.Unmarshal(value, &names); err != nil {
+				return nil, fmt.Errorf("%s: severity: %w", path, err)
+			}
+			f.Severity = make(SeverityOverrides, len(names))
+			for technique, name := range names {
+				sev, err := models.ParseSeverity(name)
+				if err != nil {
+					return nil, fmt.Errorf("%s: severity %q: %w", path, technique, err)
+				}
+				f.Severity[technique] = sev
+			}
+			continue
+		}
+
+		var weight float64
+		if err := json.Unmarshal(value, &weight); err != nil {
+			return nil, fmt.Errorf("%s: weight %q: expected a number", path, key)
+		}
+		f.Weights[key] = weight
+	}
+
+	if err := f.Weights.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// LoadSignalWeights reads the signal weights of a weights file (see
+// WeightsFile).
+func LoadSignalWeights(path string) (SignalWeights, error) {
+	f, err := LoadWeightsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.Weights, nil
+}
+
+// SetSignalWeights overrides the built-in signal weights; nil restores
+// them. The weights are not validated here (see SignalWeights.Validate).
+func (d *Detector) SetSignalWeights(w SignalWeights) *Detector {
+	d.weights = w
+	return d
+}
+
+// applyWeights replaces the built-in weights of signals with any
+// configured overrides.
+func (d *Detector) applyWeights(technique string, signals []Signal) {
+	if len(d.weights) == 0 {
+		return
+	}
+	for i := range signals {
+		signals[i].Weight = d.weights.weight(technique, signals[i].Code, signals[i].Weight)
+	}
+}
@@ -0,0 +1,144 @@
+// Package logger prints scan progress at a chosen level of detail and
+// colors verdicts (SUSPICIOUS, CLEAN, UNCLEAR, ...) on terminals, so the
+// CLI and the scanner look the same however they are configured.
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Level is how much a Logger prints. Each level includes the ones below;
+// the zero value is LevelNormal.
+type Level int
+
+const (
+	LevelQuiet   Level = iota - 1 // warnings and the final report only
+	LevelNormal                   // per-step progress (the default)
+	LevelVerbose                  // extra detail, e.g. settings and throughput
+	LevelDebug                    // full payload and response dumps
+)
+
+// ANSI escape sequences used for verdicts.
+const (
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// verdictPattern matches a verdict word with its marker on either side,
+// or a lone ✗/✓ marker.
+var verdictPattern = regexp.MustCompile(`(?:[✗✓~] )?\b(?:SUSPICIOUS|VULNERABLE|CRITICAL|CLEAN|UNCLEAR|BLOCKED|WAF|POSSIBLE)\b(?: [✗✓~])?|[✗✓]`)
+
+// verdictColor picks the color for a verdictPattern match.
+func verdictColor(match string) string {
+	for _, word := range []string{"SUSPICIOUS", "VULNERABLE", "CRITICAL"} {
+		if strings.Contains(match, word) {
+			return colorRed
+		}
+	}
+	for _, word := range []string{"UNCLEAR", "BLOCKED", "WAF", "POSSIBLE"} {
+		if strings.Contains(match, word) {
+			return colorYellow
+		}
+	}
+	if match == "✗" {
+		return colorRed
+	}
+	return colorGreen
+}
+
+// Logger writes leveled, optionally colored output to a writer.
+type Logger struct {
+	w     io.Writer
+	level Level
+	color bool
+}
+
+// New returns a Logger printing to w up to level. color enables ANSI
+// colors and should only be set when w is a terminal (see IsTerminal).
+func New(w io.Writer, level Level, color bool) *Logger {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &Logger{w: w, level: level, color: color}
+}
+
+// SetOutput sets where the logger writes; nil restores stdout.
+func (l *Logger) SetOutput(w io.Writer) {
+	if w == nil {
+		w = os.Stdout
+	}
+	l.w = w
+}
+
+// SetLevel sets how much is printed.
+func (l *Logger) SetLevel(level Level) {
+	l.level = level
+}
+
+// SetColor enables or disables ANSI colors.
+func (l *Logger) SetColor(color bool) {
+	l.color = color
+}
+
+// Writer returns the underlying writer.
+func (l *Logger) Writer() io.Writer {
+	return l.w
+}
+
+// Enabled reports whether messages at level are printed.
+func (l *Logger) Enabled(level Level) bool {
+	return l.level >= level
+}
+
+// Logf prints a message if level is enabled.
+func (l *Logger) Logf(level Level, format string, args ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	io.WriteString(l.w, l.Colorize(fmt.Sprintf(format, args...)))
+}
+
+// Printf prints at every level, including quiet: warnings, dry-run
+// payloads and reports.
+func (l *Logger) Printf(format string, args ...interface{}) {
+	l.Logf(LevelQuiet, format, args...)
+}
+
+// Infof prints per-step progress, hidden by quiet.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.Logf(LevelNormal, format, args...)
+}
+
+// Verbosef prints detail shown with -v.
+func (l *Logger) Verbosef(format string, args ...interface{}) {
+	l.Logf(LevelVerbose, format, args...)
+}
+
+// Debugf prints dumps shown with -vv.
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.Logf(LevelDebug, format, args...)
+}
+
+// Colorize colors the verdicts in s: red for SUSPICIOUS, VULNERABLE and
+// CRITICAL, green for CLEAN, yellow for UNCLEAR, BLOCKED, WAF and POSSIBLE.
+// s is returned unchanged when color is off.
+func (l *Logger) Colorize(s string) string {
+	if !l.color {
+		return s
+	}
+	return verdictPattern.ReplaceAllStringFunc(s, func(match string) string {
+		return verdictColor(match) + match + colorReset
+	})
+}
+
+// IsTerminal reports whether f is a terminal rather than a file or pipe.
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
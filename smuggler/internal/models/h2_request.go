@@ -0,0 +1,37 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ---------- HTTP/2 REQUEST ----------
+
+// H2Request is an HTTP/2 request described field by field. Headers are sent
+// exactly as given, so a content-length or transfer-encoding header can
+// contradict the DATA actually sent; that mismatch is what HTTP/2 downgrade
+// smuggling relies on.
+type H2Request struct {
+	Method    string
+	Scheme    string
+	Authority string
+	Path      string
+	Headers   [][2]string // regular header fields, in order
+	Body      string
+}
+
+// String renders the request for display, one field per line, with the
+// body after a blank line.
+func (r *H2Request) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, ":method: %s\n", r.Method)
+	fmt.Fprintf(&b, ":scheme: %s\n", r.Scheme)
+	fmt.Fprintf(&b, ":authority: %s\n", r.Authority)
+	fmt.Fprintf(&b, ":path: %s\n", r.Path)
+	for _, h := range r.Headers {
+		fmt.Fprintf(&b, "%s: %s\n", h[0], h[1])
+	}
+	b.WriteString("\n")
+	b.WriteString(r.Body)
+	return b.String()
+}
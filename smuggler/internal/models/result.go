@@ -3,6 +3,8 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"time"
 )
@@ -15,21 +17,226 @@ type HTTPResponse struct {
 
 	StatusCode int `json:"status_code,omitempty"`
 
-	Headers map[string]string `json:"headers,omitempty"`
-
-	Body string `json:"body,omitempty"`
+	// StatusLine is the raw first line, split into Proto ("HTTP/1.1") and
+	// Reason ("Bad Request") when it is a well-formed status line.
+	// MalformedStatus is set when it carries no numeric status code, or
+	// does not start with "HTTP/" at all; StatusCode is then left at 0.
+	StatusLine      string `json:"status_line,omitempty"`
+	Proto           string `json:"proto,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	MalformedStatus bool   `json:"malformed_status,omitempty"`
+
+	// Headers maps each header name, as the server spelled it, to every
+	// value it was sent with, so repeated headers (Set-Cookie, or a
+	// duplicated Transfer-Encoding) are all kept. Header returns a single
+	// value regardless of case.
+	Headers map[string][]string `json:"headers,omitempty"`
+
+	// HeaderOrder lists header names in the order the server emitted them,
+	// which fingerprints the backend that produced the response.
+	HeaderOrder []string `json:"header_order,omitempty"`
+
+	// Body is the message content; chunked bodies are decoded, with any
+	// trailer fields in Trailers. MalformedChunking is set when a chunked
+	// body could not be decoded, in which case Body is left as received.
+	Body              string            `json:"body,omitempty"`
+	Trailers          map[string]string `json:"trailers,omitempty"`
+	MalformedChunking bool              `json:"malformed_chunking,omitempty"`
 
 	TimingMS int64 `json:"timing_ms,omitempty"`
 
+	// Timing phases: TCP connect, TLS handshake, and time from the request
+	// being written to the first response byte.
+	ConnectMS int64 `json:"connect_ms,omitempty"`
+	TLSMS     int64 `json:"tls_ms,omitempty"`
+	TTFBMS    int64 `json:"ttfb_ms,omitempty"`
+
+	// TLS parameters negotiated on the connection, empty for plain HTTP:
+	// protocol version, cipher suite, ALPN protocol, and the subject CN
+	// and SANs of the server certificate.
+	TLSVersion  string   `json:"tls_version,omitempty"`
+	CipherSuite string   `json:"cipher_suite,omitempty"`
+	ALPN        string   `json:"alpn,omitempty"`
+	PeerCN      string   `json:"peer_cn,omitempty"`
+	PeerSANs    []string `json:"peer_sans,omitempty"`
+
+	// FirstByteTimeout is set when the connection stayed open but no byte
+	// arrived within the first-byte deadline: connected but hanging, as
+	// opposed to slow but responding.
+	FirstByteTimeout bool `json:"first_byte_timeout,omitempty"`
+
 	ConnectionClosed bool `json:"connection_closed,omitempty"`
 
+	// Truncated is set when Raw or Body was cut to the retention limit.
+	Truncated bool `json:"truncated,omitempty"`
+
+	// Extra holds further responses that arrived behind this one in the
+	// same read, in order: typically the back-end's answer to a smuggled
+	// request. Raw and Body cover this response only.
+	Extra []*HTTPResponse `json:"extra_responses,omitempty"`
+
 	Error error `json:"-"`
 
 	ErrorString string `json:"error,omitempty"`
 }
 
+// TimingBreakdown formats the timing phases of the response.
+func (r *HTTPResponse) TimingBreakdown() string {
+	if r.FirstByteTimeout {
+		return fmt.Sprintf("connect=%dms tls=%dms ttfb=timeout total=%dms",
+			r.ConnectMS, r.TLSMS, r.TimingMS)
+	}
+	return fmt.Sprintf("connect=%dms tls=%dms ttfb=%dms total=%dms",
+		r.ConnectMS, r.TLSMS, r.TTFBMS, r.TimingMS)
+}
+
+// TLSSummary formats the negotiated TLS parameters, or returns "" for a
+// plain HTTP response.
+func (r *HTTPResponse) TLSSummary() string {
+	if r.TLSVersion == "" {
+		return ""
+	}
+	s := r.TLSVersion + " " + r.CipherSuite
+	if r.ALPN != "" {
+		s += " alpn=" + r.ALPN
+	}
+	if r.PeerCN != "" {
+		s += " cn=" + r.PeerCN
+	}
+	if len(r.PeerSANs) > 0 {
+		s += " san=" + strings.Join(r.PeerSANs, ",")
+	}
+	return s
+}
+
+// DefaultStoreResponseBytes is how many bytes of Raw and Body are kept on
+// stored responses unless configured otherwise.
+const DefaultStoreResponseBytes = 8 * 1024
+
+// Truncate returns a copy of the response with Raw and Body cut to at most
+// n bytes each; n of zero drops them entirely. Extra responses are cut the
+// same way. A negative n, or a response already within the limit, is
+// returned as is. The copy is marked Truncated so reports show that detail
+// was dropped.
+func (r *HTTPResponse) Truncate(n int) *HTTPResponse {
+	if r == nil || n < 0 || (len(r.Raw) <= n && len(r.Body) <= n && len(r.Extra) == 0) {
+		return r
+	}
+
+	c := *r
+	if len(c.Raw) > n {
+		c.Raw = c.Raw[:n]
+		c.Truncated = true
+	}
+	if len(c.Body) > n {
+		c.Body = c.Body[:n]
+		c.Truncated = true
+	}
+	c.Extra = mapResponses(c.Extra, func(e *HTTPResponse) *HTTPResponse { return e.Truncate(n) })
+	return &c
+}
+
+// mapResponses returns a new slice holding f applied to each response, or
+// nil for an empty list.
+func mapResponses(list []*HTTPResponse, f func(*HTTPResponse) *HTTPResponse) []*HTTPResponse {
+	if len(list) == 0 {
+		return nil
+	}
+	out := make([]*HTTPResponse, len(list))
+	for i, r := range list {
+		out[i] = f(r)
+	}
+	return out
+}
+
+// RedactBody returns a copy of the response with the body replaced by a
+// note of its size, in both Body and Raw, so a report can be shared
+// without the content it exposed. Status line and headers are kept.
+func (r *HTTPResponse) RedactBody() *HTTPResponse {
+	if r == nil || (r.Body == "" && !strings.Contains(r.Raw, "\r\n\r\n") && len(r.Extra) == 0) {
+		return r
+	}
+
+	c := *r
+	note := fmt.Sprintf("[%d body bytes redacted]", len(r.Body))
+	if i := strings.Index(c.Raw, "\r\n\r\n"); i >= 0 && i+4 < len(c.Raw) {
+		c.Raw = c.Raw[:i+4] + note
+	}
+	if c.Body != "" {
+		c.Body = note
+	}
+	c.Extra = mapResponses(c.Extra, (*HTTPResponse).RedactBody)
+	return &c
+}
+
+// Header returns the first value of the named header, matching the name
+// case-insensitively, or "" if it is absent.
+func (r *HTTPResponse) Header(name string) string {
+	if v := r.HeaderValues(name); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// HeaderValues returns every value of the named header, matching the name
+// case-insensitively.
+func (r *HTTPResponse) HeaderValues(name string) []string {
+	var out []string
+	for k, v := range r.Headers {
+		if strings.EqualFold(k, name) {
+			out = append(out, v...)
+		}
+	}
+	return out
+}
+
+// HeaderBlock renders the headers as "Name: value" lines in the order the
+// server sent them, repeating names that had several values. Without a
+// recorded order the names are sorted.
+func (r *HTTPResponse) HeaderBlock() string {
+	order := r.HeaderOrder
+	if len(order) == 0 {
+		for name, values := range r.Headers {
+			for range values {
+				order = append(order, name)
+			}
+		}
+		sort.Strings(order)
+	}
+
+	var b strings.Builder
+	seen := make(map[string]int)
+	for _, name := range order {
+		if values := r.Headers[name]; seen[name] < len(values) {
+			b.WriteString(name + ": " + values[seen[name]] + "\r\n")
+			seen[name]++
+		}
+	}
+	return b.String()
+}
+
 // ---------- SCAN RESULT ----------
 
+// Severity ranks a finding for triage.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// ParseSeverity accepts a severity name in any case.
+func ParseSeverity(s string) (Severity, error) {
+	switch sev := Severity(strings.ToLower(strings.TrimSpace(s))); sev {
+	case SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+		return sev, nil
+	}
+	return "", fmt.Errorf("unknown severity %q (use info, low, medium, high or critical)", s)
+}
+
 // ScanResult represents the final scan result.
 type ScanResult struct {
 	Target     string `json:"target,omitempty"`
@@ -44,11 +251,63 @@ type ScanResult struct {
 	// Backward compatibility field
 	ConfidenceScore float64 `json:"confidence_score,omitempty"`
 
+	// SentPayload is the exact request data that produced TestResponse,
+	// including any pipelined follow-up requests, so the finding can be
+	// reproduced.
+	SentPayload string `json:"sent_payload,omitempty"`
+
+	// Severity is set when the result is recorded (see
+	// detector.Detector.Severity).
+	Severity Severity `json:"severity,omitempty"`
+
 	ResponseTimeDiff int64 `json:"response_time_diff,omitempty"`
 
+	// TestTiming and BaselineTiming are set when timing was sampled
+	// repeatedly; ResponseTimeDiff is then the difference of the medians.
+	TestTiming     *TimingStats `json:"test_timing,omitempty"`
+	BaselineTiming *TimingStats `json:"baseline_timing,omitempty"`
+
+	// Signals are the stable codes of the detection signals that fired;
+	// ReasonCode combines them with the technique into one identifier.
+	Signals    []string `json:"signals,omitempty"`
+	ReasonCode string   `json:"reason_code,omitempty"`
+
 	BaselineResponse *HTTPResponse `json:"baseline_response,omitempty"`
 	TestResponse     *HTTPResponse `json:"test_response,omitempty"`
 
+	// Responses lists, in order, every response a multi-request test read
+	// back: the attack's own response first, then each follow-up.
+	// TestResponse is the one the verdict rests on.
+	Responses []*HTTPResponse `json:"responses,omitempty"`
+
+	// Confirmation runs: each repeat's response and the fraction of repeats
+	// that reproduced the original verdict.
+	ConfirmationRuns []*HTTPResponse `json:"confirmation_runs,omitempty"`
+	Stability        float64         `json:"stability,omitempty"`
+	Flaky            bool            `json:"flaky,omitempty"`
+
+	// Blocked is set when the test response carried a configured block
+	// status, making the result inconclusive rather than clean.
+	Blocked bool `json:"blocked,omitempty"`
+
+	// WAFDetected is set when the test response looked like a WAF block,
+	// challenge page or rate limit rather than the back-end's answer. The
+	// confidence is downgraded instead of flagging the result, and WAFNote
+	// says what was recognized.
+	WAFDetected bool   `json:"waf_detected,omitempty"`
+	WAFNote     string `json:"waf_note,omitempty"`
+
+	// Observations counts how many recorded results collapsed into this one
+	// during deduplication.
+	Observations int `json:"observations,omitempty"`
+
+	// ProbeIndex is the 1-based follow-up probe that showed poisoning, for
+	// tests that send several probes.
+	ProbeIndex int `json:"probe_index,omitempty"`
+
+	// Stalled is set when the watchdog cancelled the target mid-scan.
+	Stalled bool `json:"stalled,omitempty"`
+
 	Thread *ThreadInfo `json:"thread,omitempty"`
 }
 
@@ -113,6 +372,28 @@ func (sr *ScanResult) PrettyString() string {
 	fmt.Fprintf(&b, "Technique: %s\n", sr.Technique)
 	fmt.Fprintf(&b, "Suspicious: %t (confidence %.2f)\n",
 		sr.Suspicious, conf)
+	if sr.Severity != "" {
+		fmt.Fprintf(&b, "Severity: %s\n", sr.Severity)
+	}
+
+	if sr.Stalled {
+		fmt.Fprintf(&b, "Status: target stalled\n")
+	}
+
+	if sr.Blocked {
+		fmt.Fprintf(&b, "Status: blocked (inconclusive)\n")
+	}
+
+	if sr.WAFDetected {
+		fmt.Fprintf(&b, "Status: WAF/rate limit (%s)\n", sr.WAFNote)
+	}
+
+	if sr.Observations > 1 {
+		fmt.Fprintf(&b, "Observed: %d times\n", sr.Observations)
+	}
+	if sr.ProbeIndex > 0 {
+		fmt.Fprintf(&b, "Triggered by probe: %d\n", sr.ProbeIndex)
+	}
 
 	if sr.Reason != "" {
 		fmt.Fprintf(&b, "Reason: %s\n", sr.Reason)
@@ -154,6 +435,9 @@ func (sr *ScanResult) PrettyString() string {
 			sr.TestResponse.TimingMS,
 			sr.TestResponse.ConnectionClosed,
 		)
+		if tlsInfo := sr.TestResponse.TLSSummary(); tlsInfo != "" {
+			fmt.Fprintf(&b, "TLS: %s\n", tlsInfo)
+		}
 	}
 
 	if sr.ResponseTimeDiff != 0 {
@@ -162,6 +446,31 @@ func (sr *ScanResult) PrettyString() string {
 			sr.ResponseTimeDiff)
 	}
 
+	if sr.TestTiming != nil && sr.BaselineTiming != nil {
+		fmt.Fprintf(&b,
+			"Timing samples: test %s, baseline %s\n",
+			sr.TestTiming, sr.BaselineTiming)
+	}
+
+	if len(sr.ConfirmationRuns) > 0 {
+		fmt.Fprintf(&b,
+			"Confirmation: %d runs, stability %.0f%%",
+			len(sr.ConfirmationRuns), sr.Stability*100)
+		if sr.Flaky {
+			b.WriteString(" (flaky)")
+		}
+		fmt.Fprintln(&b)
+
+		for i, run := range sr.ConfirmationRuns {
+			if run == nil {
+				continue
+			}
+			fmt.Fprintf(&b,
+				"  Run %d: status=%d time=%dms conn_closed=%t\n",
+				i+1, run.StatusCode, run.TimingMS, run.ConnectionClosed)
+		}
+	}
+
 	return b.String()
 }
 
@@ -169,6 +478,36 @@ func (sr *ScanResult) Print() {
 	fmt.Print(sr.PrettyString())
 }
 
+// ---------- STACK INFO ----------
+
+// StackInfo collects fingerprint observations about the target's HTTP stack.
+type StackInfo struct {
+	Server string `json:"server,omitempty"`
+
+	// Pipelining probe: whether n benign pipelined requests on one
+	// connection all came back cleanly.
+	PipelineTested    bool `json:"pipeline_tested,omitempty"`
+	PipelineSupported bool `json:"pipeline_supported,omitempty"`
+	PipelineResponses int  `json:"pipeline_responses,omitempty"`
+
+	// Method routing probe: "same" when GET and POST baselines come from
+	// the same back-end, "split" when their fingerprints differ.
+	MethodRouting string `json:"method_routing,omitempty"`
+	PostServer    string `json:"post_server,omitempty"`
+
+	// ALPN is the protocol the server picked when offered h2 and
+	// http/1.1; "h2" means the front-end speaks HTTP/2.
+	ALPN string `json:"alpn,omitempty"`
+}
+
+// HealthCheck records the post-scan check that the target still answers a
+// clean request the way it did before testing.
+type HealthCheck struct {
+	Healthy  bool          `json:"healthy"`
+	Detail   string        `json:"detail,omitempty"`
+	Response *HTTPResponse `json:"response,omitempty"`
+}
+
 // ---------- REQUEST CONFIG ----------
 
 type RequestConfig struct {
@@ -186,8 +525,18 @@ type BaselineComparison struct {
 	OldStatusCode     int
 	NewStatusCode     int
 
+	// MalformedStatusAppeared is set when the test response has a
+	// non-numeric status line and the baseline did not.
+	MalformedStatusAppeared bool
+	NewStatusLine           string
+
 	TimingDiffMS int64
 
+	// BaselineTiming is set when the baseline was sampled repeatedly and
+	// TestTiming when the test was too (see TimingSeparated).
+	BaselineTiming *TimingStats
+	TestTiming     *TimingStats
+
 	ConnectionBehaviorChanged bool
 	OldConnectionClosed       bool
 	NewConnectionClosed       bool
@@ -196,8 +545,88 @@ type BaselineComparison struct {
 	HeadersRemoved  map[string]string
 	HeadersModified map[string]string
 
+	// HeaderOrderChanged is set when headers present in both responses
+	// appear in a different order. BackendChanged combines this with a
+	// changed Server header as evidence a different backend answered.
+	HeaderOrderChanged bool
+	BackendChanged     bool
+
 	BodySizeDiff int
 	BodyChanged  bool
 
 	Changes []string
 }
+
+// TimingSeparated reports whether the test timing differs from the baseline
+// by more than the noise in either. With only baseline samples, a single
+// test timing must lie three standard deviations from the baseline median.
+// Without samples it is always true, so single-shot comparisons rely on
+// TimingDiffMS alone.
+func (c *BaselineComparison) TimingSeparated() bool {
+	if c.BaselineTiming == nil {
+		return true
+	}
+	diff := math.Abs(float64(c.TimingDiffMS))
+	if c.TestTiming == nil {
+		return diff > 3*c.BaselineTiming.StdDevMS
+	}
+	return diff > 2*(c.BaselineTiming.StdDevMS+c.TestTiming.StdDevMS)
+}
+
+// ---------- BASELINE SAMPLES ----------
+
+// BaselineSamples records repeated baseline requests. Outliers are samples
+// whose timing fell outside 1.5 interquartile ranges of the rest; they are
+// kept in the per-sample slices but excluded from Timing and the medians.
+type BaselineSamples struct {
+	StatusCodes []int   `json:"status_codes"`
+	TimingsMS   []int64 `json:"timings_ms"`
+	BodySizes   []int   `json:"body_sizes"`
+	Outliers    []bool  `json:"outliers"`
+
+	MedianStatus   int          `json:"median_status"`
+	MedianBodySize int          `json:"median_body_size"`
+	Timing         *TimingStats `json:"timing"`
+}
+
+// ---------- TIMING STATS ----------
+
+// TimingStats summarizes repeated timing measurements of one request.
+type TimingStats struct {
+	SamplesMS []int64 `json:"samples_ms"`
+	MedianMS  int64   `json:"median_ms"`
+	StdDevMS  float64 `json:"stddev_ms"`
+}
+
+// NewTimingStats computes the median and standard deviation of samples.
+func NewTimingStats(samples []int64) *TimingStats {
+	ts := &TimingStats{SamplesMS: append([]int64(nil), samples...)}
+	if len(samples) == 0 {
+		return ts
+	}
+
+	sorted := append([]int64(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		ts.MedianMS = (sorted[mid-1] + sorted[mid]) / 2
+	} else {
+		ts.MedianMS = sorted[mid]
+	}
+
+	var sum float64
+	for _, v := range samples {
+		sum += float64(v)
+	}
+	mean := sum / float64(len(samples))
+	var sq float64
+	for _, v := range samples {
+		sq += (float64(v) - mean) * (float64(v) - mean)
+	}
+	ts.StdDevMS = math.Sqrt(sq / float64(len(samples)))
+	return ts
+}
+
+func (ts *TimingStats) String() string {
+	return fmt.Sprintf("median %d ms, stddev %.1f ms (n=%d)", ts.MedianMS, ts.StdDevMS, len(ts.SamplesMS))
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"net"
+	"strconv"
+)
+
+// ---------- TARGET ----------
+
+// Target identifies the endpoint being scanned. HostHeader and SNI override
+// the Host header and TLS server name; when empty both derive from Host.
+type Target struct {
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	TLS        bool   `json:"tls,omitempty"`
+	HostHeader string `json:"host_header,omitempty"`
+	SNI        string `json:"sni,omitempty"`
+}
+
+// NewTarget creates a Target for host:port.
+func NewTarget(host string, port int, useTLS bool) *Target {
+	return &Target{Host: host, Port: port, TLS: useTLS}
+}
+
+// Addr returns the dialable host:port, bracketing IPv6 hosts.
+func (t *Target) Addr() string {
+	return net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+}
+
+// HostHeaderValue returns the Host header value. The port is omitted when
+// it is the default for the scheme (80 for HTTP, 443 for HTTPS), as
+// RFC 9110 recommends; IPv6 hosts are bracketed.
+func (t *Target) HostHeaderValue() string {
+	if t.HostHeader != "" {
+		return t.HostHeader
+	}
+	if (t.TLS && t.Port == 443) || (!t.TLS && t.Port == 80) {
+		if ip := net.ParseIP(t.Host); ip != nil && ip.To4() == nil {
+			return "[" + t.Host + "]"
+		}
+		return t.Host
+	}
+	return t.Addr()
+}
+
+// ServerName returns the TLS SNI value, defaulting to Host.
+func (t *Target) ServerName() string {
+	if t.SNI != "" {
+		return t.SNI
+	}
+	return t.Host
+}
+
+func (t *Target) String() string {
+	return t.Addr()
+}
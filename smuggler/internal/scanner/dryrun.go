@@ -0,0 +1,76 @@
+package scanner
+
+import (
+	"fmt"
+	"strings"
+
+	"smuggler/internal/logger"
+	"smuggler/internal/models"
+)
+
+// SetDryRun makes the scan print each technique's payload instead of
+// sending it. No baseline is captured and no connection is opened, so
+// payloads can be inspected, diffed or pasted into another tool offline.
+func (sc *Scanner) SetDryRun(dryRun bool) *Scanner {
+	sc.dryRun = dryRun
+	return sc
+}
+
+// printDryRun prints payload for test with control bytes made visible and
+// its size, so Content-Length arithmetic can be checked by eye.
+func (sc *Scanner) printDryRun(test, payload string) {
+	sc.printPayload(logger.LevelQuiet, "dry-run", test+" payload", payload)
+}
+
+// printPayload prints raw bytes at level under a "[label] what" heading,
+// with control bytes made visible and the size before and after the
+// header block.
+func (sc *Scanner) printPayload(level logger.Level, label, what, payload string) {
+	if !sc.log.Enabled(level) {
+		return
+	}
+
+	sc.log.Logf(level, "\n    [%s] %s (%d bytes", label, what, len(payload))
+	if i := strings.Index(payload, "\r\n\r\n"); i >= 0 {
+		sc.log.Logf(level, ", %d after the headers", len(payload)-i-4)
+	}
+	sc.log.Logf(level, "):\n")
+
+	for _, line := range strings.SplitAfter(visiblePayload(payload), "\n") {
+		if line != "" {
+			sc.log.Logf(level, "      | %s", line)
+		}
+	}
+	if !strings.HasSuffix(payload, "\n") {
+		sc.log.Logf(level, "\n")
+	}
+}
+
+// debugResponse dumps a raw response under -vv.
+func (sc *Scanner) debugResponse(what string, resp *models.HTTPResponse) {
+	if resp == nil || resp.Raw == "" {
+		return
+	}
+	sc.printPayload(logger.LevelDebug, "debug", what, resp.Raw)
+}
+
+// visiblePayload escapes CR, LF, tabs and other non-printable bytes, and
+// breaks the line after each LF so the request keeps its shape.
+func visiblePayload(payload string) string {
+	var b strings.Builder
+	for i := 0; i < len(payload); i++ {
+		switch c := payload[i]; {
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\n':
+			b.WriteString("\\n\n")
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
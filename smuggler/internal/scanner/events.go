@@ -0,0 +1,76 @@
+package scanner
+
+import (
+	"io"
+	"time"
+
+	"smuggler/internal/detector"
+	"smuggler/internal/models"
+)
+
+// EventPhase identifies what a ScanEvent reports.
+type EventPhase string
+
+const (
+	// PhaseLog carries one chunk of the human-readable progress output in
+	// Message, already filtered by the log level.
+	PhaseLog EventPhase = "log"
+	// PhaseBaseline reports the captured baseline response.
+	PhaseBaseline EventPhase = "baseline"
+	// PhaseTechniqueStart is sent before a technique test runs.
+	PhaseTechniqueStart EventPhase = "technique-start"
+	// PhaseTechniqueResult carries each result as it is recorded.
+	PhaseTechniqueResult EventPhase = "technique-result"
+	// PhaseReport carries the final report.
+	PhaseReport EventPhase = "report"
+)
+
+// ScanEvent is a progress update. Target, Phase and Time are always set;
+// the other fields depend on the phase.
+type ScanEvent struct {
+	Phase     EventPhase
+	Target    string
+	Time      time.Time
+	Technique string                    // technique-start, technique-result
+	Message   string                    // log
+	Baseline  *models.HTTPResponse      // baseline
+	Result    *models.ScanResult        // technique-result
+	Report    *detector.DetectionReport // report
+}
+
+// SetProgressHandler routes progress through handler instead of printing
+// it: every line the scanner would print arrives as a PhaseLog event, and
+// the structured phases arrive as they happen. nil restores printing to
+// the output set with SetOutput. With a watchdog (SetWatchdog) the handler
+// may also be called from the watchdog's goroutine.
+func (sc *Scanner) SetProgressHandler(handler func(ScanEvent)) *Scanner {
+	sc.progressHandler = handler
+	return sc
+}
+
+// emit sends ev to the progress handler. Without one, log events are
+// written to the output, unless running silently for ScanResults, and the
+// structured phases are dropped, since their text is already part of the
+// log.
+func (sc *Scanner) emit(ev ScanEvent) {
+	ev.Target = sc.target.Addr()
+	ev.Time = time.Now()
+
+	if sc.progressHandler != nil {
+		sc.progressHandler(ev)
+		return
+	}
+	if ev.Phase == PhaseLog && !sc.silent {
+		io.WriteString(sc.out, ev.Message)
+	}
+}
+
+// eventWriter turns the scanner's log output into PhaseLog events.
+type eventWriter struct {
+	sc *Scanner
+}
+
+func (w eventWriter) Write(p []byte) (int, error) {
+	w.sc.emit(ScanEvent{Phase: PhaseLog, Message: string(p)})
+	return len(p), nil
+}
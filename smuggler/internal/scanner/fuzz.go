@@ -0,0 +1,128 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"smuggler/internal/detector"
+	"smuggler/internal/payload/mutate"
+)
+
+// FuzzTechniques are the techniques TestFuzz can mutate.
+var FuzzTechniques = []string{detector.TechCLTE, detector.TechTECL, detector.TechTETE, detector.TechDualCL}
+
+// DefaultFuzzVariants is how many variants TestFuzz sends when asked for
+// zero or fewer.
+const DefaultFuzzVariants = 25
+
+// SetFuzz makes the scan finish with TestFuzz on technique, one of
+// FuzzTechniques, sending at most maxVariants mutations. "" turns fuzzing
+// off.
+func (sc *Scanner) SetFuzz(technique string, maxVariants int) *Scanner {
+	sc.fuzzTechnique = technique
+	sc.fuzzVariants = maxVariants
+	return sc
+}
+
+// fuzzBase builds the unmutated payload of technique and returns it with
+// the name its results are recorded under and its analyzer.
+func (sc *Scanner) fuzzBase(technique string) (string, string, analyzeFunc, error) {
+	gen := sc.newGenerator()
+	gen.SetPath("/")
+	gen.AddHeader("Connection", "close")
+
+	var (
+		name    string
+		raw     string
+		analyze analyzeFunc
+		err     error
+	)
+	switch technique {
+	case detector.TechCLTE:
+		name, analyze = "CL.TE", sc.detector.AnalyzeCLTE
+		raw, err = gen.GenerateCLTEPayload(sc.smuggledRequest("/admin"))
+	case detector.TechTECL:
+		name, analyze = "TE.CL", sc.detector.AnalyzeTECL
+		raw, err = gen.GenerateTECLPayload(sc.smuggledRequest("/api"))
+	case detector.TechTETE:
+		name, analyze = "TE.TE", sc.detector.AnalyzeTETE
+		raw, err = gen.GenerateTETEPayload(sc.smuggledRequest("/secret"))
+	case detector.TechDualCL:
+		name, analyze = "CL.CL", sc.detector.AnalyzeDualCL
+		smuggled := sc.smuggledRequest("/admin")
+		raw, err = gen.GenerateDualCLPayload(smuggled, len(smuggled), 0)
+	default:
+		return "", "", nil, fmt.Errorf("cannot fuzz %q (valid: %s)", technique, strings.Join(FuzzTechniques, ", "))
+	}
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%s payload generation failed: %w", name, err)
+	}
+	return raw, name, analyze, nil
+}
+
+// TestFuzz sends up to maxVariants mutations of technique's payload (see
+// package mutate): framing headers with their name case flipped, the
+// whitespace around the colon changed, their order swapped or their value
+// folded onto a continuation line. Each variant is recorded as its own
+// result named after the exact mutations ("CL.TE[fuzz:te-upper+cl-tab]").
+// maxVariants is capped at mutate.MaxVariants; zero or less sends
+// DefaultFuzzVariants. The loop stops at the first high-confidence finding
+// unless SetExhaustive is on.
+func (sc *Scanner) TestFuzz(technique string, maxVariants int) error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+
+	raw, name, analyze, err := sc.fuzzBase(technique)
+	if err != nil {
+		return err
+	}
+	if maxVariants <= 0 {
+		maxVariants = DefaultFuzzVariants
+	}
+	variants := mutate.Generate(raw, maxVariants)
+
+	sc.log.Infof("\n[*] Fuzzing %s header formatting (%d variants)...\n", name, len(variants))
+
+	var lastErr error
+	var flagged []string
+	failed := 0
+	for _, v := range variants {
+		if sc.ctx.Err() != nil {
+			break
+		}
+
+		variant := fmt.Sprintf("%s[fuzz:%s]", name, v.Name)
+		sc.heartbeat(variant)
+		sc.log.Infof("    [%s]\n", v.Name)
+
+		result, err := sc.runTechnique(variant, v.Payload, analyze)
+		if err != nil {
+			if errors.Is(err, ErrStepAborted) {
+				return err
+			}
+			sc.log.Printf("    [!] %v\n", err)
+			lastErr = err
+			failed++
+			continue
+		}
+		if result == nil || !result.Suspicious {
+			continue
+		}
+		flagged = append(flagged, v.Name)
+
+		if !sc.exhaustive && result.GetConfidence() >= highConfidence {
+			sc.log.Infof("    High-confidence hit on %s; skipping remaining variants (use -exhaustive to try all)\n", v.Name)
+			break
+		}
+	}
+
+	if len(flagged) > 0 {
+		sc.log.Printf("    Mutations with desync signals: %s\n", strings.Join(flagged, ", "))
+	}
+	if len(variants) > 0 && failed == len(variants) {
+		return lastErr
+	}
+	return nil
+}
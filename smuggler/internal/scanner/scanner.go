@@ -2,12 +2,19 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"smuggler/internal/ai"
 	"smuggler/internal/baseline"
 	"smuggler/internal/detector"
+	"smuggler/internal/logger"
 	"smuggler/internal/models"
 	"smuggler/internal/payload"
 	"smuggler/internal/sender"
@@ -15,29 +22,109 @@ import (
 
 // Scanner orchestrates the entire HTTP request smuggling detection workflow.
 type Scanner struct {
-	target           string
-	port             int
+	target           *models.Target
 	sender           *sender.RawSender
 	baselineManager  *baseline.Manager
 	detector         *detector.Detector
 	aiProvider       ai.Provider
+	aiSuggest        bool
+	aiAdaptive       bool
 	baselineResponse *models.HTTPResponse
+	initialBaseline  *models.HTTPResponse
+	health           *models.HealthCheck
 	results          []*models.ScanResult
 	report           *detector.DetectionReport
+	confirmRuns      int
+	pipelineProbes   int
+	probeCount       int
+	stackInfo        models.StackInfo
+	rng              *rand.Rand
+	resultHandler    func(*models.ScanResult)
+	cl0Paths         []string
+	smuggleMethod    string
+	smugglePath      string
+	smuggleHeaders   map[string]string
+	http2            bool
+	timingSamples    int
+	baselineSamples  int
+	exhaustive       bool
+	chunkSweep       bool
+	chunkExtension   string
+	fuzzTechnique    string
+	fuzzVariants     int
+	wafWarned        bool
+	baselineTiming   *models.TimingStats
+	h2Baseline       *models.HTTPResponse
+	sweepDelay       time.Duration
+	storeBytes       int
+	keepFindings     bool
+	dryRun           bool
+	requestHeaders   map[string]string
+	redactBodies     bool
+
+	enabledTechniques map[string]bool
+	autoTechniques    bool
+	explainTiming     bool
+	compareMethods    bool
+	step              StepFunc
+	out               io.Writer
+	log               *logger.Logger
+	progressHandler   func(ScanEvent)
+	silent            bool
+
+	ctx              context.Context
+	watchdogInterval time.Duration
+	watchdog         *watchdog
 }
 
-// NewScanner creates a new scanner for a target.
-func NewScanner(target string, port int) *Scanner {
+// NewScanner creates a new scanner for a target. The target's TLS flag
+// and SNI are applied to the sender.
+func NewScanner(target *models.Target) *Scanner {
 	s := sender.NewRawSender()
+	s.SetTLS(target.TLS)
+	if target.SNI != "" {
+		s.SetServerName(target.SNI)
+	}
 
-	return &Scanner{
+	sc := &Scanner{
 		target:          target,
-		port:            port,
 		sender:          s,
-		baselineManager: baseline.NewManager(s, target, port),
+		baselineManager: baseline.NewManager(s, target),
 		detector:        detector.NewDetector(),
 		results:         make([]*models.ScanResult, 0),
+		out:             os.Stdout,
+		storeBytes:      models.DefaultStoreResponseBytes,
+		probeCount:      1,
+		ctx:             context.Background(),
+		rng:             rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	sc.log = logger.New(eventWriter{sc}, logger.LevelNormal, false)
+	return sc
+}
+
+// SetOutput sets where progress and the report are printed; nil restores
+// stdout. Concurrent scans give each scanner its own writer so their
+// output does not interleave.
+func (sc *Scanner) SetOutput(w io.Writer) *Scanner {
+	if w == nil {
+		w = os.Stdout
 	}
+	sc.out = w
+	return sc
+}
+
+// SetLogLevel sets how much progress is printed: quiet shows only
+// warnings and the report, and debug adds every payload and response.
+func (sc *Scanner) SetLogLevel(level logger.Level) *Scanner {
+	sc.log.SetLevel(level)
+	return sc
+}
+
+// SetColor colors verdicts in the output; only enable it when the output
+// is a terminal.
+func (sc *Scanner) SetColor(color bool) *Scanner {
+	sc.log.SetColor(color)
+	return sc
 }
 
 // SetConfidenceThreshold sets the detector's confidence threshold.
@@ -48,6 +135,7 @@ func (sc *Scanner) SetConfidenceThreshold(threshold float64) *Scanner {
 
 // SetTLS enables or disables TLS/HTTPS for connections.
 func (sc *Scanner) SetTLS(useTLS bool) *Scanner {
+	sc.target.TLS = useTLS
 	sc.sender.SetTLS(useTLS)
 	return sc
 }
@@ -58,6 +146,488 @@ func (sc *Scanner) SetInsecureTLS(insecure bool) *Scanner {
 	return sc
 }
 
+// SetServerName sends sni as the TLS server name instead of the target
+// host, e.g. to reach a specific virtual host on a shared front-end by IP.
+// Empty restores the default.
+func (sc *Scanner) SetServerName(sni string) *Scanner {
+	sc.target.SNI = sni
+	sc.sender.SetServerName(sni)
+	return sc
+}
+
+// SetHostHeader sends host as the Host header of the baseline and every
+// payload, independently of the address dialed and of the SNI. Empty
+// restores the default.
+func (sc *Scanner) SetHostHeader(host string) *Scanner {
+	sc.target.HostHeader = host
+	return sc
+}
+
+// SetClientCert presents a client certificate to targets requiring mTLS.
+func (sc *Scanner) SetClientCert(certFile, keyFile string) error {
+	return sc.sender.SetClientCert(certFile, keyFile)
+}
+
+// SetCACert verifies the target against a custom root CA instead of the
+// system roots.
+func (sc *Scanner) SetCACert(caFile string) error {
+	return sc.sender.SetCACert(caFile)
+}
+
+// SetConfirmRuns sets how many times a suspicious technique is re-sent to
+// measure how stable the finding is. Zero disables confirmation.
+func (sc *Scanner) SetConfirmRuns(n int) *Scanner {
+	if n < 0 {
+		n = 0
+	}
+	sc.confirmRuns = n
+	return sc
+}
+
+// SetProxy routes all connections through an upstream HTTP proxy.
+func (sc *Scanner) SetProxy(proxyURL string) error {
+	return sc.sender.Dialer().SetProxy(proxyURL)
+}
+
+// SetProxyAuth sets basic credentials for the upstream proxy.
+func (sc *Scanner) SetProxyAuth(user, pass string) *Scanner {
+	sc.sender.Dialer().SetProxyAuth(user, pass)
+	return sc
+}
+
+// AddProxyHeader adds a raw header (e.g. a bearer Proxy-Authorization) to
+// the proxy CONNECT request.
+func (sc *Scanner) AddProxyHeader(header string) error {
+	return sc.sender.Dialer().AddProxyHeader(header)
+}
+
+// SetPipelineBaseline enables a pipelining probe of n benign requests on one
+// connection before the smuggling tests. Zero disables the probe.
+func (sc *Scanner) SetPipelineBaseline(n int) *Scanner {
+	if n < 0 {
+		n = 0
+	}
+	sc.pipelineProbes = n
+	return sc
+}
+
+// SetProbeCount sets how many probes the GPOST test sends after the
+// smuggling payload, stopping at the first that shows poisoning. Values
+// below 1 are raised to 1.
+func (sc *Scanner) SetProbeCount(n int) *Scanner {
+	if n < 1 {
+		n = 1
+	}
+	sc.probeCount = n
+	return sc
+}
+
+// SetRand sets the random source used by every randomized component of the
+// scan (markers, fuzzing, jitter), so a run can be replayed from its seed.
+// The Rand is not safe for concurrent use and must not be shared between
+// scanners running in parallel.
+func (sc *Scanner) SetRand(r *rand.Rand) *Scanner {
+	if r != nil {
+		sc.rng = r
+	}
+	return sc
+}
+
+// Rand returns the scanner's random source.
+func (sc *Scanner) Rand() *rand.Rand {
+	return sc.rng
+}
+
+// SetResultHandler registers a callback invoked with each result as soon as
+// it is recorded, for callers that stream results.
+func (sc *Scanner) SetResultHandler(handler func(*models.ScanResult)) *Scanner {
+	sc.resultHandler = handler
+	return sc
+}
+
+// addResult records a result and notifies the result handler.
+func (sc *Scanner) addResult(result *models.ScanResult) {
+	sc.debugResponse(result.Technique+" response", result.TestResponse)
+	result.Severity = sc.detector.Severity(result)
+	if sc.redactBodies {
+		redactBodies(result)
+	}
+	sc.trimResponses(result)
+	sc.results = append(sc.results, result)
+	if result.WAFDetected && !sc.wafWarned {
+		sc.wafWarned = true
+		sc.log.Printf("    [!] Response looks like a WAF or rate limit (%s); slow down with -rate/-delay or scan from another source IP\n", result.WAFNote)
+	}
+	if sc.resultHandler != nil {
+		sc.resultHandler(result)
+	}
+	sc.emit(ScanEvent{Phase: PhaseTechniqueResult, Technique: result.Technique, Result: result})
+}
+
+// SetStoreResponseBytes limits how many bytes of each response's Raw and
+// Body are kept on recorded results: n > 0 keeps that many, 0 keeps none
+// and a negative n keeps everything. The limit is applied when a result is
+// recorded, so large scans do not hold every full response in memory.
+func (sc *Scanner) SetStoreResponseBytes(n int) *Scanner {
+	sc.storeBytes = n
+	return sc
+}
+
+// SetKeepFindingResponses keeps full responses on suspicious results
+// regardless of the SetStoreResponseBytes limit.
+func (sc *Scanner) SetKeepFindingResponses(keep bool) *Scanner {
+	sc.keepFindings = keep
+	return sc
+}
+
+// trimResponses applies the response retention limit to a result. The
+// responses are copied before being cut, since the baseline is shared
+// with later comparisons.
+func (sc *Scanner) trimResponses(result *models.ScanResult) {
+	if sc.storeBytes < 0 || (sc.keepFindings && result.Suspicious) {
+		return
+	}
+
+	result.BaselineResponse = result.BaselineResponse.Truncate(sc.storeBytes)
+	result.TestResponse = result.TestResponse.Truncate(sc.storeBytes)
+	for i, run := range result.ConfirmationRuns {
+		result.ConfirmationRuns[i] = run.Truncate(sc.storeBytes)
+	}
+	for i, resp := range result.Responses {
+		result.Responses[i] = resp.Truncate(sc.storeBytes)
+	}
+}
+
+// SetRedactBodies replaces response bodies on recorded results with a note
+// of their size, for reports shared outside the team. Status lines,
+// headers and the sent payloads are kept, so findings stay reproducible.
+func (sc *Scanner) SetRedactBodies(redact bool) *Scanner {
+	sc.redactBodies = redact
+	return sc
+}
+
+// redactBodies strips the response bodies from a result. The responses are
+// copied first, like in trimResponses.
+func redactBodies(result *models.ScanResult) {
+	result.BaselineResponse = result.BaselineResponse.RedactBody()
+	result.TestResponse = result.TestResponse.RedactBody()
+	for i, run := range result.ConfirmationRuns {
+		result.ConfirmationRuns[i] = run.RedactBody()
+	}
+	for i, resp := range result.Responses {
+		result.Responses[i] = resp.RedactBody()
+	}
+}
+
+// SetEnabledTechniques restricts the scan to the given technique
+// identifiers (see detector.AllTechniques). An empty list runs everything.
+func (sc *Scanner) SetEnabledTechniques(ids []string) error {
+	ids, err := detector.ParseTechniques(ids)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		sc.enabledTechniques = nil
+		return nil
+	}
+
+	enabled := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		enabled[id] = true
+	}
+	sc.enabledTechniques = enabled
+	return nil
+}
+
+// SetAutoTechniques makes the scan run only the techniques recommended for
+// the fingerprinted stack, chosen after the baseline is captured.
+func (sc *Scanner) SetAutoTechniques(auto bool) *Scanner {
+	sc.autoTechniques = auto
+	return sc
+}
+
+// SetCL0Paths sets the endpoints swept by the CL.0 test. CL.0 is highly
+// endpoint-specific, so a wordlist of static files and redirects is typical.
+func (sc *Scanner) SetCL0Paths(paths []string) *Scanner {
+	sc.cl0Paths = paths
+	return sc
+}
+
+// SetSmuggledRequest sets the victim request smuggled by the CL.TE, TE.CL,
+// TE.TE, Mixed-TE and bare-CR tests. Empty method and path keep each
+// test's default (GET and /admin, /api or /secret); headers are added after
+// Host, sorted by name.
+func (sc *Scanner) SetSmuggledRequest(method, path string, headers map[string]string) *Scanner {
+	sc.smuggleMethod = method
+	sc.smugglePath = path
+	sc.smuggleHeaders = headers
+	return sc
+}
+
+// smuggledRequest builds the smuggled victim request, using defaultPath
+// unless a path was configured.
+func (sc *Scanner) smuggledRequest(defaultPath string) string {
+	method := sc.smuggleMethod
+	if method == "" {
+		method = "GET"
+	}
+	path := sc.smugglePath
+	if path == "" {
+		path = defaultPath
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\nHost: %s\r\n", method, path, sc.target.HostHeaderValue())
+
+	keys := make([]string, 0, len(sc.smuggleHeaders))
+	for k := range sc.smuggleHeaders {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, sc.smuggleHeaders[k])
+	}
+
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// SetTimingSamples sends every technique payload, and the baseline, n times
+// and compares the timing distributions instead of single measurements, so
+// timing signals only fire when the difference exceeds the jitter. Values
+// below 2 restore single-shot timing.
+func (sc *Scanner) SetTimingSamples(n int) *Scanner {
+	sc.timingSamples = n
+	return sc
+}
+
+// SetBaselineSamples captures the baseline n times and uses the median of
+// the non-outlier samples as the reference, so one slow baseline does not
+// skew every timing comparison. Values below 2 send a single baseline.
+func (sc *Scanner) SetBaselineSamples(n int) *Scanner {
+	sc.baselineSamples = n
+	return sc
+}
+
+// SetExhaustive makes multi-variant tests such as Obfuscated-TE send every
+// variant even after one has produced a high-confidence finding.
+func (sc *Scanner) SetExhaustive(exhaustive bool) *Scanner {
+	sc.exhaustive = exhaustive
+	return sc
+}
+
+// SetChunkSweep makes CL.TE and TE.CL also try each chunk layout in
+// payload.ChunkVariants (padded sizes, several chunks, sizes that disagree
+// with the data), since parsers differ in which of them they accept.
+func (sc *Scanner) SetChunkSweep(sweep bool) *Scanner {
+	sc.chunkSweep = sweep
+	return sc
+}
+
+// SetChunkExtension replaces the built-in extensions of the
+// chunk-extension test (payload.ChunkExtensions) with ext, sent as
+// "1;<ext>", so users can fuzz the extension. "" restores the built-in
+// list. The trailer variants run either way.
+func (sc *Scanner) SetChunkExtension(ext string) *Scanner {
+	sc.chunkExtension = ext
+	return sc
+}
+
+// sweepChunks re-runs a chunked technique with each chunk layout,
+// recording the results as technique[layout]. It stops at the first
+// high-confidence finding unless exhaustive.
+func (sc *Scanner) sweepChunks(technique string, gen *payload.Generator, build func(string) (string, error), smuggled string, analyze analyzeFunc) error {
+	defer gen.SetChunkConfig(payload.ChunkConfig{})
+
+	for _, v := range payload.ChunkVariants {
+		if sc.ctx.Err() != nil {
+			break
+		}
+
+		variant := fmt.Sprintf("%s[%s]", technique, v.Name)
+		sc.heartbeat(variant)
+		sc.log.Infof("    [%s] chunked body %q\n", v.Name, v.Config.Body())
+
+		gen.SetChunkConfig(v.Config)
+		payloadStr, err := build(smuggled)
+		if err != nil {
+			return fmt.Errorf("%s payload generation failed: %w", variant, err)
+		}
+
+		result, err := sc.runTechnique(variant, payloadStr, analyze)
+		if err != nil {
+			return err
+		}
+		if !sc.exhaustive && result != nil && result.Suspicious && result.GetConfidence() >= highConfidence {
+			sc.log.Infof("    High-confidence hit on %s; skipping remaining chunk layouts (use -exhaustive to try all)\n", v.Name)
+			break
+		}
+	}
+	return nil
+}
+
+// sampleTiming re-sends payloadStr until n timings (including first) are
+// collected. Failed sends are skipped.
+func (sc *Scanner) sampleTiming(payloadStr string, first *models.HTTPResponse, n int) *models.TimingStats {
+	samples := []int64{first.TimingMS}
+	for i := 1; i < n && sc.ctx.Err() == nil; i++ {
+		resp, err := sc.send(payloadStr)
+		sc.heartbeat("")
+		if err != nil {
+			continue
+		}
+		samples = append(samples, resp.TimingMS)
+	}
+	return models.NewTimingStats(samples)
+}
+
+// baselineTimingStats samples the baseline request's timing once per scan.
+func (sc *Scanner) baselineTimingStats() *models.TimingStats {
+	if sc.baselineTiming == nil {
+		samples := []int64{sc.baselineResponse.TimingMS}
+		for i := 1; i < sc.timingSamples && sc.ctx.Err() == nil; i++ {
+			resp, err := sc.baselineManager.CaptureBaseline()
+			sc.heartbeat("")
+			if err != nil {
+				continue
+			}
+			samples = append(samples, resp.TimingMS)
+		}
+		sc.baselineTiming = models.NewTimingStats(samples)
+		sc.log.Infof("    Baseline timing: %s\n", sc.baselineTiming)
+	}
+	return sc.baselineTiming
+}
+
+// SetHTTP2 enables the HTTP/2 downgrade tests (H2.CL and H2.TE). They need
+// a front-end that speaks HTTP/2, so they are off by default.
+func (sc *Scanner) SetHTTP2(enabled bool) *Scanner {
+	sc.http2 = enabled
+	return sc
+}
+
+// SetSweepDelay sets the pause between requests in path sweeps.
+func (sc *Scanner) SetSweepDelay(d time.Duration) *Scanner {
+	sc.sweepDelay = d
+	return sc
+}
+
+// SetBlockStatuses sets the status codes treated as generic block pages;
+// tests answered with one are reported as blocked instead of analyzed.
+func (sc *Scanner) SetBlockStatuses(codes []int) *Scanner {
+	sc.detector.SetBlockStatuses(codes)
+	return sc
+}
+
+// SetSignalWeights overrides the detector's built-in signal weights; nil
+// restores them.
+func (sc *Scanner) SetSignalWeights(w detector.SignalWeights) *Scanner {
+	sc.detector.SetSignalWeights(w)
+	return sc
+}
+
+// SetSeverityOverrides fixes the severity of suspicious findings for the
+// listed techniques; nil restores the built-in mapping.
+func (sc *Scanner) SetSeverityOverrides(o detector.SeverityOverrides) *Scanner {
+	sc.detector.SetSeverityOverrides(o)
+	return sc
+}
+
+// SetTimeouts sets the connect and read timeouts of the sender; zero keeps
+// the current value.
+func (sc *Scanner) SetTimeouts(connect, read time.Duration) *Scanner {
+	sc.sender.SetConnectTimeout(connect)
+	sc.sender.SetReadTimeout(read)
+	return sc
+}
+
+// SetFirstByteTimeout bounds the wait for the first response byte
+// separately from the full read timeout, so a target that accepts the
+// request but never starts answering is classified quickly.
+func (sc *Scanner) SetFirstByteTimeout(d time.Duration) *Scanner {
+	sc.sender.SetFirstByteTimeout(d)
+	return sc
+}
+
+// SetContext bounds the scan by ctx: once it is done, sends in flight are
+// aborted and the remaining tests fail. nil restores context.Background.
+func (sc *Scanner) SetContext(ctx context.Context) *Scanner {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	sc.ctx = ctx
+	return sc
+}
+
+// SetWatchdog enables a stall watchdog: if no test completes within interval
+// a warning is logged, and after a second interval the target is cancelled.
+// Zero disables the watchdog.
+func (sc *Scanner) SetWatchdog(interval time.Duration) *Scanner {
+	sc.watchdogInterval = interval
+	return sc
+}
+
+// IgnoreHeaders excludes extra headers from baseline header comparison.
+func (sc *Scanner) IgnoreHeaders(names ...string) *Scanner {
+	sc.baselineManager.IgnoreHeaders(names...)
+	return sc
+}
+
+// CompareHeaders forces comparison of headers that are ignored by default
+// as volatile (see baseline.DefaultVolatileHeaders).
+func (sc *Scanner) CompareHeaders(names ...string) *Scanner {
+	sc.baselineManager.CompareHeaders(names...)
+	return sc
+}
+
+// SetStats feeds the scanner's connection and request counts into stats.
+func (sc *Scanner) SetStats(stats *sender.Stats) *Scanner {
+	sc.sender.SetStats(stats)
+	return sc
+}
+
+// SetLimiter throttles every request the scanner sends (see
+// sender.RawSender.SetLimiter). Pass the same Limiter to concurrent
+// scanners to bound their total rate.
+func (sc *Scanner) SetLimiter(l *sender.Limiter) *Scanner {
+	sc.sender.SetLimiter(l)
+	return sc
+}
+
+// SetCompareBaselineMethod adds a preflight comparing GET and POST
+// baselines to detect method-based routing (see TestMethodRouting).
+func (sc *Scanner) SetCompareBaselineMethod(compare bool) *Scanner {
+	sc.compareMethods = compare
+	return sc
+}
+
+// SetResolver shares a DNS resolver (with its cache and pins) with the
+// scanner's dialer.
+func (sc *Scanner) SetResolver(r *sender.Resolver) *Scanner {
+	sc.sender.Dialer().SetResolver(r)
+	return sc
+}
+
+// SetExplainTiming prints the connect/TLS/TTFB breakdown of each response,
+// so timing-based findings can be told apart from slow handshakes.
+func (sc *Scanner) SetExplainTiming(explain bool) *Scanner {
+	sc.explainTiming = explain
+	return sc
+}
+
+// printTiming prints the timing breakdown of resp when enabled.
+func (sc *Scanner) printTiming(resp *models.HTTPResponse) {
+	if sc.explainTiming && resp != nil {
+		sc.log.Infof("    Timing breakdown: %s\n", resp.TimingBreakdown())
+	}
+}
+
+// SetReadBufferSize sets the response read buffer size used by the sender.
+func (sc *Scanner) SetReadBufferSize(n int) *Scanner {
+	sc.sender.SetReadBufferSize(n)
+	return sc
+}
+
 // SetAIAnalyzer sets an AI analyzer for intelligent response analysis.
 func (sc *Scanner) SetAIAnalyzer(analyzer *ai.AIAnalyzer) *Scanner {
 	sc.aiProvider = analyzer
@@ -70,377 +640,1581 @@ func (sc *Scanner) SetAIProvider(provider ai.Provider) *Scanner {
 	return sc
 }
 
+// SetRequestHeaders sets extra headers (User-Agent, Cookie, Authorization,
+// ...) sent with the baseline and every test request, so authenticated
+// areas can be tested. They go on the outer requests only; the smuggled
+// request is configured with SetSmuggledRequest.
+func (sc *Scanner) SetRequestHeaders(headers map[string]string) *Scanner {
+	sc.requestHeaders = headers
+	sc.baselineManager.SetHeaders(headers)
+	return sc
+}
+
+// newGenerator returns a payload generator for the target carrying the
+// configured request headers.
+func (sc *Scanner) newGenerator() *payload.Generator {
+	return payload.NewGenerator(sc.target).AddHeaders(sc.requestHeaders)
+}
+
+// requireBaseline fails unless a baseline was captured. A dry run needs
+// none, since nothing is sent to compare.
+func (sc *Scanner) requireBaseline() error {
+	if sc.baselineResponse == nil && !sc.dryRun {
+		return fmt.Errorf("baseline not captured; call CaptureBaseline first")
+	}
+	return nil
+}
+
 // CaptureBaseline sends a normal request to establish baseline behavior.
 func (sc *Scanner) CaptureBaseline() error {
-	fmt.Printf("[*] Capturing baseline response for %s:%d\n", sc.target, sc.port)
+	sc.log.Infof("[*] Capturing baseline response for %s\n", sc.target.Addr())
 
-	resp, err := sc.baselineManager.CaptureBaseline()
+	var resp *models.HTTPResponse
+	var samples *models.BaselineSamples
+	var err error
+	if sc.baselineSamples > 1 {
+		resp, samples, err = sc.baselineManager.CaptureBaselineN(sc.baselineSamples)
+	} else {
+		resp, err = sc.baselineManager.CaptureBaseline()
+	}
 	if err != nil {
 		return fmt.Errorf("baseline capture failed: %w", err)
 	}
 
 	sc.baselineResponse = resp
-	fmt.Printf("    Status: %d | Timing: %d ms | Headers: %d | Body: %d bytes\n",
+	sc.initialBaseline = resp
+	sc.log.Infof("    Status: %d | Timing: %d ms | Headers: %d | Body: %d bytes\n",
 		resp.StatusCode, resp.TimingMS, len(resp.Headers), len(resp.Body))
+	if samples != nil {
+		outliers := 0
+		for _, o := range samples.Outliers {
+			if o {
+				outliers++
+			}
+		}
+		sc.log.Infof("    Samples: %d | Timing %s | %d outliers discarded\n",
+			len(samples.TimingsMS), samples.Timing, outliers)
+		if len(samples.Timing.SamplesMS) > 1 {
+			sc.baselineTiming = samples.Timing
+		}
+	}
+	sc.printTiming(resp)
+	sc.debugResponse("baseline response", resp)
+	sc.emit(ScanEvent{Phase: PhaseBaseline, Baseline: resp})
+
+	sc.stackInfo.Server = resp.Header("Server")
+	if tlsInfo := resp.TLSSummary(); tlsInfo != "" {
+		sc.log.Verbosef("    TLS: %s\n", tlsInfo)
+	}
+
+	if sc.target.TLS {
+		alpn, err := sc.sender.ProbeALPN(sc.ctx, sc.target.Addr())
+		if err != nil {
+			sc.log.Verbosef("    ALPN probe failed: %v\n", err)
+		} else {
+			sc.stackInfo.ALPN = alpn
+			if alpn == "h2" {
+				sc.log.Infof("    Server negotiates HTTP/2 (ALPN h2)\n")
+			}
+		}
+	}
 
 	return nil
 }
 
-// TestCLTE tests for CL.TE vulnerability.
-func (sc *Scanner) TestCLTE() error {
-	if sc.baselineResponse == nil {
-		return fmt.Errorf("baseline not captured; call CaptureBaseline first")
+// TestMethodRouting captures a POST baseline and compares its fingerprint
+// with the GET baseline. Front-ends that route methods to different
+// back-ends make every POST-based probe look like a backend change against
+// a GET baseline, so when the routes diverge the POST baseline replaces it
+// and techniques are judged against the back-end they actually reach.
+func (sc *Scanner) TestMethodRouting() error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
 	}
 
-	fmt.Printf("\n[*] Testing CL.TE (Content-Length / Transfer-Encoding)...\n")
-
-	gen := payload.NewGenerator(sc.target, sc.port)
-	gen.SetPath("/")
-	gen.AddHeader("Connection", "close")
+	sc.log.Infof("\n[*] Comparing GET and POST baselines for method-based routing...\n")
 
-	payloadStr, err := gen.GenerateCLTEPayload("GET /admin HTTP/1.1\r\nHost: " + sc.target + "\r\n\r\n")
+	post, err := sc.baselineManager.CaptureMethodBaseline("POST")
 	if err != nil {
-		return fmt.Errorf("CL.TE payload generation failed: %w", err)
+		return fmt.Errorf("method routing check failed: %w", err)
 	}
+	sc.log.Infof("    POST Status: %d | Timing: %d ms | Headers: %d | Body: %d bytes\n",
+		post.StatusCode, post.TimingMS, len(post.Headers), len(post.Body))
+	sc.printTiming(post)
 
-	targetAddr := fmt.Sprintf("%s:%d", sc.target, sc.port)
-	testResp, err := sc.sender.SendRequest(targetAddr, payloadStr)
-	if err != nil {
-		return fmt.Errorf("CL.TE test send failed: %w", err)
+	sc.stackInfo.PostServer = post.Header("Server")
+
+	comparison := sc.baselineManager.CompareResponses(sc.baselineResponse, post)
+	if !comparison.BackendChanged {
+		sc.stackInfo.MethodRouting = "same"
+		sc.log.Infof("    Result: GET and POST reach the same back-end\n")
+		return nil
 	}
 
-	fmt.Printf("    Response: %d | Timing: %d ms\n", testResp.StatusCode, testResp.TimingMS)
+	sc.stackInfo.MethodRouting = "split"
+	sc.baselineResponse = post
+	sc.log.Infof("    Result: GET and POST reach different back-ends (GET server %q, POST server %q)\n",
+		sc.stackInfo.Server, sc.stackInfo.PostServer)
+	sc.log.Infof("    Techniques will be compared against the POST baseline\n")
 
-	comparison := sc.baselineManager.CompareResponses(sc.baselineResponse, testResp)
-	result := sc.detector.AnalyzeCLTE(sc.target, comparison)
+	return nil
+}
 
-	// Run AI analysis if provider available
-	if sc.aiProvider != nil {
-		sc.runAIAnalysis("CL.TE", sc.baselineResponse, testResp, result)
+// TestPipelineBaseline pipelines n benign requests on one connection and
+// checks that all n responses come back and match the baseline status. The
+// outcome is stored on StackInfo; when pipelining fails, tests that rely on
+// connection reuse are skipped.
+func (sc *Scanner) TestPipelineBaseline(n int) error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+	if n < 2 {
+		n = 2
 	}
 
-	sc.results = append(sc.results, result)
+	sc.log.Infof("\n[*] Testing connection reuse (%d pipelined requests)...\n", n)
 
-	fmt.Printf("    Result: %s\n", func() string {
-		if result.Suspicious {
-			return "SUSPICIOUS ✗"
+	gen := sc.newGenerator()
+	payloads := make([]string, n)
+	for i := 0; i < n-1; i++ {
+		payloads[i] = gen.GenerateBaselineKeepAlive()
+	}
+	payloads[n-1] = gen.GenerateBaseline()
+
+	targetAddr := sc.target.Addr()
+	responses, err := sc.sender.SendPipelinedContext(sc.ctx, targetAddr, payloads)
+	if err != nil {
+		return fmt.Errorf("pipeline baseline send failed: %w", err)
+	}
+
+	clean := 0
+	for _, r := range responses {
+		if r.StatusCode == sc.baselineResponse.StatusCode {
+			clean++
 		}
-		return "CLEAN ✓"
-	}())
+	}
+
+	sc.stackInfo.PipelineTested = true
+	sc.stackInfo.PipelineResponses = clean
+	sc.stackInfo.PipelineSupported = clean == n
+
+	if sc.stackInfo.PipelineSupported {
+		sc.log.Infof("    All %d responses matched baseline; connection reuse works\n", n)
+	} else {
+		sc.log.Infof("    Only %d/%d responses matched baseline; connection reuse unavailable\n", clean, n)
+	}
+
+	return nil
+}
+
+// GetStackInfo returns the fingerprint observations collected so far.
+func (sc *Scanner) GetStackInfo() models.StackInfo {
+	return sc.stackInfo
+}
+
+// TestCLTE tests for CL.TE vulnerability.
+func (sc *Scanner) TestCLTE() error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+
+	sc.log.Infof("\n[*] Testing CL.TE (Content-Length / Transfer-Encoding)...\n")
+
+	gen := sc.newGenerator()
+	gen.SetPath("/")
+	gen.AddHeader("Connection", "close")
+
+	smuggled := sc.smuggledRequest("/admin")
+	payloadStr, err := gen.GenerateCLTEPayload(smuggled)
+	if err != nil {
+		return fmt.Errorf("CL.TE payload generation failed: %w", err)
+	}
+
+	_, err = sc.runTechnique("CL.TE", payloadStr, sc.detector.AnalyzeCLTE)
+	if err != nil || !sc.chunkSweep {
+		return err
+	}
+	return sc.sweepChunks("CL.TE", gen, gen.GenerateCLTEPayload, smuggled, sc.detector.AnalyzeCLTE)
+}
+
+// TestBareCR sends a CL.TE payload whose chunked terminator ends in a lone
+// CR (see payload.GenerateCLTEBareCR) and compares the response with the
+// baseline. It runs as part of the obfuscation sweep, since a bare CR only
+// matters for the unusual lenient parsers that sweep targets.
+func (sc *Scanner) TestBareCR() error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+
+	sc.log.Infof("\n[*] Testing bare-CR line terminator...\n")
+
+	gen := sc.newGenerator()
+	gen.SetPath("/")
+	gen.AddHeader("Connection", "close")
+
+	payloadStr, err := gen.GenerateBareCRPayload(sc.smuggledRequest("/admin"))
+	if err != nil {
+		return fmt.Errorf("Bare-CR payload generation failed: %w", err)
+	}
+	if bareCR, bareLF := payload.LineTerminatorAnomalies(payloadStr); bareCR != 1 || bareLF != 0 {
+		return fmt.Errorf("Bare-CR payload lint failed: %d bare CR, %d bare LF (want 1, 0)", bareCR, bareLF)
+	}
+
+	_, err = sc.runTechnique("Bare-CR", payloadStr, sc.detector.AnalyzeCLTE)
+	return err
+}
+
+// TestSegmentedDelivery sends the CL.TE payload twice: once in a single
+// write and once with the headers and body in separate TCP segments. The
+// whole-delivery response is the reference for the segmented one, so only
+// differences caused by how the front-end and back-end reassemble the
+// stream are flagged.
+func (sc *Scanner) TestSegmentedDelivery() error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+
+	sc.log.Infof("\n[*] Testing segmented delivery (headers and body in separate TCP segments)...\n")
+
+	gen := sc.newGenerator()
+	gen.SetPath("/")
+	gen.AddHeader("Connection", "close")
+
+	payloadStr, err := gen.GenerateCLTEPayload(sc.smuggledRequest("/admin"))
+	if err != nil {
+		return fmt.Errorf("segmented payload generation failed: %w", err)
+	}
+	if send, err := sc.approve("Segmented[CL.TE]", payloadStr); !send {
+		return err
+	}
+
+	// Split after the request line, at the end of the headers, and after
+	// the terminating chunk so each parser sees a partial message.
+	headerEnd := strings.Index(payloadStr, "\r\n\r\n") + 4
+	points := []int{strings.Index(payloadStr, "\r\n") + 2, headerEnd}
+	if i := strings.Index(payloadStr[headerEnd:], "0\r\n\r\n"); i != -1 {
+		points = append(points, headerEnd+i+5)
+	}
+
+	sc.log.Infof("    [1] Sending payload in a single write...\n")
+	whole, err := sc.send(payloadStr)
+	if undelivered(err) {
+		sc.recordUndelivered("Segmented[CL.TE]", payloadStr, whole, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("whole delivery send failed: %w", err)
+	}
+	sc.log.Infof("        Response: %d | Timing: %d ms\n", whole.StatusCode, whole.TimingMS)
+	sc.printTiming(whole)
+
+	sc.log.Infof("    [2] Sending payload in %d segments...\n", len(points)+1)
+	sc.sender.SetSplitWrite(points)
+	segmented, err := sc.send(payloadStr)
+	sc.sender.SetSplitWrite(nil)
+	if undelivered(err) {
+		sc.recordUndelivered("Segmented[CL.TE]", payloadStr, segmented, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("segmented delivery send failed: %w", err)
+	}
+	sc.log.Infof("        Response: %d | Timing: %d ms\n", segmented.StatusCode, segmented.TimingMS)
+	sc.printTiming(segmented)
+
+	comparison := sc.baselineManager.CompareResponses(whole, segmented)
+	result := sc.detector.AnalyzeCLTE(sc.target.Host, comparison)
+	result.Technique = "Segmented[CL.TE]"
+	result.SentPayload = payloadStr
+
+	if sc.aiProvider != nil {
+		sc.runAIAnalysis(result.Technique, whole, segmented, result)
+	}
+
+	sc.addResult(result)
+
+	sc.log.Infof("    Result: %s\n", func() string {
+		if result.Suspicious {
+			return "SUSPICIOUS ✗ (segmentation changes how the payload is parsed)"
+		}
+		if result.Blocked {
+			return "BLOCKED ~ (inconclusive)"
+		}
+		if result.WAFDetected {
+			return "WAF ~ (inconclusive, confidence downgraded)"
+		}
+		return "CLEAN ✓"
+	}())
+
+	return nil
+}
+
+// undelivered reports whether a send failed because the target cut the
+// request short (closing, resetting or no longer reading the connection
+// while it was written) rather than being unreachable. The payload itself
+// may have caused that, so the test is recorded rather than ending the
+// scan.
+func undelivered(err error) bool {
+	return errors.Is(err, sender.ErrWrite) ||
+		(errors.Is(err, sender.ErrTimeout) && !errors.Is(err, sender.ErrNoResponse))
+}
+
+// send sends payloadStr to the target. A request that was delivered but
+// got no response is not an error here: a read timeout or a silent close
+// is exactly what the timing-based tests look for, so the empty response
+// is returned for analysis.
+func (sc *Scanner) send(payloadStr string) (*models.HTTPResponse, error) {
+	resp, err := sc.sender.SendRequestContext(sc.ctx, sc.target.Addr(), payloadStr)
+	if errors.Is(err, sender.ErrNoResponse) {
+		sc.log.Verbosef("    No response: %v\n", err)
+		return resp, nil
+	}
+	return resp, err
+}
+
+// recordUndelivered records an inconclusive result for a test whose
+// request the target cut short.
+func (sc *Scanner) recordUndelivered(technique, payloadStr string, resp *models.HTTPResponse, err error) *models.ScanResult {
+	sc.log.Infof("    Result: UNCLEAR ~ (%v)\n", err)
+
+	result := &models.ScanResult{
+		Target:           sc.target.Host,
+		Technique:        technique,
+		Reason:           fmt.Sprintf("Request not fully delivered (%s): %v", sender.KindOf(err), err),
+		SentPayload:      payloadStr,
+		BaselineResponse: sc.baselineResponse,
+		TestResponse:     resp,
+	}
+	sc.addResult(result)
+	return result
+}
+
+// analyzeFunc is a detector entry point for a single technique.
+type analyzeFunc func(target string, comparison *models.BaselineComparison) *models.ScanResult
+
+// runTechnique sends a technique payload, compares the response against the
+// baseline, runs confirmation and AI analysis, and records the result.
+func (sc *Scanner) runTechnique(technique, payloadStr string, analyze analyzeFunc) (*models.ScanResult, error) {
+	if send, err := sc.approve(technique, payloadStr); !send {
+		return nil, err
+	}
+
+	testResp, err := sc.send(payloadStr)
+	if undelivered(err) {
+		return sc.recordUndelivered(technique, payloadStr, testResp, err), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s test send failed: %w", technique, err)
+	}
+
+	sc.log.Infof("    Response: %d | Timing: %d ms\n", testResp.StatusCode, testResp.TimingMS)
+	sc.printTiming(testResp)
+
+	comparison := sc.baselineManager.CompareResponses(sc.baselineResponse, testResp)
+	if sc.timingSamples > 1 {
+		baseStats := sc.baselineTimingStats()
+		testStats := sc.sampleTiming(payloadStr, testResp, sc.timingSamples)
+		sc.log.Infof("    Timing: %s\n", testStats)
+		sc.baselineManager.ApplyTimingStats(comparison, baseStats, testStats)
+	}
+	result := analyze(sc.target.Host, comparison)
+	result.Technique = technique
+	result.SentPayload = payloadStr
+
+	if result.Suspicious && sc.confirmRuns > 0 {
+		sc.confirmResult(result, payloadStr, analyze)
+	}
+
+	// Run AI analysis if provider available
+	if sc.aiProvider != nil {
+		sc.runAIAnalysis(technique, sc.baselineResponse, testResp, result)
+	}
+
+	sc.addResult(result)
+
+	sc.log.Infof("    Result: %s\n", func() string {
+		if result.Suspicious {
+			return "SUSPICIOUS ✗"
+		}
+		if result.Flaky {
+			return "FLAKY ~"
+		}
+		if result.Blocked {
+			return "BLOCKED ~ (inconclusive)"
+		}
+		if result.WAFDetected {
+			return "WAF ~ (inconclusive, confidence downgraded)"
+		}
+		return "CLEAN ✓"
+	}())
+
+	return result, nil
+}
+
+// confirmResult re-sends a payload that looked suspicious and records how
+// consistently the repeats reproduce the original verdict and status code.
+// The finding is kept only if a strict majority of the repeats are flagged
+// suspicious too, so a single 503 or GC pause does not make a target look
+// vulnerable. A repeat that fails to send is retried once; if it fails
+// again it is inconclusive and left out of the vote rather than counted
+// as a clean response.
+func (sc *Scanner) confirmResult(result *models.ScanResult, payloadStr string, analyze analyzeFunc) {
+	consistent := 0
+	flagged := 0
+	inconclusive := 0
+
+	for i := 0; i < sc.confirmRuns && sc.ctx.Err() == nil; i++ {
+		resp, err := sc.send(payloadStr)
+		if err != nil && sc.ctx.Err() == nil {
+			resp, err = sc.send(payloadStr)
+		}
+		sc.heartbeat("")
+		if err != nil {
+			sc.log.Printf("    [!] Confirmation run %d inconclusive: %v\n", i+1, err)
+			inconclusive++
+			continue
+		}
+		result.ConfirmationRuns = append(result.ConfirmationRuns, resp)
+
+		comparison := sc.baselineManager.CompareResponses(sc.baselineResponse, resp)
+		repeat := analyze(sc.target.Host, comparison)
+
+		if repeat.Suspicious {
+			flagged++
+		}
+		if repeat.Suspicious == result.Suspicious &&
+			resp.StatusCode == result.TestResponse.StatusCode {
+			consistent++
+		}
+	}
+
+	completed := len(result.ConfirmationRuns)
+	var note string
+	if inconclusive > 0 {
+		note = fmt.Sprintf(", %d inconclusive (send failed)", inconclusive)
+	}
+	if completed == 0 {
+		sc.log.Infof("    Confirmation: no run completed%s\n", note)
+		result.Reason = fmt.Sprintf("Unconfirmed: none of %d confirmation runs could be sent (inconclusive)\n%s",
+			sc.confirmRuns, result.Reason)
+		return
+	}
+
+	result.Stability = float64(consistent) / float64(completed)
+	sc.log.Infof("    Confirmation: %d/%d runs flagged, %d/%d consistent (stability %.0f%%)%s\n",
+		flagged, completed, consistent, completed, result.Stability*100, note)
+
+	if 2*flagged <= completed {
+		result.Suspicious = false
+		result.Flaky = true
+		result.Reason = fmt.Sprintf(
+			"Flaky: only %d/%d confirmation runs reproduced the finding%s\n%s",
+			flagged, completed, note, result.Reason,
+		)
+		return
+	}
+
+	result.Reason = fmt.Sprintf("Confirmed %d/%d%s: %s", flagged, completed, note, result.Reason)
+}
+
+// aiBodySnippetBytes is how much of each response body is shown to the AI
+// provider; the provider's prompt cap may cut it further.
+const aiBodySnippetBytes = 1024
+
+// bodySnippet returns the first n bytes of body, marking the cut.
+func bodySnippet(body string, n int) string {
+	if len(body) <= n {
+		return body
+	}
+	return body[:n] + "\n[... truncated]"
+}
+
+// runAIAnalysis calls the AI provider to analyze a test result
+func (sc *Scanner) runAIAnalysis(testType string, baseline, test *models.HTTPResponse, result *models.ScanResult) {
+	baseline_map := map[string]interface{}{
+		"status":       baseline.StatusCode,
+		"body_len":     len(baseline.Body),
+		"timing":       baseline.TimingMS,
+		"header_count": len(baseline.Headers),
+		"headers":      baseline.HeaderBlock(),
+		"body":         bodySnippet(baseline.Body, aiBodySnippetBytes),
+	}
+
+	test_map := map[string]interface{}{
+		"status":       test.StatusCode,
+		"body_len":     len(test.Body),
+		"timing":       test.TimingMS,
+		"header_count": len(test.Headers),
+		"headers":      test.HeaderBlock(),
+		"body":         bodySnippet(test.Body, aiBodySnippetBytes),
+	}
+
+	aiResult, err := sc.aiProvider.AnalyzeResponses(sc.ctx, baseline_map, test_map, testType)
+	if err != nil {
+		sc.log.Infof("    [AI Analysis Error: %v]\n", err)
+		return
+	}
+
+	if aiResult != nil && aiResult.Confidence > 0 {
+		sc.log.Infof("\n    [AI Analysis - %s]\n", sc.aiProvider.Name())
+		sc.log.Infof("    Confidence: %.1f%%\n", aiResult.Confidence*100)
+		sc.log.Infof("    Reasoning: %s\n", aiResult.Reasoning)
+		if len(aiResult.SuspiciousSignals) > 0 {
+			sc.log.Infof("    Signals: %v\n", aiResult.SuspiciousSignals)
+		}
+		if len(aiResult.Recommendations) > 0 {
+			sc.log.Infof("    Next Steps: %v\n", aiResult.Recommendations)
+		}
+
+		// Update result with AI confidence if higher
+		if aiResult.Confidence > result.ConfidenceScore {
+			result.ConfidenceScore = aiResult.Confidence
+			result.Confidence = aiResult.Confidence
+		}
+		if aiResult.IsVulnerable && !result.Suspicious {
+			result.Suspicious = true
+		}
+	}
+}
+
+// TestTECL tests for TE.CL vulnerability.
+func (sc *Scanner) TestTECL() error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+
+	sc.log.Infof("\n[*] Testing TE.CL (Transfer-Encoding / Content-Length)...\n")
+
+	gen := sc.newGenerator()
+	gen.SetPath("/")
+	gen.AddHeader("Connection", "close")
+
+	smuggled := sc.smuggledRequest("/api")
+	payloadStr, err := gen.GenerateTECLPayload(smuggled)
+	if err != nil {
+		return fmt.Errorf("TE.CL payload generation failed: %w", err)
+	}
+
+	_, err = sc.runTechnique("TE.CL", payloadStr, sc.detector.AnalyzeTECL)
+	if err != nil || !sc.chunkSweep {
+		return err
+	}
+	return sc.sweepChunks("TE.CL", gen, gen.GenerateTECLPayload, smuggled, sc.detector.AnalyzeTECL)
+}
+
+// TestMixedTE tests for Mixed Transfer-Encoding header exploitation.
+func (sc *Scanner) TestMixedTE() error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+
+	sc.log.Infof("\n[*] Testing Mixed-TE (Multiple Transfer-Encoding headers)...\n")
+
+	payloadStr := payload.WithHeaders(fmt.Sprintf(
+		"GET / HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n"+
+			"Transfer-Encoding: identity\r\n"+
+			"Transfer-Encoding: chunked\r\nContent-Length: 5\r\n\r\n"+
+			"0\r\n\r\n%s",
+		sc.target.HostHeaderValue(), sc.smuggledRequest("/secret")), sc.requestHeaders)
+
+	_, err := sc.runTechnique("Mixed-TE", payloadStr, sc.detector.AnalyzeMixedTE)
+	return err
+}
+
+// TestTETE tests for TE.TE desync: both servers honor Transfer-Encoding,
+// but a duplicate header with a space before the colon makes one of them
+// ignore it.
+func (sc *Scanner) TestTETE() error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+
+	sc.log.Infof("\n[*] Testing TE.TE (Transfer-Encoding / obfuscated Transfer-Encoding)...\n")
+
+	gen := sc.newGenerator()
+	gen.SetPath("/")
+	gen.AddHeader("Connection", "close")
+
+	payloadStr, err := gen.GenerateTETEPayload(sc.smuggledRequest("/secret"))
+	if err != nil {
+		return fmt.Errorf("TE.TE payload generation failed: %w", err)
+	}
+
+	_, err = sc.runTechnique("TE.TE", payloadStr, sc.detector.AnalyzeTETE)
+	return err
+}
+
+// TestDualCL tests for CL.CL desync: two conflicting Content-Length
+// headers, the first covering the smuggled request and the second zero, so
+// servers that pick different ones disagree on where the body ends.
+func (sc *Scanner) TestDualCL() error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+
+	sc.log.Infof("\n[*] Testing CL.CL (conflicting duplicate Content-Length)...\n")
+
+	gen := sc.newGenerator()
+	gen.SetPath("/")
+	gen.AddHeader("Connection", "close")
+
+	smuggled := sc.smuggledRequest("/admin")
+	payloadStr, err := gen.GenerateDualCLPayload(smuggled, len(smuggled), 0)
+	if err != nil {
+		return fmt.Errorf("CL.CL payload generation failed: %w", err)
+	}
+
+	_, err = sc.runTechnique("CL.CL", payloadStr, sc.detector.AnalyzeDualCL)
+	return err
+}
+
+// highConfidence is the confidence at which a multi-variant test stops
+// early, since further variants add little once the target is flagged.
+const highConfidence = 0.8
+
+// TestObfuscatedTE tests for obfuscated Transfer-Encoding header exploitation.
+// Each obfuscation in payload.ObfuscationPatterns and each byte-level
+// variant in payload.WhitespaceObfuscations is sent separately and
+// recorded as its own result (e.g. "Obfuscated-TE[cow]" or
+// "Obfuscated-TE[vtab-prefix]"), so the report
+// attributes a finding to the exact obfuscation that broke the target.
+// The loop stops at the first high-confidence finding unless SetExhaustive
+// is on.
+func (sc *Scanner) TestObfuscatedTE() error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+
+	sc.log.Infof("\n[*] Testing Obfuscated-TE (Transfer-Encoding with non-standard values)...\n")
+
+	gen := sc.newGenerator()
+	gen.SetPath("/")
+	gen.AddHeader("Connection", "close")
+
+	smuggled := "POST / HTTP/1.1\r\nHost: " + sc.target.HostHeaderValue() + "\r\nContent-Type: application/x-www-form-urlencoded\r\nContent-Length: 15\r\n\r\nx=1"
+
+	type variant struct {
+		name    string
+		display string
+		build   func() (string, error)
+	}
+	var variants []variant
+	for _, obfuscation := range payload.ObfuscationPatterns {
+		obfuscation := obfuscation
+		variants = append(variants, variant{
+			name:    obfuscation,
+			display: "Transfer-Encoding: " + obfuscation,
+			build:   func() (string, error) { return gen.GenerateObfuscatedTEPayload(smuggled, obfuscation) },
+		})
+	}
+	for _, ws := range payload.WhitespaceObfuscations {
+		ws := ws
+		variants = append(variants, variant{
+			name:    ws.Name,
+			display: fmt.Sprintf("Transfer-Encoding:%q", ws.Value()),
+			build:   func() (string, error) { return gen.GenerateWhitespaceObfuscatedTEPayload(smuggled, ws) },
+		})
+	}
+
+	var lastErr error
+	failed := 0
+	for _, v := range variants {
+		if sc.ctx.Err() != nil {
+			break
+		}
+
+		technique := fmt.Sprintf("Obfuscated-TE[%s]", v.name)
+		sc.heartbeat(technique)
+		sc.log.Infof("    [%s] %s\n", v.name, v.display)
+
+		payloadStr, err := v.build()
+		if err != nil {
+			return fmt.Errorf("%s payload generation failed: %w", technique, err)
+		}
+
+		result, err := sc.runTechnique(technique, payloadStr, sc.detector.AnalyzeObfuscatedTE)
+		if err != nil {
+			if errors.Is(err, ErrStepAborted) {
+				return err
+			}
+			sc.log.Printf("    [!] %v\n", err)
+			lastErr = err
+			failed++
+			continue
+		}
+
+		if !sc.exhaustive && result != nil && result.Suspicious && result.GetConfidence() >= highConfidence {
+			sc.log.Infof("    High-confidence hit on %s; skipping remaining obfuscations (use -exhaustive to try all)\n", v.name)
+			break
+		}
+	}
+
+	if failed == len(variants) {
+		return lastErr
+	}
+	return nil
+}
+
+// TestChunkExtension sends CL.TE-framed requests whose chunked body
+// carries a chunk extension (payload.ChunkExtensions, or the one set with
+// SetChunkExtension) or a trailer section (payload.ChunkTrailers). Each
+// variant is recorded as its own result ("Chunk-Ext[bare-lf]"), and the
+// loop stops at the first high-confidence finding unless SetExhaustive is
+// on.
+func (sc *Scanner) TestChunkExtension() error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+
+	sc.log.Infof("\n[*] Testing chunk extensions and trailers...\n")
+
+	gen := sc.newGenerator()
+	gen.SetPath("/")
+	gen.AddHeader("Connection", "close")
+
+	smuggled := sc.smuggledRequest("/admin")
+
+	type variant struct {
+		name    string
+		display string
+		build   func() (string, error)
+	}
+	extensions := payload.ChunkExtensions
+	if sc.chunkExtension != "" {
+		extensions = []payload.ChunkVariant{{Name: "custom", Config: payload.ChunkConfig{
+			Chunks: []payload.Chunk{{Data: "x", Extension: sc.chunkExtension}},
+		}}}
+	}
+	var variants []variant
+	for _, ext := range extensions {
+		extension := ext.Config.Chunks[0].Extension
+		display := fmt.Sprintf("%q", "1;"+extension)
+		if len(extension) > 64 {
+			display = fmt.Sprintf("%q... (%d bytes)", "1;"+extension[:64], len(extension))
+		}
+		variants = append(variants, variant{
+			name:    ext.Name,
+			display: display,
+			build:   func() (string, error) { return gen.GenerateChunkExtensionPayload(smuggled, extension) },
+		})
+	}
+	for _, tr := range payload.ChunkTrailers {
+		trailer := tr.Config.Trailers[0]
+		variants = append(variants, variant{
+			name:    tr.Name,
+			display: "0\\r\\n" + trailer,
+			build:   func() (string, error) { return gen.GenerateTrailerPayload(smuggled, trailer) },
+		})
+	}
+
+	var lastErr error
+	failed := 0
+	for _, v := range variants {
+		if sc.ctx.Err() != nil {
+			break
+		}
+
+		technique := fmt.Sprintf("Chunk-Ext[%s]", v.name)
+		sc.heartbeat(technique)
+		sc.log.Infof("    [%s] %s\n", v.name, v.display)
+
+		payloadStr, err := v.build()
+		if err != nil {
+			return fmt.Errorf("%s payload generation failed: %w", technique, err)
+		}
+
+		result, err := sc.runTechnique(technique, payloadStr, sc.detector.AnalyzeChunkExtension)
+		if err != nil {
+			if errors.Is(err, ErrStepAborted) {
+				return err
+			}
+			sc.log.Printf("    [!] %v\n", err)
+			lastErr = err
+			failed++
+			continue
+		}
+
+		if !sc.exhaustive && result != nil && result.Suspicious && result.GetConfidence() >= highConfidence {
+			sc.log.Infof("    High-confidence hit on %s; skipping remaining variants (use -exhaustive to try all)\n", v.name)
+			break
+		}
+	}
+
+	if failed == len(variants) {
+		return lastErr
+	}
+	return nil
+}
+
+func (sc *Scanner) TestCLTE_GPOST() error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+
+	sc.log.Infof("\n[*] Testing CL.TE GPOST poisoning (multi-request attack)...\n")
+
+	if sc.stackInfo.PipelineTested && !sc.stackInfo.PipelineSupported {
+		sc.log.Infof("    Skipped: target does not reuse connections\n")
+		return nil
+	}
+
+	targetAddr := sc.target.Addr()
+
+	smugglePayload := payload.WithHeaders(payload.CL_TE_GPOST_ATTACK(sc.target), sc.requestHeaders)
+	if send, err := sc.approve("CL.TE-GPOST", smugglePayload); !send {
+		return err
+	}
+
+	// The smuggled "G" prefix is left on the connection to the back-end,
+	// so the probe goes out on the same socket to be contaminated by it.
+	conn, err := sc.sender.OpenConnContext(sc.ctx, targetAddr)
+	if err != nil {
+		return fmt.Errorf("smuggling payload send failed: %w", err)
+	}
+	defer conn.Close()
+
+	sc.log.Infof("    [1] Sending smuggling payload...\n")
+	resp1, err := conn.SendContext(sc.ctx, smugglePayload)
+	if err != nil {
+		return fmt.Errorf("smuggling payload send failed: %w", err)
+	}
+	sc.log.Infof("        Response: %d | Timing: %d ms\n", resp1.StatusCode, resp1.TimingMS)
+	sc.printTiming(resp1)
+
+	// The poisoned prefix may be picked up by another client's request
+	// first, so keep probing until one shows the indicator.
+	probePayload := payload.WithHeaders(payload.ProbeRequestAfterPoison(sc.target), sc.requestHeaders)
+	var resp2 *models.HTTPResponse
+	responses := []*models.HTTPResponse{resp1}
+	var suspicious, blocked bool
+	var reason, reasonCode string
+	probeIndex := 0
+
+	for i := 1; i <= sc.probeCount && sc.ctx.Err() == nil; i++ {
+		sc.log.Infof("    [2] Sending probe %d/%d after smuggling...\n", i, sc.probeCount)
+		var resp *models.HTTPResponse
+		if !conn.Closed() {
+			resp, err = conn.SendContext(sc.ctx, probePayload)
+		}
+		if conn.Closed() && (resp == nil || errors.Is(err, sender.ErrConnClosed)) {
+			// the front-end may still route a new connection to the poisoned
+			// back-end connection, so probe anyway
+			sc.log.Infof("        Server closed the connection; probing on a new one\n")
+			resp, err = sc.send(probePayload)
+		}
+		if err != nil {
+			if resp2 == nil {
+				return fmt.Errorf("probe request send failed: %w", err)
+			}
+			sc.log.Printf("        [!] probe %d failed: %v\n", i, err)
+			break
+		}
+		resp2 = resp
+		responses = append(responses, resp)
+		sc.log.Infof("        Response: %d | Timing: %d ms\n", resp2.StatusCode, resp2.TimingMS)
+		sc.printTiming(resp2)
+
+		sc.log.Infof("    [3] Analyzing probe %d response for poisoning...\n", i)
+
+		var probeBlocked bool
+		suspicious, probeBlocked, reason, reasonCode = sc.classifyGPOSTProbe(resp2)
+		blocked = blocked || probeBlocked
+		if suspicious {
+			probeIndex = i
+			if sc.probeCount > 1 {
+				reason = fmt.Sprintf("Probe %d of %d: %s", i, sc.probeCount, reason)
+			}
+			break
+		}
+	}
+	if resp2 == nil {
+		return sc.ctx.Err()
+	}
+	wafNote, waf := detector.WAFResponse(resp2)
+	if waf && reasonCode == "followup-status" {
+		// A status change from a WAF or rate limit says nothing about
+		// whether the back-end connection was poisoned.
+		suspicious, probeIndex = false, 0
+		reasonCode = "waf"
+		reason = "WAF/rate limit: " + wafNote + "; slow down (-rate, -delay) or scan from another source IP"
+	}
+	if !suspicious && blocked {
+		reasonCode = "blocked"
+		reason = detector.BlockedReason(resp2.StatusCode)
+	}
+
+	result := &models.ScanResult{
+		Target:           sc.target.Host,
+		Technique:        "CL.TE-GPOST",
+		Suspicious:       suspicious,
+		Blocked:          blocked && !suspicious,
+		Reason:           reason,
+		ProbeIndex:       probeIndex,
+		ResponseTimeDiff: resp2.TimingMS - sc.baselineResponse.TimingMS,
+		BaselineResponse: sc.baselineResponse,
+		TestResponse:     resp2,
+		Responses:        responses,
+		SentPayload:      smugglePayload + probePayload,
+	}
+	if reasonCode != "" {
+		result.Signals = []string{reasonCode}
+		result.ReasonCode = detector.ReasonCode(result.Technique, result.Signals)
+	}
+	if waf && !suspicious {
+		result.WAFDetected, result.WAFNote = true, wafNote
+	}
+
+	if sc.aiProvider != nil {
+		sc.runAIAnalysis("CL.TE-GPOST", sc.baselineResponse, resp2, result)
+	}
+
+	sc.addResult(result)
+
+	sc.log.Infof("    Result: %s\n", func() string {
+		if result.Suspicious {
+			return "SUSPICIOUS ✗"
+		}
+		if result.Blocked {
+			return "BLOCKED ~ (inconclusive)"
+		}
+		if result.WAFDetected {
+			return "WAF ~ (inconclusive, confidence downgraded)"
+		}
+		return "UNCLEAR ~"
+	}())
+
+	if len(resp2.Body) > 0 && len(resp2.Body) < 500 {
+		sc.log.Infof("    Response Body Preview:\n%s\n", resp2.Body)
+	} else if len(resp2.Body) > 0 {
+		sc.log.Infof("    Response Body (first 300 chars):\n%s...\n", resp2.Body[:300])
+	}
+
+	return nil
+}
+
+// classifyGPOSTProbe looks for signs that a probe was prefixed with the
+// smuggled "G". blocked is set when the probe got a configured block status.
+func (sc *Scanner) classifyGPOSTProbe(resp *models.HTTPResponse) (suspicious, blocked bool, reason, reasonCode string) {
+	switch {
+	case strings.Contains(strings.ToUpper(resp.Raw), "GPOST"):
+		sc.log.Infof("        ✗ SUSPICIOUS: Response contains 'GPOST' indicator\n")
+		return true, false, "Probe response contains 'GPOST' method - request successfully poisoned!", "gpost-reflected"
+	case strings.Contains(strings.ToUpper(resp.Reason), "UNRECOGNIZED METHOD"),
+		strings.Contains(strings.ToUpper(resp.Body), "UNRECOGNIZED METHOD"):
+		sc.log.Infof("        ✗ SUSPICIOUS: Response mentions unrecognized method\n")
+		return true, false, "Probe response indicates unrecognized method - likely poisoned request", "unrecognized-method"
+	case sc.detector.IsBlockStatus(resp.StatusCode):
+		sc.log.Infof("        ~ BLOCKED: Probe answered with block status %d\n", resp.StatusCode)
+		return false, true, "", ""
+	case (resp.StatusCode == 405 || resp.StatusCode == 400) && resp.StatusCode != sc.baselineResponse.StatusCode:
+		sc.log.Infof("        ~ POSSIBLE: Status code changed after smuggling\n")
+		return true, false, fmt.Sprintf("Probe returned %d (baseline was %d) - possible poisoning", resp.StatusCode, sc.baselineResponse.StatusCode), "followup-status"
+	}
+	return false, false, "", ""
+}
+
+// HeaderInjectionCanaryHeader is the response header TestHeaderInjection
+// tries to inject.
+const HeaderInjectionCanaryHeader = "X-Smuggle-Canary"
+
+// TestHeaderInjection smuggles a request that tries to split a response and
+// inject HeaderInjectionCanaryHeader with the canary value, then checks
+// whether a pipelined follow-up's response carries it. An empty canary is
+// replaced with a random one so no two runs share a value. A match means
+// the desync lets an attacker set headers on other users' responses.
+func (sc *Scanner) TestHeaderInjection(canary string) error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+
+	sc.log.Infof("\n[*] Testing response header injection via smuggled CRLF...\n")
 
-	return nil
-}
+	if sc.stackInfo.PipelineTested && !sc.stackInfo.PipelineSupported {
+		sc.log.Infof("    Skipped: target does not reuse connections\n")
+		return nil
+	}
 
-// runAIAnalysis calls the AI provider to analyze a test result
-func (sc *Scanner) runAIAnalysis(testType string, baseline, test *models.HTTPResponse, result *models.ScanResult) {
-	baseline_map := map[string]interface{}{
-		"status":   baseline.StatusCode,
-		"body_len": len(baseline.Body),
-		"timing":   baseline.TimingMS,
-		"headers":  len(baseline.Headers),
+	if canary == "" {
+		canary = fmt.Sprintf("%08x%08x", sc.rng.Uint32(), sc.rng.Uint32())
 	}
 
-	test_map := map[string]interface{}{
-		"status":   test.StatusCode,
-		"body_len": len(test.Body),
-		"timing":   test.TimingMS,
-		"headers":  len(test.Headers),
+	attack := payload.WithHeaders(payload.CL_TE_HEADER_INJECTION(sc.target, HeaderInjectionCanaryHeader, canary), sc.requestHeaders)
+	if send, err := sc.approve("Header-Injection", attack); !send {
+		return err
 	}
+	probe := payload.WithHeaders(payload.ProbeRequestAfterPoison(sc.target), sc.requestHeaders)
 
-	aiResult, err := sc.aiProvider.AnalyzeResponses(context.Background(), baseline_map, test_map, testType)
+	targetAddr := sc.target.Addr()
+	responses, err := sc.sender.SendPipelinedContext(sc.ctx, targetAddr, []string{attack, probe})
 	if err != nil {
-		fmt.Printf("    [AI Analysis Error: %v]\n", err)
-		return
+		return fmt.Errorf("header injection send failed: %w", err)
 	}
 
-	if aiResult != nil && aiResult.Confidence > 0 {
-		fmt.Printf("\n    [AI Analysis - %s]\n", sc.aiProvider.Name())
-		fmt.Printf("    Confidence: %.1f%%\n", aiResult.Confidence*100)
-		fmt.Printf("    Reasoning: %s\n", aiResult.Reasoning)
-		if len(aiResult.SuspiciousSignals) > 0 {
-			fmt.Printf("    Signals: %v\n", aiResult.SuspiciousSignals)
-		}
-		if len(aiResult.Recommendations) > 0 {
-			fmt.Printf("    Next Steps: %v\n", aiResult.Recommendations)
-		}
+	result := &models.ScanResult{
+		Target:           sc.target.Host,
+		Technique:        "Header-Injection",
+		BaselineResponse: sc.baselineResponse,
+		Responses:        responses,
+		SentPayload:      attack + probe,
+	}
 
-		// Update result with AI confidence if higher
-		if aiResult.Confidence > result.ConfidenceScore {
-			result.ConfidenceScore = aiResult.Confidence
-		}
-		if aiResult.IsVulnerable && !result.Suspicious {
-			result.Suspicious = true
+	if len(responses) < 2 {
+		result.Reason = "Connection closed after the first response; follow-up not delivered"
+		if len(responses) == 1 {
+			result.TestResponse = responses[0]
 		}
+		sc.addResult(result)
+		sc.log.Infof("    Result: UNCLEAR ~ (connection closed)\n")
+		return nil
 	}
-}
 
-// TestTECL tests for TE.CL vulnerability.
-func (sc *Scanner) TestTECL() error {
-	if sc.baselineResponse == nil {
-		return fmt.Errorf("baseline not captured; call CaptureBaseline first")
+	probeResp := responses[1]
+	result.TestResponse = probeResp
+	result.ResponseTimeDiff = probeResp.TimingMS - sc.baselineResponse.TimingMS
+	sc.log.Infof("    Probe response: %d | Timing: %d ms\n", probeResp.StatusCode, probeResp.TimingMS)
+	sc.printTiming(probeResp)
+
+	if hasHeaderValue(probeResp, HeaderInjectionCanaryHeader, canary) {
+		result.Suspicious = true
+		result.ConfidenceScore = 1.0
+		result.Signals = []string{"canary-header"}
+		result.ReasonCode = detector.ReasonCode(result.Technique, result.Signals)
+		result.Reason = fmt.Sprintf(
+			"CRITICAL: follow-up response carries injected header %s: %s - smuggled CRLF split a victim response",
+			HeaderInjectionCanaryHeader, canary,
+		)
+	} else {
+		result.Reason = "Canary header not present in follow-up response"
 	}
 
-	fmt.Printf("\n[*] Testing TE.CL (Transfer-Encoding / Content-Length)...\n")
+	if sc.aiProvider != nil && result.Suspicious {
+		sc.runAIAnalysis("Header-Injection", sc.baselineResponse, probeResp, result)
+	}
 
-	gen := payload.NewGenerator(sc.target, sc.port)
-	gen.SetPath("/")
-	gen.AddHeader("Connection", "close")
+	sc.addResult(result)
 
-	payloadStr, err := gen.GenerateTECLPayload("GET /api HTTP/1.1\r\nHost: " + sc.target + "\r\n\r\n")
-	if err != nil {
-		return fmt.Errorf("TE.CL payload generation failed: %w", err)
+	if result.Suspicious {
+		sc.log.Infof("    Result: CRITICAL ✗ (canary %s reflected as a header)\n", canary)
+	} else {
+		sc.log.Infof("    Result: CLEAN ✓\n")
 	}
 
-	targetAddr := fmt.Sprintf("%s:%d", sc.target, sc.port)
-	testResp, err := sc.sender.SendRequest(targetAddr, payloadStr)
-	if err != nil {
-		return fmt.Errorf("TE.CL test send failed: %w", err)
+	return nil
+}
+
+// hasHeaderValue reports whether resp has a header named name (any case)
+// whose value is exactly value.
+func hasHeaderValue(resp *models.HTTPResponse, name, value string) bool {
+	for _, v := range resp.HeaderValues(name) {
+		if strings.TrimSpace(v) == value {
+			return true
+		}
 	}
+	return false
+}
 
-	fmt.Printf("    Response: %d | Timing: %d ms\n", testResp.StatusCode, testResp.TimingMS)
+// probeCL0 pipelines a CL.0 attack on path with a benign follow-up. If the
+// back-end ignored the attack body, the follow-up is answered as the
+// smuggled request for a random marker path.
+func (sc *Scanner) probeCL0(path string) (*models.ScanResult, error) {
+	marker := fmt.Sprintf("/cl0-probe-%08x", sc.rng.Uint32())
 
-	comparison := sc.baselineManager.CompareResponses(sc.baselineResponse, testResp)
-	result := sc.detector.AnalyzeTECL(sc.target, comparison)
+	gen := sc.newGenerator()
+	gen.SetMethod("POST")
+	gen.SetPath(path)
+	gen.AddHeader("Connection", "keep-alive")
 
-	// Run AI analysis if provider available
-	if sc.aiProvider != nil {
-		sc.runAIAnalysis("TE.CL", sc.baselineResponse, testResp, result)
+	attack, err := gen.GenerateCL0Payload("GET " + marker + " HTTP/1.1\r\nX-Ignore: X")
+	if err != nil {
+		return nil, fmt.Errorf("CL.0 payload generation failed: %w", err)
 	}
+	followUp := sc.newGenerator().GenerateBaseline()
 
-	sc.results = append(sc.results, result)
+	if send, err := sc.approve("CL.0["+path+"]", attack); !send {
+		return nil, err
+	}
 
-	fmt.Printf("    Result: %s\n", func() string {
-		if result.Suspicious {
-			return "SUSPICIOUS ✗"
+	targetAddr := sc.target.Addr()
+	responses, err := sc.sender.SendPipelinedContext(sc.ctx, targetAddr, []string{attack, followUp})
+	if err != nil {
+		return nil, fmt.Errorf("CL.0 test send failed: %w", err)
+	}
+
+	technique := "CL.0[" + path + "]"
+
+	if len(responses) < 2 {
+		result := &models.ScanResult{
+			Target:           sc.target.Host,
+			Technique:        technique,
+			Reason:           "Connection closed after the first response; path not testable for CL.0",
+			BaselineResponse: sc.baselineResponse,
+			Responses:        responses,
+			SentPayload:      attack + followUp,
 		}
-		return "CLEAN ✓"
-	}())
+		if len(responses) == 1 {
+			result.TestResponse = responses[0]
+		}
+		return result, nil
+	}
 
-	return nil
+	comparison := sc.baselineManager.CompareResponses(sc.baselineResponse, responses[1])
+	result := sc.detector.AnalyzeCL0(sc.target.Host, comparison, marker)
+	result.Technique = technique
+	result.Responses = responses
+	result.SentPayload = attack + followUp
+
+	return result, nil
 }
 
-// TestMixedTE tests for Mixed Transfer-Encoding header exploitation.
-func (sc *Scanner) TestMixedTE() error {
-	if sc.baselineResponse == nil {
-		return fmt.Errorf("baseline not captured; call CaptureBaseline first")
+// TestCL0 tests a single endpoint for CL.0 desync, where the back-end
+// ignores the request body. Static assets and redirects are the usual
+// candidates; an empty path tests "/".
+func (sc *Scanner) TestCL0(path string) error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
+	if path == "" {
+		path = "/"
 	}
 
-	fmt.Printf("\n[*] Testing Mixed-TE (Multiple Transfer-Encoding headers)...\n")
+	sc.log.Infof("\n[*] Testing CL.0 on %s...\n", path)
 
-	payloadStr := fmt.Sprintf(
-		"GET / HTTP/1.1\r\nHost: %s:%d\r\nConnection: close\r\n"+
-			"Transfer-Encoding: identity\r\n"+
-			"Transfer-Encoding: chunked\r\nContent-Length: 5\r\n\r\n"+
-			"0\r\n\r\nGET /secret HTTP/1.1\r\nHost: %s\r\n\r\n",
-		sc.target, sc.port, sc.target)
+	result, err := sc.probeCL0(path)
+	if err != nil || result == nil {
+		return err
+	}
+	sc.addResult(result)
 
-	targetAddr := fmt.Sprintf("%s:%d", sc.target, sc.port)
-	testResp, err := sc.sender.SendRequest(targetAddr, payloadStr)
-	if err != nil {
-		return fmt.Errorf("Mixed-TE test send failed: %w", err)
+	if result.Suspicious {
+		sc.log.Infof("    Result: SUSPICIOUS ✗ (confidence %.0f%%)\n", result.ConfidenceScore*100)
+	} else {
+		sc.log.Infof("    Result: CLEAN ✓\n")
 	}
+	return nil
+}
 
-	fmt.Printf("    Response: %d | Timing: %d ms\n", testResp.StatusCode, testResp.TimingMS)
+// TestCL0Sweep tries the CL.0 probe against each path, pausing between
+// probes, and records a result for every path that looks vulnerable.
+func (sc *Scanner) TestCL0Sweep(paths []string) error {
+	if err := sc.requireBaseline(); err != nil {
+		return err
+	}
 
-	comparison := sc.baselineManager.CompareResponses(sc.baselineResponse, testResp)
-	result := sc.detector.AnalyzeMixedTE(sc.target, comparison)
+	sc.log.Infof("\n[*] Testing CL.0 across %d paths...\n", len(paths))
 
-	// Run AI analysis if provider available
-	if sc.aiProvider != nil {
-		sc.runAIAnalysis("Mixed-TE", sc.baselineResponse, testResp, result)
-	}
+	vulnerable := 0
+	for i, path := range paths {
+		if sc.ctx.Err() != nil {
+			break
+		}
+		if i > 0 && sc.sweepDelay > 0 && !sc.dryRun {
+			time.Sleep(sc.sweepDelay)
+		}
+		sc.heartbeat("CL.0[" + path + "]")
 
-	sc.results = append(sc.results, result)
+		result, err := sc.probeCL0(path)
+		if errors.Is(err, ErrStepAborted) {
+			return err
+		}
+		if err != nil {
+			sc.log.Infof("    %s: %v\n", path, err)
+			continue
+		}
+		if result == nil {
+			continue
+		}
 
-	fmt.Printf("    Result: %s\n", func() string {
 		if result.Suspicious {
-			return "SUSPICIOUS ✗"
+			vulnerable++
+			sc.addResult(result)
+			sc.log.Infof("    ✗ %s: SUSPICIOUS (confidence %.0f%%)\n", path, result.ConfidenceScore*100)
 		}
-		return "CLEAN ✓"
-	}())
+	}
+
+	if vulnerable == 0 {
+		sc.addResult(&models.ScanResult{
+			Target:           sc.target.Host,
+			Technique:        "CL.0",
+			Reason:           fmt.Sprintf("No CL.0 desync found across %d paths", len(paths)),
+			BaselineResponse: sc.baselineResponse,
+		})
+		sc.log.Infof("    Result: CLEAN ✓ (%d paths)\n", len(paths))
+	} else {
+		sc.log.Infof("    Result: %d/%d paths SUSPICIOUS ✗\n", vulnerable, len(paths))
+	}
 
 	return nil
 }
 
-// TestObfuscatedTE tests for obfuscated Transfer-Encoding header exploitation.
-// This technique uses non-standard TE header values (e.g., "cow") to bypass proxies.
-func (sc *Scanner) TestObfuscatedTE() error {
-	if sc.baselineResponse == nil {
-		return fmt.Errorf("baseline not captured; call CaptureBaseline first")
+// h2AnalyzeFunc is a detector entry point for an HTTP/2 downgrade probe.
+type h2AnalyzeFunc func(target string, comparison *models.BaselineComparison, marker string) *models.ScanResult
+
+// TestH2CL tests for H2.CL downgrade smuggling: an HTTP/2 request declaring
+// content-length: 0 whose DATA carries a smuggled request prefix.
+func (sc *Scanner) TestH2CL() error {
+	sc.log.Infof("\n[*] Testing H2.CL (HTTP/2 content-length downgrade)...\n")
+	_, err := sc.runH2Technique("H2.CL", payload.GenerateH2CL, sc.detector.AnalyzeH2CL)
+	return err
+}
+
+// TestH2TE tests for H2.TE downgrade smuggling: an HTTP/2 request carrying
+// transfer-encoding: chunked and a terminating chunk ahead of a smuggled
+// request prefix.
+func (sc *Scanner) TestH2TE() error {
+	sc.log.Infof("\n[*] Testing H2.TE (HTTP/2 transfer-encoding downgrade)...\n")
+	_, err := sc.runH2Technique("H2.TE", payload.GenerateH2TE, sc.detector.AnalyzeH2TE)
+	return err
+}
+
+// runH2Technique sends an HTTP/2 attack built by generate, then a benign
+// HTTP/2 request on a new connection. A front-end that downgrades to
+// HTTP/1.1 and reuses its back-end connection answers the benign request
+// with the response to the smuggled prefix.
+func (sc *Scanner) runH2Technique(technique string, generate func(*models.Target, string) *models.H2Request, analyze h2AnalyzeFunc) (*models.ScanResult, error) {
+	h2 := sender.NewH2Sender(sc.sender)
+	targetAddr := sc.target.Addr()
+
+	if sc.h2Baseline == nil && !sc.dryRun {
+		resp, err := h2.SendRequestContext(sc.ctx, targetAddr, payload.WithH2Headers(payload.H2Baseline(sc.target, "/"), sc.requestHeaders))
+		if err != nil {
+			// a target without HTTP/2 is not a failed scan
+			sc.log.Printf("    [!] HTTP/2 baseline failed, skipping: %v\n", err)
+			return nil, nil
+		}
+		sc.h2Baseline = resp
 	}
 
-	fmt.Printf("\n[*] Testing Obfuscated-TE (Transfer-Encoding with non-standard values)...\n")
+	marker := fmt.Sprintf("/h2-probe-%08x", sc.rng.Uint32())
+	attack := payload.WithH2Headers(generate(sc.target, "GET "+marker+" HTTP/1.1\r\nX-Ignore: X"), sc.requestHeaders)
 
-	gen := payload.NewGenerator(sc.target, sc.port)
-	gen.SetPath("/")
-	gen.AddHeader("Connection", "close")
+	if send, err := sc.approve(technique, attack.String()); !send {
+		return nil, err
+	}
 
-	// Test with "cow" obfuscation (common exploitation technique)
-	payloadStr, err := gen.GenerateObfuscatedTEPayload(
-		"POST / HTTP/1.1\r\nHost: "+sc.target+"\r\nContent-Type: application/x-www-form-urlencoded\r\nContent-Length: 15\r\n\r\nx=1",
-		"cow",
-	)
+	attackResp, err := h2.SendRequestContext(sc.ctx, targetAddr, attack)
 	if err != nil {
-		return fmt.Errorf("Obfuscated-TE payload generation failed: %w", err)
+		return nil, fmt.Errorf("%s test send failed: %w", technique, err)
 	}
+	sc.log.Infof("    Attack: %s | Timing: %d ms\n", attackResp.StatusLine, attackResp.TimingMS)
 
-	targetAddr := fmt.Sprintf("%s:%d", sc.target, sc.port)
-	testResp, err := sc.sender.SendRequest(targetAddr, payloadStr)
+	followUp, err := h2.SendRequestContext(sc.ctx, targetAddr, payload.WithH2Headers(payload.H2Baseline(sc.target, "/"), sc.requestHeaders))
 	if err != nil {
-		return fmt.Errorf("Obfuscated-TE test send failed: %w", err)
+		return nil, fmt.Errorf("%s follow-up failed: %w", technique, err)
 	}
+	sc.log.Infof("    Follow-up: %d | Timing: %d ms\n", followUp.StatusCode, followUp.TimingMS)
+	sc.printTiming(followUp)
 
-	fmt.Printf("    Response: %d | Timing: %d ms\n", testResp.StatusCode, testResp.TimingMS)
-
-	comparison := sc.baselineManager.CompareResponses(sc.baselineResponse, testResp)
-	result := sc.detector.AnalyzeObfuscatedTE(sc.target, comparison)
+	comparison := sc.baselineManager.CompareResponses(sc.h2Baseline, followUp)
+	result := analyze(sc.target.Host, comparison, marker)
+	result.SentPayload = attack.String()
 
-	// Run AI analysis if provider available
 	if sc.aiProvider != nil {
-		sc.runAIAnalysis("Obfuscated-TE", sc.baselineResponse, testResp, result)
+		sc.runAIAnalysis(technique, sc.h2Baseline, followUp, result)
 	}
 
-	sc.results = append(sc.results, result)
+	sc.addResult(result)
 
-	fmt.Printf("    Result: %s\n", func() string {
-		if result.Suspicious {
-			return "SUSPICIOUS ✗"
-		}
-		return "CLEAN ✓"
-	}())
+	if result.Suspicious {
+		sc.log.Infof("    Result: SUSPICIOUS ✗\n")
+	} else {
+		sc.log.Infof("    Result: CLEAN ✓\n")
+	}
 
-	return nil
+	return result, nil
 }
 
-func (sc *Scanner) TestCLTE_GPOST() error {
-	if sc.baselineResponse == nil {
-		return fmt.Errorf("baseline not captured; call CaptureBaseline first")
-	}
+// scanStep is a single named stage of the scan workflow. id is the
+// technique identifier used for selection; preflight steps have none.
+type scanStep struct {
+	id   string
+	name string
+	run  func() error
+}
+
+// Run executes the full scanning workflow, printing progress as it goes.
+// ScanResults is the silent form for use as a library.
+func (sc *Scanner) Run() error {
+	return sc.scan()
+}
 
-	fmt.Printf("\n[*] Testing CL.TE GPOST poisoning (multi-request attack)...\n")
+// ScanResults runs the full workflow without printing and returns the
+// recorded results and the final report. Progress still reaches the
+// handler set with SetProgressHandler. The report is nil after a dry run
+// or when the scan failed before one was generated.
+func (sc *Scanner) ScanResults() ([]*models.ScanResult, *detector.DetectionReport, error) {
+	sc.silent = true
+	defer func() { sc.silent = false }()
 
-	targetAddr := fmt.Sprintf("%s:%d", sc.target, sc.port)
+	err := sc.scan()
+	return sc.results, sc.report, err
+}
 
-	fmt.Printf("    [1] Sending smuggling payload...\n")
-	smugglePayload := payload.CL_TE_GPOST_ATTACK(sc.target, sc.port)
-	resp1, err := sc.sender.SendRequest(targetAddr, smugglePayload)
-	if err != nil {
-		return fmt.Errorf("smuggling payload send failed: %w", err)
+// scan executes the workflow behind Run and ScanResults.
+func (sc *Scanner) scan() error {
+	sc.log.Infof("\n%s\n", strings.Repeat("=", 60))
+	sc.log.Infof("HTTP REQUEST SMUGGLING SCANNER\n")
+	sc.log.Infof("Target: %s\n", sc.target.Addr())
+	sc.log.Infof("%s\n\n", strings.Repeat("=", 60))
+
+	if sc.watchdogInterval > 0 {
+		ctx, cancel := context.WithCancel(sc.ctx)
+		defer cancel()
+		sc.ctx = ctx
+		sc.watchdog = newWatchdog(sc.watchdogInterval, cancel, sc.log.Writer())
+		sc.watchdog.start()
+		defer sc.watchdog.halt()
 	}
-	fmt.Printf("        Response: %d | Timing: %d ms\n", resp1.StatusCode, resp1.TimingMS)
 
-	fmt.Printf("    [2] Sending probe request after smuggling...\n")
-	probePayload := payload.ProbeRequestAfterPoison(sc.target, sc.port)
-	resp2, err := sc.sender.SendRequest(targetAddr, probePayload)
-	if err != nil {
-		return fmt.Errorf("probe request send failed: %w", err)
+	if sc.dryRun {
+		sc.log.Infof("[*] Dry run: payloads are printed, nothing is sent\n")
+		if sc.autoTechniques {
+			sc.applyRecommendations()
+		}
+		_, err := sc.runSteps(sc.techniqueSteps())
+		return err
 	}
-	fmt.Printf("        Response: %d | Timing: %d ms\n", resp2.StatusCode, resp2.TimingMS)
-
-	fmt.Printf("    [3] Analyzing probe response for poisoning...\n")
 
-	var suspicious bool
-	var reason string
+	preflight := []scanStep{
+		{"", "baseline", sc.CaptureBaseline},
+	}
+	if sc.compareMethods {
+		preflight = append(preflight, scanStep{"", "method-routing", sc.TestMethodRouting})
+	}
+	if sc.pipelineProbes > 0 {
+		preflight = append(preflight, scanStep{"", "pipeline", func() error {
+			return sc.TestPipelineBaseline(sc.pipelineProbes)
+		}})
+	}
 
-	if strings.Contains(strings.ToUpper(resp2.Raw), "GPOST") {
-		suspicious = true
-		reason = "Probe response contains 'GPOST' method - request successfully poisoned!"
-		fmt.Printf("        ✗ SUSPICIOUS: Response contains 'GPOST' indicator\n")
-	} else if strings.Contains(strings.ToUpper(resp2.Raw), "UNRECOGNIZED METHOD") {
-		suspicious = true
-		reason = "Probe response indicates unrecognized method - likely poisoned request"
-		fmt.Printf("        ✗ SUSPICIOUS: Response mentions unrecognized method\n")
-	} else if resp2.StatusCode == 405 || resp2.StatusCode == 400 {
-		if resp2.StatusCode != sc.baselineResponse.StatusCode {
-			suspicious = true
-			reason = fmt.Sprintf("Probe returned %d (baseline was %d) - possible poisoning", resp2.StatusCode, sc.baselineResponse.StatusCode)
-			fmt.Printf("        ~ POSSIBLE: Status code changed after smuggling\n")
+	if stop, err := sc.runSteps(preflight); stop || err != nil {
+		if err == nil {
+			sc.generateFinalReport()
 		}
+		return err
 	}
 
-	result := &models.ScanResult{
-		Target:           sc.target,
-		Technique:        "CL.TE-GPOST",
-		Suspicious:       suspicious,
-		Reason:           reason,
-		ResponseTimeDiff: resp2.TimingMS - sc.baselineResponse.TimingMS,
-		BaselineResponse: sc.baselineResponse,
-		TestResponse:     resp2,
+	if sc.autoTechniques {
+		sc.applyRecommendations()
 	}
 
-	if sc.aiProvider != nil {
-		sc.runAIAnalysis("CL.TE-GPOST", sc.baselineResponse, resp2, result)
+	stopped, err := sc.runSteps(sc.techniqueSteps())
+	if err != nil {
+		return err
 	}
 
-	sc.results = append(sc.results, result)
-
-	fmt.Printf("    Result: %s\n", func() string {
-		if result.Suspicious {
-			return "SUSPICIOUS ✗"
+	if sc.aiProvider != nil && sc.aiSuggest && !stopped {
+		if err := sc.runAISuggestions(); err != nil {
+			return err
 		}
-		return "UNCLEAR ~"
-	}())
+	}
 
-	if len(resp2.Body) > 0 && len(resp2.Body) < 500 {
-		fmt.Printf("    Response Body Preview:\n%s\n", resp2.Body)
-	} else if len(resp2.Body) > 0 {
-		fmt.Printf("    Response Body (first 300 chars):\n%s...\n", resp2.Body[:300])
+	if sc.ctx.Err() == nil {
+		sc.verifyHealthy()
 	}
 
+	sc.generateFinalReport()
+
 	return nil
 }
 
-// Run executes the full scanning workflow.
-func (sc *Scanner) Run() error {
-	fmt.Printf("\n%s\n", strings.Repeat("=", 60))
-	fmt.Printf("HTTP REQUEST SMUGGLING SCANNER\n")
-	fmt.Printf("Target: %s:%d\n", sc.target, sc.port)
-	fmt.Printf("%s\n\n", strings.Repeat("=", 60))
+// techniqueSteps returns the enabled technique tests in run order,
+// followed by the fuzzing run if SetFuzz was called.
+func (sc *Scanner) techniqueSteps() []scanStep {
+	all := sc.allTechniqueSteps()
+	steps := make([]scanStep, 0, len(all)+1)
+	for _, step := range all {
+		if sc.techniqueEnabled(step.id) {
+			steps = append(steps, step)
+		}
+	}
+	if sc.fuzzTechnique != "" {
+		steps = append(steps, scanStep{sc.fuzzTechnique, "Fuzz", func() error {
+			return sc.TestFuzz(sc.fuzzTechnique, sc.fuzzVariants)
+		}})
+	}
+	return steps
+}
 
-	if err := sc.CaptureBaseline(); err != nil {
-		return err
+// allTechniqueSteps returns every technique test in run order, enabled or
+// not.
+func (sc *Scanner) allTechniqueSteps() []scanStep {
+	return []scanStep{
+		{detector.TechCLTE, "CL.TE", sc.TestCLTE},
+		{detector.TechTECL, "TE.CL", sc.TestTECL},
+		{detector.TechMixedTE, "Mixed-TE", sc.TestMixedTE},
+		{detector.TechTETE, "TE.TE", sc.TestTETE},
+		{detector.TechDualCL, "CL.CL", sc.TestDualCL},
+		{detector.TechObfuscatedTE, "Obfuscated-TE", sc.TestObfuscatedTE},
+		{detector.TechObfuscatedTE, "Bare-CR", sc.TestBareCR},
+		{detector.TechChunkExt, "Chunk-Ext", sc.TestChunkExtension},
+		{detector.TechGPOST, "CL.TE-GPOST", sc.TestCLTE_GPOST},
+		{detector.TechSegmented, "Segmented", sc.TestSegmentedDelivery},
+		{detector.TechHeaderInjection, "Header-Injection", func() error {
+			return sc.TestHeaderInjection("")
+		}},
+		{detector.TechCL0, "CL.0", func() error {
+			if len(sc.cl0Paths) <= 1 {
+				return sc.TestCL0(strings.Join(sc.cl0Paths, ""))
+			}
+			return sc.TestCL0Sweep(sc.cl0Paths)
+		}},
+		{detector.TechH2CL, "H2.CL", sc.TestH2CL},
+		{detector.TechH2TE, "H2.TE", sc.TestH2TE},
 	}
+}
 
-	if err := sc.TestCLTE(); err != nil {
-		return err
+// techniqueEnabled reports whether a technique should run. Without an
+// explicit selection every technique runs, except CL.0 which needs paths.
+func (sc *Scanner) techniqueEnabled(id string) bool {
+	if sc.enabledTechniques != nil {
+		return sc.enabledTechniques[id]
 	}
+	switch id {
+	case detector.TechCL0:
+		return len(sc.cl0Paths) > 0
+	case detector.TechH2CL, detector.TechH2TE:
+		return sc.http2
+	}
+	return true
+}
 
-	if err := sc.TestTECL(); err != nil {
-		return err
+// applyRecommendations restricts the run to the techniques recommended for
+// the fingerprinted stack and prints why each was chosen.
+func (sc *Scanner) applyRecommendations() {
+	recs := detector.ExplainRecommendations(sc.stackInfo)
+
+	sc.log.Infof("\n[*] Auto-selected techniques (server: %q):\n", sc.stackInfo.Server)
+	enabled := make(map[string]bool, len(recs))
+	for _, r := range recs {
+		enabled[r.Technique] = true
+		sc.log.Infof("    - %s: %s\n", r.Technique, r.Rationale)
 	}
+	sc.enabledTechniques = enabled
+}
 
-	if err := sc.TestMixedTE(); err != nil {
-		return err
+// runSteps runs steps in order, stopping early if the watchdog cancels the
+// target. It reports whether the scan was stopped.
+func (sc *Scanner) runSteps(steps []scanStep) (bool, error) {
+	for _, step := range steps {
+		sc.heartbeat(step.name)
+		if step.id != "" {
+			sc.emit(ScanEvent{Phase: PhaseTechniqueStart, Technique: step.name})
+		}
+
+		err := step.run()
+		if errors.Is(err, ErrStepAborted) {
+			sc.log.Printf("\n[!] %v during %s; skipping remaining tests\n", err, step.name)
+			return true, nil
+		}
+
+		if stalled, during := sc.stalled(); stalled {
+			sc.recordStall(during)
+			return true, nil
+		}
+		if err != nil {
+			return true, err
+		}
 	}
+	return false, nil
+}
 
-	if err := sc.TestObfuscatedTE(); err != nil {
-		return err
+// heartbeat tells the watchdog, if any, that the scan is still moving and
+// names the test or variant now running; "" keeps the current name. Steps
+// that loop over many variants or samples call it on every pass, so a step
+// that is slow overall but not stuck is not cancelled.
+func (sc *Scanner) heartbeat(name string) {
+	if sc.watchdog != nil {
+		sc.watchdog.progress(name)
 	}
+}
 
-	if err := sc.TestCLTE_GPOST(); err != nil {
-		return err
+// stalled reports whether the watchdog cancelled this target.
+func (sc *Scanner) stalled() (bool, string) {
+	if sc.watchdog == nil || sc.ctx.Err() == nil {
+		return false, ""
 	}
+	return sc.watchdog.state()
+}
 
-	sc.generateFinalReport()
+// recordStall adds a result noting the target stopped making progress.
+func (sc *Scanner) recordStall(during string) {
+	sc.log.Printf("\n[!] Target stalled during %s; skipping remaining tests\n", during)
 
-	return nil
+	sc.addResult(&models.ScanResult{
+		Target:           sc.target.Host,
+		Technique:        during,
+		Stalled:          true,
+		Reason:           fmt.Sprintf("Target stalled: no progress for %s during %s", 2*sc.watchdogInterval, during),
+		BaselineResponse: sc.baselineResponse,
+	})
 }
 
 // generateFinalReport creates and stores the detection report.
 func (sc *Scanner) generateFinalReport() {
-	sc.report = sc.detector.GenerateReport(sc.target, sc.results...)
+	sc.report = sc.detector.GenerateReport(sc.target.Host, detector.Dedup(sc.results)...)
+	sc.report.Health = sc.health
+	sc.report.Notes = detector.StackNotes(sc.stackInfo,
+		sc.techniqueEnabled(detector.TechH2CL) || sc.techniqueEnabled(detector.TechH2TE))
+
+	if sc.aiProvider != nil {
+		sc.identifyTechnique()
+	}
+	sc.emit(ScanEvent{Phase: PhaseReport, Report: sc.report})
+}
+
+// verifyHealthy sends a clean request after testing and checks that it is
+// answered like the original baseline. A difference may mean a poisoned
+// connection or a degraded back-end is now affecting real users, so it is
+// reported loudly.
+func (sc *Scanner) verifyHealthy() {
+	if sc.initialBaseline == nil {
+		return
+	}
+
+	sc.log.Infof("\n[*] Verifying target health after testing...\n")
+
+	resp, err := sc.baselineManager.CaptureBaseline()
+	check := &models.HealthCheck{Response: resp}
+	sc.health = check
+
+	if err != nil {
+		check.Detail = fmt.Sprintf("clean request failed after testing: %v", err)
+	} else {
+		comparison := sc.baselineManager.CompareResponses(sc.initialBaseline, resp)
+		switch {
+		case comparison.MalformedStatusAppeared:
+			check.Detail = fmt.Sprintf("clean request got a malformed status line %q", comparison.NewStatusLine)
+		case comparison.StatusCodeChanged:
+			check.Detail = fmt.Sprintf("clean request now returns %d (baseline was %d)",
+				comparison.NewStatusCode, comparison.OldStatusCode)
+		default:
+			check.Healthy = true
+			check.Detail = fmt.Sprintf("clean request returned %d as before", resp.StatusCode)
+		}
+	}
+
+	if check.Healthy {
+		sc.log.Infof("    Result: healthy ✓ (%s)\n", check.Detail)
+		return
+	}
+
+	sc.log.Printf("\n%s\n", strings.Repeat("!", 60))
+	sc.log.Printf("[!] WARNING: target behaves differently after testing\n")
+	sc.log.Printf("[!] %s\n", check.Detail)
+	sc.log.Printf("[!] A lingering desync may be affecting real users; verify manually\n")
+	sc.log.Printf("%s\n", strings.Repeat("!", 60))
 }
 
 // PrintReport prints the final detection report to stdout.
 func (sc *Scanner) PrintReport() {
 	if sc.report == nil {
-		fmt.Println("[!] No report available. Run the scanner first.")
+		sc.log.Printf("[!] No report available. Run the scanner first.\n")
 		return
 	}
 
-	fmt.Printf("\n%s\n", strings.Repeat("=", 60))
-	fmt.Print(sc.report.String())
-	fmt.Printf("%s\n", strings.Repeat("=", 60))
+	sc.log.Printf("\n%s\n", strings.Repeat("=", 60))
+	sc.log.Printf("%s", sc.report.String())
+	sc.log.Printf("%s\n", strings.Repeat("=", 60))
 }
 
 // GetResults returns the raw scan results.
@@ -461,6 +2235,14 @@ func (sc *Scanner) IsVulnerable() bool {
 	return sc.report.Vulnerable > 0
 }
 
+// VulnerableCount returns the number of techniques flagged suspicious.
+func (sc *Scanner) VulnerableCount() int {
+	if sc.report == nil {
+		return 0
+	}
+	return sc.report.Vulnerable
+}
+
 // GetMostLikelyTechnique returns the most likely vulnerability type.
 func (sc *Scanner) GetMostLikelyTechnique() string {
 	if sc.report == nil || sc.report.Vulnerable == 0 {
@@ -476,9 +2258,16 @@ func (sc *Scanner) Summary() string {
 	}
 
 	var summary strings.Builder
-	summary.WriteString(fmt.Sprintf("Target: %s:%d\n", sc.target, sc.port))
+	summary.WriteString(fmt.Sprintf("Target: %s\n", sc.target.Addr()))
 	summary.WriteString(fmt.Sprintf("Tests run: %d\n", sc.report.TotalTests))
 	summary.WriteString(fmt.Sprintf("Vulnerable: %d\n", sc.report.Vulnerable))
+	switch sc.stackInfo.MethodRouting {
+	case "split":
+		summary.WriteString(fmt.Sprintf("Method routing: GET and POST reach different back-ends (%q vs %q)\n",
+			sc.stackInfo.Server, sc.stackInfo.PostServer))
+	case "same":
+		summary.WriteString("Method routing: GET and POST reach the same back-end\n")
+	}
 
 	if sc.report.Vulnerable > 0 {
 		summary.WriteString(fmt.Sprintf("Most likely: %s\n", sc.report.MostLikelyTechnique))
@@ -490,34 +2279,290 @@ func (sc *Scanner) Summary() string {
 	return summary.String()
 }
 
+// Options holds the settings applied to each Scanner built by RunScan.
+type Options struct {
+	UseTLS   bool
+	Insecure bool
+
+	// SNI and HostHeader override the TLS server name and Host header,
+	// which otherwise derive from the target host.
+	SNI        string
+	HostHeader string
+
+	// ClientCert and ClientKey are PEM files for mTLS; CACert replaces the
+	// system roots for verifying the target.
+	ClientCert string
+	ClientKey  string
+	CACert     string
+
+	Confidence  float64
+	AIProvider  ai.Provider
+	ConfirmRuns int
+
+	// Upstream proxy; ProxyAuth is "user:pass" for basic auth.
+	Proxy        string
+	ProxyAuth    string
+	ProxyHeaders []string
+
+	Watchdog         time.Duration
+	PipelineBaseline int
+
+	// ProbeCount is the number of GPOST probes (see SetProbeCount).
+	ProbeCount int
+
+	CL0Paths   []string
+	SweepDelay time.Duration
+
+	// HTTP2 adds the HTTP/2 downgrade tests (H2.CL, H2.TE).
+	HTTP2 bool
+
+	// TimingSamples sends each payload this many times and compares timing
+	// distributions (see SetTimingSamples); below 2 is single-shot.
+	TimingSamples int
+
+	// BaselineSamples captures the baseline this many times and uses the
+	// median (see SetBaselineSamples); below 2 is a single baseline.
+	BaselineSamples int
+
+	// ChunkSweep also runs CL.TE and TE.CL with each chunk layout (see
+	// SetChunkSweep).
+	ChunkSweep bool
+
+	// ChunkExtension replaces the built-in extensions of the Chunk-Ext
+	// test (see SetChunkExtension).
+	ChunkExtension string
+
+	// Fuzz ends the scan with TestFuzz on this technique, sending at most
+	// FuzzVariants mutations (see SetFuzz).
+	Fuzz         string
+	FuzzVariants int
+
+	// Exhaustive sends every variant of multi-variant tests instead of
+	// stopping at the first high-confidence finding.
+	Exhaustive bool
+
+	// AISuggest and AIAdaptive ask the AI provider for payload strategies
+	// after the standard tests (see SetAISuggest).
+	AISuggest  bool
+	AIAdaptive bool
+
+	// Smuggled victim request (see SetSmuggledRequest); empty fields keep
+	// the per-test defaults.
+	SmuggleMethod  string
+	SmugglePath    string
+	SmuggleHeaders map[string]string
+
+	// BlockStatuses are status codes treated as block pages (see
+	// SetBlockStatuses).
+	BlockStatuses []int
+
+	// SignalWeights overrides the detector's signal weights (see
+	// SetSignalWeights).
+	SignalWeights detector.SignalWeights
+
+	// SeverityOverrides fixes the severity of listed techniques (see
+	// SetSeverityOverrides).
+	SeverityOverrides detector.SeverityOverrides
+
+	// ConnectTimeout and ReadTimeout override the sender's 10s defaults
+	// when non-zero.
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+
+	// FirstByteTimeout bounds the wait for the first response byte
+	// (0 uses the full read timeout).
+	FirstByteTimeout time.Duration
+
+	// Techniques restricts the scan to these technique identifiers (see
+	// SetEnabledTechniques); empty runs everything.
+	Techniques []string
+
+	// AutoTechniques runs only the techniques recommended for the stack.
+	AutoTechniques bool
+	ExplainTiming  bool
+
+	// IgnoreHeaders are excluded from header comparison in addition to the
+	// default volatile headers; CompareHeaders are removed from that set.
+	IgnoreHeaders  []string
+	CompareHeaders []string
+
+	// Step asks before every intrusive payload (interactive step mode).
+	Step StepFunc
+
+	// Stats, when set, aggregates live throughput across scans.
+	Stats *sender.Stats
+
+	// Limiter, when set, is shared by every scan and bounds the total
+	// request rate.
+	Limiter *sender.Limiter
+
+	// Resolver, when set, is shared by every scan so DNS lookups are cached
+	// for the whole run and -resolve pins apply.
+	Resolver *sender.Resolver
+
+	// CompareBaselineMethod checks whether GET and POST reach different
+	// back-ends before testing.
+	CompareBaselineMethod bool
+
+	// Rand is the seeded random source shared by randomized components.
+	Rand *rand.Rand
+
+	// StoreResponseBytes, when set, limits the response bytes kept on each
+	// result (see SetStoreResponseBytes); nil keeps the default.
+	// KeepFindingResponses keeps full responses on suspicious results.
+	StoreResponseBytes   *int
+	KeepFindingResponses bool
+
+	// RedactBodies strips response bodies from results (see
+	// SetRedactBodies).
+	RedactBodies bool
+
+	// DryRun prints payloads instead of sending them (see SetDryRun).
+	DryRun bool
+
+	// RequestHeaders are sent with the baseline and every test request
+	// (see SetRequestHeaders).
+	RequestHeaders map[string]string
+
+	// Output receives the scan's progress and report (default stdout).
+	Output io.Writer
+
+	// LogLevel sets how much progress is printed and Color colors the
+	// verdicts (see SetLogLevel and SetColor).
+	LogLevel logger.Level
+	Color    bool
+
+	// ResultHandler, if set, receives each result as it is recorded.
+	ResultHandler func(*models.ScanResult)
+
+	// ProgressHandler, if set, receives progress events instead of the
+	// output being printed (see SetProgressHandler).
+	ProgressHandler func(ScanEvent)
+
+	// Context, if set, cancels the scan when done (see SetContext).
+	Context context.Context
+}
+
 // RunFullScan is a convenience wrapper that configures and runs a full scan.
 func RunFullScan(target string, port int, useTLS, insecure bool, confidence float64, aiProvider ai.Provider) error {
-	s := NewScanner(target, port)
-	s.SetConfidenceThreshold(confidence)
-	if useTLS {
+	_, err := RunScan(target, port, Options{
+		UseTLS:     useTLS,
+		Insecure:   insecure,
+		Confidence: confidence,
+		AIProvider: aiProvider,
+	})
+	return err
+}
+
+// RunScan configures a Scanner from opts, runs the full workflow, prints the
+// report, and returns the scanner so callers can inspect its results.
+func RunScan(target string, port int, opts Options) (*Scanner, error) {
+	s := NewScanner(models.NewTarget(target, port, opts.UseTLS))
+	s.SetContext(opts.Context)
+	s.SetOutput(opts.Output)
+	s.SetLogLevel(opts.LogLevel)
+	s.SetColor(opts.Color)
+	s.SetConfidenceThreshold(opts.Confidence)
+	if opts.UseTLS {
 		s.SetTLS(true)
-		if insecure {
+		if opts.Insecure {
 			s.SetInsecureTLS(true)
 		}
 	}
-	if aiProvider != nil {
-		s.SetAIProvider(aiProvider)
+	if opts.SNI != "" {
+		s.SetServerName(opts.SNI)
+	}
+	if opts.HostHeader != "" {
+		s.SetHostHeader(opts.HostHeader)
+	}
+	if opts.ClientCert != "" {
+		if err := s.SetClientCert(opts.ClientCert, opts.ClientKey); err != nil {
+			return s, err
+		}
+	}
+	if opts.CACert != "" {
+		if err := s.SetCACert(opts.CACert); err != nil {
+			return s, err
+		}
+	}
+	if opts.AIProvider != nil {
+		s.SetAIProvider(opts.AIProvider)
+	}
+	s.SetConfirmRuns(opts.ConfirmRuns)
+	s.SetWatchdog(opts.Watchdog)
+	s.SetPipelineBaseline(opts.PipelineBaseline)
+	s.SetProbeCount(opts.ProbeCount)
+	s.SetRand(opts.Rand)
+	s.SetCL0Paths(opts.CL0Paths)
+	s.SetSweepDelay(opts.SweepDelay)
+	s.SetHTTP2(opts.HTTP2)
+	s.SetTimingSamples(opts.TimingSamples)
+	s.SetBaselineSamples(opts.BaselineSamples)
+	s.SetExhaustive(opts.Exhaustive)
+	s.SetChunkSweep(opts.ChunkSweep)
+	s.SetChunkExtension(opts.ChunkExtension)
+	s.SetFuzz(opts.Fuzz, opts.FuzzVariants)
+	s.SetAISuggest(opts.AISuggest, opts.AIAdaptive)
+	s.SetSmuggledRequest(opts.SmuggleMethod, opts.SmugglePath, opts.SmuggleHeaders)
+	s.SetTimeouts(opts.ConnectTimeout, opts.ReadTimeout)
+	s.SetFirstByteTimeout(opts.FirstByteTimeout)
+	s.SetBlockStatuses(opts.BlockStatuses)
+	s.SetSignalWeights(opts.SignalWeights)
+	s.SetSeverityOverrides(opts.SeverityOverrides)
+	s.SetResultHandler(opts.ResultHandler)
+	s.SetProgressHandler(opts.ProgressHandler)
+	if err := s.SetEnabledTechniques(opts.Techniques); err != nil {
+		return s, err
+	}
+	s.SetAutoTechniques(opts.AutoTechniques)
+	s.SetExplainTiming(opts.ExplainTiming)
+	s.IgnoreHeaders(opts.IgnoreHeaders...)
+	s.CompareHeaders(opts.CompareHeaders...)
+	s.SetStepFunc(opts.Step)
+	s.SetStats(opts.Stats)
+	s.SetLimiter(opts.Limiter)
+	s.SetCompareBaselineMethod(opts.CompareBaselineMethod)
+	s.SetResolver(opts.Resolver)
+	if opts.StoreResponseBytes != nil {
+		s.SetStoreResponseBytes(*opts.StoreResponseBytes)
+	}
+	s.SetKeepFindingResponses(opts.KeepFindingResponses)
+	s.SetRedactBodies(opts.RedactBodies)
+	s.SetDryRun(opts.DryRun)
+	s.SetRequestHeaders(opts.RequestHeaders)
+
+	if opts.Proxy != "" {
+		if err := s.SetProxy(opts.Proxy); err != nil {
+			return s, err
+		}
+		if opts.ProxyAuth != "" {
+			user, pass, _ := strings.Cut(opts.ProxyAuth, ":")
+			s.SetProxyAuth(user, pass)
+		}
+		for _, h := range opts.ProxyHeaders {
+			if err := s.AddProxyHeader(h); err != nil {
+				return s, err
+			}
+		}
 	}
 
 	if err := s.Run(); err != nil {
-		return err
+		return s, err
+	}
+	if s.dryRun {
+		return s, nil
 	}
 
 	s.PrintReport()
 
-	fmt.Printf("\n%s\n", s.Summary())
+	s.log.Printf("\n%s\n", s.Summary())
 
 	if s.IsVulnerable() {
-		fmt.Println("\n[!] VULNERABLE SERVER DETECTED")
-		fmt.Printf("[!] Most likely technique: %s\n", s.GetMostLikelyTechnique())
+		s.log.Printf("\n[!] VULNERABLE SERVER DETECTED\n")
+		s.log.Printf("[!] Most likely technique: %s\n", s.GetMostLikelyTechnique())
 	} else {
-		fmt.Println("\n[✓] No vulnerabilities detected")
+		s.log.Printf("\n[✓] No vulnerabilities detected\n")
 	}
 
-	return nil
+	return s, nil
 }
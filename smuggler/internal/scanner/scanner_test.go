@@ -0,0 +1,126 @@
+package scanner
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"smuggler/internal/models"
+)
+
+// okResponse is what testServer answers a request with by default.
+const okResponse = "HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nok"
+
+// testServer serves each connection's first request with reply(request) and
+// closes it. An empty reply leaves the request unanswered until the client
+// gives up. It returns the scanner for the server and the requests received
+// so far.
+func testServer(t *testing.T, reply func(request string) string) (*Scanner, func() []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	var requests []string
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+				buf := make([]byte, 64*1024)
+				n, _ := conn.Read(buf)
+				request := string(buf[:n])
+				mu.Lock()
+				requests = append(requests, request)
+				mu.Unlock()
+
+				if resp := reply(request); resp != "" {
+					conn.Write([]byte(resp))
+					return
+				}
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	host, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	sc := NewScanner(models.NewTarget(host, port, false)).SetOutput(io.Discard)
+	sc.SetTimeouts(time.Second, 300*time.Millisecond)
+
+	return sc, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requests...)
+	}
+}
+
+func TestTECLTimeoutIsAnalysed(t *testing.T) {
+	// The back-end waits for the chunked body TE.CL leaves unterminated,
+	// so the probe times out with no response.
+	sc, _ := testServer(t, func(request string) string {
+		if strings.Contains(request, "Transfer-Encoding") {
+			return ""
+		}
+		return okResponse
+	})
+
+	if err := sc.CaptureBaseline(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.TestTECL(); err != nil {
+		t.Fatalf("TestTECL: %v", err)
+	}
+
+	results := sc.GetResults()
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	result := results[0]
+	if strings.HasPrefix(result.Reason, "Request not fully delivered") {
+		t.Errorf("timed-out probe recorded as undelivered: %s", result.Reason)
+	}
+	if result.TestResponse == nil || result.TestResponse.Raw != "" {
+		t.Fatalf("test response = %+v, want the empty timed-out response", result.TestResponse)
+	}
+	if result.TestResponse.TimingMS < 300 {
+		t.Errorf("test response timing = %d ms, want at least the 300 ms read timeout", result.TestResponse.TimingMS)
+	}
+}
+
+func TestMixedTECarriesRequestHeaders(t *testing.T) {
+	sc, requests := testServer(t, func(string) string { return okResponse })
+	sc.SetRequestHeaders(map[string]string{"X-Scan-Id": "mixed-te-test"})
+
+	if err := sc.CaptureBaseline(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.TestMixedTE(); err != nil {
+		t.Fatalf("TestMixedTE: %v", err)
+	}
+
+	var mixed string
+	for _, r := range requests() {
+		if strings.Contains(r, "Transfer-Encoding: identity") {
+			mixed = r
+		}
+	}
+	if mixed == "" {
+		t.Fatal("no Mixed-TE request captured")
+	}
+	headers, _, _ := strings.Cut(mixed, "\r\n\r\n")
+	if !strings.Contains(headers, "\r\nX-Scan-Id: mixed-te-test\r\n") {
+		t.Errorf("Mixed-TE request headers lack the -header value:\n%s", headers)
+	}
+}
@@ -0,0 +1,82 @@
+package scanner
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"smuggler/internal/logger"
+)
+
+// ErrStepAborted is returned when the operator declines a payload in step
+// mode. The scan stops, but results gathered so far are still reported.
+var ErrStepAborted = errors.New("scan aborted by operator")
+
+// StepDecision is the operator's answer before an intrusive payload is sent.
+type StepDecision int
+
+const (
+	StepAbort StepDecision = iota // stop the scan
+	StepSend                      // send the payload
+	StepSkip                      // skip this test and continue
+)
+
+// StepFunc is consulted before each intrusive payload is sent.
+type StepFunc func(test, payload string) StepDecision
+
+// PromptStep returns a StepFunc that prints each payload to out and asks
+// "send next payload? [y/N/skip]" on in. Anything other than y or skip
+// aborts, so an accidental Enter never sends a payload.
+func PromptStep(in io.Reader, out io.Writer) StepFunc {
+	reader := bufio.NewReader(in)
+
+	return func(test, payload string) StepDecision {
+		fmt.Fprintf(out, "\n    [step] %s payload:\n", test)
+		for _, line := range strings.Split(payload, "\r\n") {
+			fmt.Fprintf(out, "      | %s\n", line)
+		}
+		fmt.Fprintf(out, "    send next payload? [y/N/skip] ")
+
+		answer, _ := reader.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return StepSend
+		case "s", "skip":
+			return StepSkip
+		default:
+			return StepAbort
+		}
+	}
+}
+
+// SetStepFunc enables step mode: f is asked before every intrusive payload.
+func (sc *Scanner) SetStepFunc(f StepFunc) *Scanner {
+	sc.step = f
+	return sc
+}
+
+// approve asks the step function whether to send payload. It reports false
+// when the test should be skipped and returns ErrStepAborted on abort. In a
+// dry run the payload is printed and never sent.
+func (sc *Scanner) approve(test, payload string) (bool, error) {
+	if sc.dryRun {
+		sc.printDryRun(test, payload)
+		return false, nil
+	}
+	sc.printPayload(logger.LevelDebug, "debug", test+" payload", payload)
+	if sc.step == nil {
+		return true, nil
+	}
+
+	switch sc.step(test, payload) {
+	case StepSend:
+		return true, nil
+	case StepSkip:
+		sc.log.Infof("    Skipped by operator\n")
+		return false, nil
+	default:
+		return false, ErrStepAborted
+	}
+}
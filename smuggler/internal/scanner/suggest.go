@@ -0,0 +1,227 @@
+package scanner
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"smuggler/internal/ai"
+	"smuggler/internal/detector"
+)
+
+// SetAISuggest asks the AI provider for payload strategies once the
+// standard tests have run and prints them. With adaptive set, suggested
+// techniques that were not part of the run are tested in a second round.
+// Both need an AI provider (see SetAIProvider).
+func (sc *Scanner) SetAISuggest(suggest, adaptive bool) *Scanner {
+	sc.aiSuggest = suggest || adaptive
+	sc.aiAdaptive = adaptive
+	return sc
+}
+
+// aiResultSummary condenses the results so far into one entry per
+// technique, the shape handed to SuggestPayloads and IdentifyTechnique.
+func (sc *Scanner) aiResultSummary() map[string]map[string]interface{} {
+	summary := make(map[string]map[string]interface{})
+	for _, r := range detector.Dedup(sc.results) {
+		entry := map[string]interface{}{
+			"suspicious": r.Suspicious,
+			"confidence": r.GetConfidence(),
+		}
+		if r.Reason != "" {
+			entry["reason"] = r.Reason
+		}
+		if len(r.Signals) > 0 {
+			entry["signals"] = r.Signals
+		}
+		if r.TestResponse != nil {
+			entry["status"] = r.TestResponse.StatusCode
+		}
+		if r.Blocked {
+			entry["blocked"] = true
+		}
+		if r.WAFDetected {
+			entry["waf"] = r.WAFNote
+		}
+
+		// Keep the strongest result when several collapse to one name.
+		if prev, ok := summary[r.Technique]; ok && prev["confidence"].(float64) >= r.GetConfidence() {
+			continue
+		}
+		summary[r.Technique] = entry
+	}
+	return summary
+}
+
+// aiTargetInfo describes the target for SuggestPayloads.
+func (sc *Scanner) aiTargetInfo() map[string]string {
+	info := map[string]string{
+		"host": sc.target.Host,
+		"port": strconv.Itoa(sc.target.Port),
+		"tls":  strconv.FormatBool(sc.target.TLS),
+	}
+	if sc.stackInfo.Server != "" {
+		info["server"] = sc.stackInfo.Server
+	}
+	if sc.stackInfo.PipelineTested {
+		info["pipelining"] = strconv.FormatBool(sc.stackInfo.PipelineSupported)
+	}
+	if sc.stackInfo.MethodRouting != "" {
+		info["method_routing"] = sc.stackInfo.MethodRouting
+	}
+	return info
+}
+
+// runAISuggestions asks the AI provider for payload strategies based on
+// the results so far and prints them. In adaptive mode the suggestions
+// that map to a technique this run skipped are tested as a second round.
+// AI errors are reported and otherwise ignored.
+func (sc *Scanner) runAISuggestions() error {
+	previous := make(map[string]interface{})
+	for technique, entry := range sc.aiResultSummary() {
+		previous[technique] = entry
+	}
+
+	sc.log.Infof("\n[*] Asking %s for payload suggestions...\n", sc.aiProvider.Name())
+
+	suggestions, err := sc.aiProvider.SuggestPayloads(sc.ctx, sc.aiTargetInfo(), previous)
+	if err != nil {
+		sc.log.Infof("    [AI Suggestion Error: %v]\n", err)
+		return nil
+	}
+	if len(suggestions) == 0 {
+		sc.log.Infof("    No suggestions returned\n")
+		return nil
+	}
+
+	sortSuggestions(suggestions)
+	for i, s := range suggestions {
+		if s == nil {
+			continue
+		}
+		sc.log.Infof("    %d. %s", i+1, s.Technique)
+		if s.Priority != "" {
+			sc.log.Infof(" [%s priority]", s.Priority)
+		}
+		sc.log.Infof("\n")
+		if s.Description != "" {
+			sc.log.Infof("       %s\n", s.Description)
+		}
+		if s.PayloadStrategy != "" {
+			sc.log.Infof("       Strategy: %s\n", s.PayloadStrategy)
+		}
+		if s.Rationale != "" {
+			sc.log.Infof("       Rationale: %s\n", s.Rationale)
+		}
+	}
+
+	if !sc.aiAdaptive {
+		return nil
+	}
+
+	ran := make(map[string]bool)
+	for _, step := range sc.techniqueSteps() {
+		ran[step.name] = true
+	}
+
+	var round []scanStep
+	queued := make(map[string]bool)
+	for _, s := range suggestions {
+		if s == nil {
+			continue
+		}
+		step, ok := matchSuggestedStep(s.Technique, sc.allTechniqueSteps())
+		switch {
+		case !ok:
+			sc.log.Infof("    [-] %s: no matching test, skipped\n", s.Technique)
+		case ran[step.name]:
+			sc.log.Infof("    [-] %s: already tested as %s\n", s.Technique, step.name)
+		case !queued[step.name]:
+			queued[step.name] = true
+			round = append(round, step)
+		}
+	}
+
+	if len(round) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(round))
+	for i, step := range round {
+		names[i] = step.name
+	}
+	sc.log.Infof("\n[*] AI adaptive round: %s\n", strings.Join(names, ", "))
+
+	_, err = sc.runSteps(round)
+	return err
+}
+
+// identifyTechnique asks the AI provider which technique the results point
+// to and records its answer on the report next to the detector's choice.
+func (sc *Scanner) identifyTechnique() {
+	summary := sc.aiResultSummary()
+	if len(summary) == 0 || sc.ctx.Err() != nil {
+		return
+	}
+
+	technique, confidence, err := sc.aiProvider.IdentifyTechnique(sc.ctx, summary)
+	if err != nil {
+		sc.log.Infof("    [AI Identification Error: %v]\n", err)
+		return
+	}
+	sc.report.AITechnique = technique
+	sc.report.AIConfidence = confidence
+}
+
+// suggestionRank orders priorities from most to least urgent; unknown
+// priorities sort last.
+var suggestionRank = map[string]int{
+	"critical": 0,
+	"high":     1,
+	"medium":   2,
+	"low":      3,
+}
+
+// sortSuggestions orders suggestions by priority, keeping the provider's
+// order within a priority.
+func sortSuggestions(suggestions []*ai.PayloadSuggestion) {
+	rank := func(s *ai.PayloadSuggestion) int {
+		if s == nil {
+			return len(suggestionRank) + 1
+		}
+		if r, ok := suggestionRank[strings.ToLower(strings.TrimSpace(s.Priority))]; ok {
+			return r
+		}
+		return len(suggestionRank)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return rank(suggestions[i]) < rank(suggestions[j])
+	})
+}
+
+// matchSuggestedStep maps a technique named by the AI to one of steps.
+// Names are compared without case or punctuation, so "cl.te", "CL-TE" and
+// the technique id "cl-te" all match; failing that, the longest step name
+// contained in the suggestion wins ("TE.CL with chunk extensions").
+func matchSuggestedStep(technique string, steps []scanStep) (scanStep, bool) {
+	want := detector.NormalizeTechnique(detector.TechniqueFamily(technique))
+	if want == "" {
+		return scanStep{}, false
+	}
+
+	for _, step := range steps {
+		if detector.NormalizeTechnique(step.name) == want || detector.NormalizeTechnique(step.id) == want {
+			return step, true
+		}
+	}
+
+	var best scanStep
+	found := false
+	for _, step := range steps {
+		name := detector.NormalizeTechnique(step.name)
+		if strings.Contains(want, name) && (!found || len(name) > len(detector.NormalizeTechnique(best.name))) {
+			best, found = step, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,101 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// watchdog tracks scan progress for a single target. If no test completes
+// within the interval it logs a warning; if the next interval also passes
+// without progress it cancels the target's context.
+type watchdog struct {
+	interval time.Duration
+	cancel   context.CancelFunc
+	out      io.Writer
+
+	mu           sync.Mutex
+	current      string
+	lastProgress time.Time
+	stalled      bool
+	done         chan struct{}
+}
+
+func newWatchdog(interval time.Duration, cancel context.CancelFunc, out io.Writer) *watchdog {
+	return &watchdog{
+		interval:     interval,
+		cancel:       cancel,
+		out:          out,
+		lastProgress: time.Now(),
+		done:         make(chan struct{}),
+	}
+}
+
+// start runs the watchdog loop until stopped or the stall limit is reached.
+func (w *watchdog) start() {
+	go func() {
+		tick := w.interval / 2
+		if tick <= 0 {
+			tick = w.interval
+		}
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+
+		warned := false
+
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+			}
+
+			w.mu.Lock()
+			idle := time.Since(w.lastProgress)
+			current := w.current
+			w.mu.Unlock()
+
+			switch {
+			case idle >= 2*w.interval:
+				fmt.Fprintf(w.out, "    [!] Watchdog: no progress for %s during %s, cancelling target\n",
+					idle.Round(time.Second), current)
+				w.mu.Lock()
+				w.stalled = true
+				w.mu.Unlock()
+				w.cancel()
+				return
+			case idle >= w.interval && !warned:
+				fmt.Fprintf(w.out, "    [!] Watchdog: no progress for %s during %s\n",
+					idle.Round(time.Second), current)
+				warned = true
+			case idle < w.interval:
+				warned = false
+			}
+		}
+	}()
+}
+
+// progress records that a test, variant or send completed and names the
+// one now running. An empty name keeps the current one, for progress within
+// a test such as a timing sample.
+func (w *watchdog) progress(test string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if test != "" {
+		w.current = test
+	}
+	w.lastProgress = time.Now()
+}
+
+// state reports whether the watchdog cancelled the target, and during which test.
+func (w *watchdog) state() (bool, string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stalled, w.current
+}
+
+func (w *watchdog) halt() {
+	close(w.done)
+}
@@ -0,0 +1,45 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"smuggler/internal/detector"
+	"smuggler/internal/payload"
+)
+
+func TestWatchdogSparesSlowMultiVariantStep(t *testing.T) {
+	// Each variant answers well within the interval, but the whole
+	// Obfuscated-TE step takes several intervals.
+	sc, _ := testServer(t, func(string) string {
+		time.Sleep(60 * time.Millisecond)
+		return okResponse
+	})
+	if err := sc.SetEnabledTechniques([]string{detector.TechObfuscatedTE}); err != nil {
+		t.Fatal(err)
+	}
+	sc.SetWatchdog(100 * time.Millisecond)
+
+	variants := len(payload.ObfuscationPatterns) + len(payload.WhitespaceObfuscations)
+	if step := time.Duration(variants) * 60 * time.Millisecond; step < 400*time.Millisecond {
+		t.Fatalf("step takes about %v, too short to outlast the watchdog", step)
+	}
+
+	results, _, err := sc.ScanResults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran := 0
+	for _, r := range results {
+		if strings.HasPrefix(r.Reason, "Target stalled") {
+			t.Fatalf("watchdog cancelled the step: %s", r.Reason)
+		}
+		if strings.HasPrefix(r.Technique, "Obfuscated-TE[") {
+			ran++
+		}
+	}
+	if ran != variants {
+		t.Errorf("%d Obfuscated-TE variants ran, want all %d", ran, variants)
+	}
+}
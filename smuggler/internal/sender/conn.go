@@ -0,0 +1,187 @@
+package sender
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"smuggler/internal/models"
+)
+
+// ErrConnClosed is returned by Conn.Send once the server has closed the
+// connection.
+var ErrConnClosed = errors.New("connection closed by server")
+
+// Conn is a persistent connection for sending several requests over the
+// same socket, so state left behind by one request (such as a smuggled
+// prefix) is seen by the next. Each Send reads exactly one response, using
+// its Content-Length or chunked framing, instead of waiting for EOF.
+type Conn struct {
+	rs      *RawSender
+	target  string
+	conn    net.Conn
+	reader  *bufio.Reader
+	pending string
+	timing  DialTiming
+	closed  bool
+}
+
+// OpenConn opens a persistent connection to target.
+func (rs *RawSender) OpenConn(target string) (*Conn, error) {
+	return rs.OpenConnContext(context.Background(), target)
+}
+
+// OpenConnContext is like OpenConn but abandons the dial, including any
+// proxy CONNECT and TLS handshake, as soon as ctx is done. ctx only bounds
+// the opening; each send takes its own.
+func (rs *RawSender) OpenConnContext(ctx context.Context, target string) (*Conn, error) {
+	conn, timing, err := rs.dial(ctx, target)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, abortError(target, ctx.Err())
+		}
+		return nil, connectError(target, err)
+	}
+
+	bufSize := rs.readBufferSize
+	if bufSize <= 0 {
+		bufSize = DefaultReadBufferSize
+	}
+
+	return &Conn{
+		rs:     rs,
+		target: target,
+		conn:   conn,
+		reader: bufio.NewReaderSize(conn, bufSize),
+		timing: timing,
+	}, nil
+}
+
+// Closed reports whether the server has closed the connection.
+func (c *Conn) Closed() bool {
+	return c.closed
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	c.closed = true
+	return c.conn.Close()
+}
+
+// Send writes payload and reads the next response on the connection. Bytes
+// that arrive beyond that response are kept for the following Send. A
+// response without length framing is read until the server closes the
+// connection or the read timeout expires.
+func (c *Conn) Send(payload string) (*models.HTTPResponse, error) {
+	return c.SendContext(context.Background(), payload)
+}
+
+// SendContext is like Send but gives up as soon as ctx is done, whether
+// still waiting on the rate limiter or mid-exchange. A connection
+// interrupted mid-exchange is closed, since its framing is lost.
+func (c *Conn) SendContext(ctx context.Context, payload string) (*models.HTTPResponse, error) {
+	if c.closed {
+		return nil, ErrConnClosed
+	}
+
+	response := &models.HTTPResponse{
+		Headers: make(map[string][]string),
+	}
+	if err := c.rs.wait(ctx); err != nil {
+		response.Error = abortError(c.target, err)
+		return response, response.Error
+	}
+	startTime := time.Now()
+	c.rs.stats.addRequests(1)
+
+	stop := context.AfterFunc(ctx, func() { c.conn.Close() })
+	defer stop()
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.rs.timeout))
+	if err := c.rs.writePayload(c.conn, []byte(payload)); err != nil {
+		c.closed = true
+		if ctx.Err() != nil {
+			response.Error = abortError(c.target, ctx.Err())
+		} else {
+			response.Error = writeError(c.target, err)
+		}
+		return response, response.Error
+	}
+
+	sentAt := time.Now()
+	c.conn.SetReadDeadline(sentAt.Add(c.rs.readTimeout))
+
+	raw, firstByte, readErr := c.readOne()
+	response.Raw = raw
+	response.TimingMS = time.Since(startTime).Milliseconds()
+	if c.timing.Connect > 0 {
+		// the dial cost belongs to the first request only
+		response.ConnectMS = c.timing.Connect.Milliseconds()
+		response.TLSMS = c.timing.TLS.Milliseconds()
+		c.timing = DialTiming{}
+	}
+	recordTLS(response, c.conn)
+	if tlsRejected(raw, readErr) {
+		c.closed = true
+		response.Error = rejectedError(c.target, readErr)
+		return response, response.Error
+	}
+	if !firstByte.IsZero() {
+		response.TTFBMS = firstByte.Sub(sentAt).Milliseconds()
+	}
+
+	if closedByPeer(readErr) {
+		c.closed = true
+		response.ConnectionClosed = true
+	}
+
+	parseHTTPResponse(response)
+
+	if ctx.Err() != nil {
+		c.closed = true
+		response.ConnectionClosed = false
+		response.Error = abortError(c.target, ctx.Err())
+		return response, response.Error
+	}
+	if raw == "" && c.closed {
+		response.Error = &SendError{Kind: KindRead, Target: c.target, Err: ErrConnClosed}
+		return response, response.Error
+	}
+	return response, nil
+}
+
+// readOne returns the next complete response from the connection, along
+// with the arrival time of its first byte.
+func (c *Conn) readOne() (string, time.Time, error) {
+	var buf strings.Builder
+	var firstByte time.Time
+
+	buf.WriteString(c.pending)
+	c.pending = ""
+	if buf.Len() > 0 {
+		firstByte = time.Now()
+	}
+
+	tmp := make([]byte, c.reader.Size())
+	for {
+		if n := responseLength(buf.String()); n > 0 {
+			raw := buf.String()
+			c.pending = raw[n:]
+			return raw[:n], firstByte, nil
+		}
+
+		n, err := c.reader.Read(tmp)
+		if n > 0 {
+			if firstByte.IsZero() {
+				firstByte = time.Now()
+			}
+			buf.Write(tmp[:n])
+		}
+		if err != nil {
+			return buf.String(), firstByte, err
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnSendReusesConnection(t *testing.T) {
+	addr := replyServer(t, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+	rs := NewRawSenderWithTimeout(2*time.Second, 2*time.Second)
+
+	conn, err := rs.OpenConn(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 1; i <= 2; i++ {
+		resp, err := conn.Send("GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+		if err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+		if resp.StatusCode != 200 || resp.Body != "ok" {
+			t.Errorf("send %d: got %d %q, want 200 \"ok\"", i, resp.StatusCode, resp.Body)
+		}
+	}
+	if conn.Closed() {
+		t.Error("keep-alive connection reported closed")
+	}
+}
+
+func TestConnSendLimiterCancelled(t *testing.T) {
+	addr := replyServer(t, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+	rs := NewRawSender().SetLimiter(NewDelayLimiter(time.Hour))
+
+	conn, err := rs.OpenConn(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The first send takes the limiter's free slot; the second would wait
+	// an hour for the next one.
+	if _, err := conn.Send("GET / HTTP/1.1\r\nHost: test\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = conn.SendContext(ctx, "GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+	if !errors.Is(err, ErrAborted) {
+		t.Fatalf("err = %v, want ErrAborted from the cancelled limiter wait", err)
+	}
+	if conn.Closed() {
+		t.Error("connection closed by a send that never started")
+	}
+}
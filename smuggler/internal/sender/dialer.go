@@ -0,0 +1,309 @@
+package sender
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// Dialer opens the connection a request is written to, either directly, through
+// an upstream HTTP(S) proxy using CONNECT, or through a SOCKS5 proxy.
+// Tunnelling is used even for plain-HTTP targets so the proxy never
+// rewrites the raw payload.
+type Dialer struct {
+	timeout      time.Duration
+	proxy        *url.URL
+	proxyAuth    *url.Userinfo
+	proxyHeaders []string
+	resolver     *Resolver
+}
+
+func NewDialer(timeout time.Duration) *Dialer {
+	return &Dialer{timeout: timeout, resolver: NewResolver(0)}
+}
+
+// SetTimeout sets the connect timeout, which also bounds the proxy CONNECT
+// exchange and the TLS handshake.
+func (d *Dialer) SetTimeout(timeout time.Duration) *Dialer {
+	d.timeout = timeout
+	return d
+}
+
+// SetResolver replaces the dialer's resolver, typically with one shared by
+// every dialer in a run so lookups are cached across targets.
+func (d *Dialer) SetResolver(r *Resolver) *Dialer {
+	if r != nil {
+		d.resolver = r
+	}
+	return d
+}
+
+// dialDirect resolves the target host through the resolver cache and
+// connects to its addresses in order until one accepts. The attempts share
+// the connect timeout, each getting an equal part of what is left, so an
+// unreachable first address does not use up the whole budget.
+func (d *Dialer) dialDirect(ctx context.Context, target string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := d.resolver.ResolveAll(ctx, host)
+	if err != nil {
+		return nil, &resolveError{host: host, err: err}
+	}
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+
+	var firstErr error
+	for i, addr := range addrs {
+		nd := &net.Dialer{}
+		if deadline, ok := ctx.Deadline(); ok {
+			nd.Deadline = time.Now().Add(time.Until(deadline) / time.Duration(len(addrs)-i))
+		}
+		conn, err := nd.DialContext(ctx, "tcp", net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// SetProxy routes connections through the given proxy URL: http:// and
+// https:// proxies are tunnelled with CONNECT (over TLS to the proxy for
+// https://), socks5:// proxies with a SOCKS5 CONNECT. Credentials embedded
+// in the URL are sent as basic Proxy-Authorization or SOCKS5
+// username/password authentication.
+func (d *Dialer) SetProxy(proxyURL string) error {
+	if proxyURL == "" {
+		d.proxy = nil
+		return nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL %q: %w", proxyURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q (use http://, https:// or socks5://)", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy URL %q has no host", proxyURL)
+	}
+
+	if u.User != nil && d.proxyAuth == nil {
+		d.proxyAuth = u.User
+	}
+	d.proxy = u
+	return nil
+}
+
+// SetProxyAuth sets basic proxy credentials, overriding any in the proxy URL.
+func (d *Dialer) SetProxyAuth(user, pass string) *Dialer {
+	d.proxyAuth = url.UserPassword(user, pass)
+	return d
+}
+
+// AddProxyHeader adds a raw "Name: Value" header to the CONNECT request,
+// e.g. "Proxy-Authorization: Bearer <token>".
+func (d *Dialer) AddProxyHeader(header string) error {
+	if !strings.Contains(header, ":") {
+		return fmt.Errorf("invalid proxy header %q (expected \"Name: Value\")", header)
+	}
+	d.proxyHeaders = append(d.proxyHeaders, strings.TrimSpace(header))
+	return nil
+}
+
+// ProxyAddr returns the configured proxy host:port, or "" for direct dialing.
+func (d *Dialer) ProxyAddr() string {
+	if d.proxy == nil {
+		return ""
+	}
+	return d.proxy.Host
+}
+
+// DialTiming records how long each phase of opening a connection took.
+type DialTiming struct {
+	Connect time.Duration // TCP connect, including any proxy CONNECT
+	TLS     time.Duration // TLS handshake, zero for plain connections
+}
+
+// Dial connects to target, wrapping the connection in TLS when tlsConfig is
+// set, and reports how long the connect and handshake phases took.
+func (d *Dialer) Dial(target string, tlsConfig *tls.Config) (net.Conn, DialTiming, error) {
+	return d.DialContext(context.Background(), target, tlsConfig)
+}
+
+// DialContext is like Dial but aborts the connect, any proxy CONNECT
+// exchange and the TLS handshake when ctx is done.
+func (d *Dialer) DialContext(ctx context.Context, target string, tlsConfig *tls.Config) (net.Conn, DialTiming, error) {
+	var timing DialTiming
+	start := time.Now()
+
+	var conn net.Conn
+	var err error
+	switch {
+	case d.proxy == nil:
+		conn, err = d.dialDirect(ctx, target)
+	case d.proxy.Scheme == "socks5":
+		conn, err = d.dialSOCKS5(ctx, target)
+	default:
+		conn, err = d.dialConnect(ctx, target)
+	}
+	if err != nil {
+		return nil, timing, err
+	}
+	timing.Connect = time.Since(start)
+
+	if tlsConfig == nil {
+		return conn, timing, nil
+	}
+
+	cfg := tlsConfig.Clone()
+	if cfg.ServerName == "" {
+		host, _, _ := net.SplitHostPort(target)
+		cfg.ServerName = host
+	}
+
+	handshakeStart := time.Now()
+	tlsConn := tls.Client(conn, cfg)
+	tlsConn.SetDeadline(time.Now().Add(d.timeout))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		if d.proxy != nil {
+			return nil, timing, fmt.Errorf("TLS handshake through proxy failed: %w", err)
+		}
+		return nil, timing, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	tlsConn.SetDeadline(time.Time{})
+	timing.TLS = time.Since(handshakeStart)
+
+	return tlsConn, timing, nil
+}
+
+// dialConnect opens a CONNECT tunnel to target through the HTTP proxy,
+// speaking TLS to the proxy itself when its scheme is https.
+func (d *Dialer) dialConnect(ctx context.Context, target string) (net.Conn, error) {
+	nd := &net.Dialer{Timeout: d.timeout}
+	conn, err := nd.DialContext(ctx, "tcp", d.proxy.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy %s: %w", d.proxy.Host, err)
+	}
+
+	conn.SetDeadline(time.Now().Add(d.timeout))
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if d.proxy.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName: d.proxy.Hostname(),
+			MinVersion: tls.VersionTLS12,
+		})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake with proxy %s failed: %w", d.proxy.Host, err)
+		}
+		conn = tlsConn
+	}
+
+	var req strings.Builder
+	fmt.Fprintf(&req, "CONNECT %s HTTP/1.1\r\n", target)
+	fmt.Fprintf(&req, "Host: %s\r\n", target)
+	if d.proxyAuth != nil {
+		pass, _ := d.proxyAuth.Password()
+		cred := base64.StdEncoding.EncodeToString([]byte(d.proxyAuth.Username() + ":" + pass))
+		fmt.Fprintf(&req, "Proxy-Authorization: Basic %s\r\n", cred)
+	}
+	for _, h := range d.proxyHeaders {
+		fmt.Fprintf(&req, "%s\r\n", h)
+	}
+	req.WriteString("\r\n")
+
+	if _, err := conn.Write([]byte(req.String())); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT to proxy: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read proxy CONNECT response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		conn.Close()
+
+		if resp.StatusCode == http.StatusProxyAuthRequired {
+			return nil, fmt.Errorf("proxy authentication rejected (407): %s",
+				strings.TrimSpace(string(body)))
+		}
+		return nil, fmt.Errorf("proxy CONNECT to %s failed: %s: %s",
+			target, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// dialSOCKS5 connects to target through the SOCKS5 proxy. The target host
+// name is sent to the proxy unresolved, so DNS happens on the proxy's side
+// of the tunnel.
+func (d *Dialer) dialSOCKS5(ctx context.Context, target string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if d.proxyAuth != nil {
+		pass, _ := d.proxyAuth.Password()
+		auth = &proxy.Auth{User: d.proxyAuth.Username(), Password: pass}
+	}
+
+	socks, err := proxy.SOCKS5("tcp", d.proxy.Host, auth, &net.Dialer{Timeout: d.timeout})
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOCKS5 proxy %s: %w", d.proxy.Host, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	conn, err := socks.(proxy.ContextDialer).DialContext(ctx, "tcp", target)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS5 proxy %s failed to connect to %s: %w", d.proxy.Host, target, err)
+	}
+	return conn, nil
+}
+
+// bufferedConn drains bytes the proxy sent past the CONNECT response
+// before reading from the underlying connection.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,171 @@
+package sender
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+)
+
+// ErrorKind classifies why a send failed, so callers can tell an
+// unreachable host from a target that stopped reading or answering.
+type ErrorKind string
+
+const (
+	KindResolve ErrorKind = "resolve" // DNS lookup failed
+	KindConnect ErrorKind = "connect" // TCP, proxy or TLS setup failed, including connect timeouts
+	KindWrite   ErrorKind = "write"   // the server closed or reset the connection while the request was written
+	KindRead    ErrorKind = "read"    // the server closed the connection without answering
+	KindTimeout ErrorKind = "timeout" // a deadline passed: the server stopped reading the request or never answered it
+	KindAborted ErrorKind = "aborted" // the context was cancelled
+)
+
+// Sentinels matching a SendError of the same kind with errors.Is.
+var (
+	ErrResolve = errors.New("resolve failed")
+	ErrConnect = errors.New("connect failed")
+	ErrWrite   = errors.New("write failed")
+	ErrRead    = errors.New("read failed")
+	ErrTimeout = errors.New("timed out")
+	ErrAborted = errors.New("aborted")
+)
+
+// ErrNoResponse is wrapped by the KindRead or KindTimeout error of a send
+// whose request was written but got nothing back. The response returned
+// with it still records the timing and whether the connection was closed,
+// which is what timing-based detection looks at.
+var ErrNoResponse = errors.New("no response")
+
+var kindSentinels = map[ErrorKind]error{
+	KindResolve: ErrResolve,
+	KindConnect: ErrConnect,
+	KindWrite:   ErrWrite,
+	KindRead:    ErrRead,
+	KindTimeout: ErrTimeout,
+	KindAborted: ErrAborted,
+}
+
+// SendError is returned by the senders when a request could not be
+// completed. errors.Is matches it against the sentinel of its kind (and
+// against whatever Err wraps), so
+//
+//	if errors.Is(err, sender.ErrTimeout) { ... }
+//
+// works on any error a sender returns.
+type SendError struct {
+	Kind   ErrorKind
+	Target string
+	Err    error
+}
+
+func (e *SendError) Error() string {
+	switch e.Kind {
+	case KindResolve, KindConnect:
+		return fmt.Sprintf("failed to connect to %s: %v", e.Target, e.Err)
+	case KindAborted:
+		return fmt.Sprintf("request to %s aborted: %v", e.Target, e.Err)
+	case KindRead:
+		return fmt.Sprintf("failed to read response: %v", e.Err)
+	default:
+		return fmt.Sprintf("failed to send request: %v", e.Err)
+	}
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel for e's kind.
+func (e *SendError) Is(target error) bool {
+	return kindSentinels[e.Kind] == target
+}
+
+// Timeout reports whether the send failed on a write or read deadline.
+func (e *SendError) Timeout() bool {
+	return e.Kind == KindTimeout
+}
+
+// KindOf returns the kind of a SendError anywhere in err's chain, or ""
+// when err did not come from a sender.
+func KindOf(err error) ErrorKind {
+	var se *SendError
+	if errors.As(err, &se) {
+		return se.Kind
+	}
+	return ""
+}
+
+// resolveError marks a failed lookup in the dial chain.
+type resolveError struct {
+	host string
+	err  error
+}
+
+func (e *resolveError) Error() string {
+	return fmt.Sprintf("failed to resolve %s: %v", e.host, e.err)
+}
+
+func (e *resolveError) Unwrap() error {
+	return e.err
+}
+
+// connectError classifies a dial failure.
+func connectError(target string, err error) *SendError {
+	var re *resolveError
+	if errors.As(err, &re) {
+		return &SendError{Kind: KindResolve, Target: target, Err: err}
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return &SendError{Kind: KindResolve, Target: target, Err: err}
+	}
+	return &SendError{Kind: KindConnect, Target: target, Err: err}
+}
+
+// writeError classifies a failed request write.
+func writeError(target string, err error) *SendError {
+	if isTimeout(err) {
+		return &SendError{Kind: KindTimeout, Target: target, Err: err}
+	}
+	return &SendError{Kind: KindWrite, Target: target, Err: err}
+}
+
+// abortError wraps the context error of a cancelled send.
+func abortError(target string, err error) *SendError {
+	return &SendError{Kind: KindAborted, Target: target, Err: err}
+}
+
+// noResponseError reports a request that was written but not answered: a
+// read timeout, or the server closing or resetting the connection.
+func noResponseError(target string, readErr error) *SendError {
+	kind := KindRead
+	if isTimeout(readErr) {
+		kind = KindTimeout
+	}
+	return &SendError{Kind: kind, Target: target, Err: fmt.Errorf("%w: %w", ErrNoResponse, readErr)}
+}
+
+// isTimeout reports whether err is a deadline expiry.
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// closedByPeer reports whether a read error means the connection is gone:
+// EOF, a reset or an already-closed socket. A read deadline expiring means
+// the server kept the connection open and is not counted. Any other error
+// leaves the connection unusable and is treated as closed.
+func closedByPeer(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EPIPE), errors.Is(err, net.ErrClosed):
+		return true
+	case isTimeout(err):
+		return false
+	}
+	return true
+}
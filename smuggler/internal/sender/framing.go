@@ -0,0 +1,241 @@
+package sender
+
+import (
+	"strconv"
+	"strings"
+)
+
+// headerBoundary locates the blank line ending the header section. Besides
+// CRLF it accepts bare-LF and bare-CR framing, which desynced or lenient
+// back-ends sometimes emit. It returns the index where the headers end and
+// where the body starts, or -1, -1 if the headers are incomplete.
+func headerBoundary(raw string) (headerEnd, bodyStart int) {
+	headerEnd, bodyStart = -1, -1
+	for _, sep := range []string{"\r\n\r\n", "\n\n", "\r\r"} {
+		if i := strings.Index(raw, sep); i != -1 && (headerEnd == -1 || i < headerEnd) {
+			headerEnd, bodyStart = i, i+len(sep)
+		}
+	}
+	return headerEnd, bodyStart
+}
+
+// splitLines splits s on CRLF, bare LF or bare CR.
+func splitLines(s string) []string {
+	var lines []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\r':
+			lines = append(lines, s[start:i])
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+			start = i + 1
+		case '\n':
+			lines = append(lines, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(lines, s[start:])
+}
+
+// responseLength returns the byte length of the first complete HTTP
+// response in raw, using Content-Length or chunked framing. It returns -1
+// when the response is incomplete or delimited only by connection close.
+func responseLength(raw string) int {
+	headerEnd, bodyStart := headerBoundary(raw)
+	if headerEnd == -1 {
+		return -1
+	}
+
+	lines := splitLines(raw[:headerEnd])
+	status := 0
+	if parts := strings.Fields(lines[0]); len(parts) >= 2 {
+		status, _ = strconv.Atoi(parts[1])
+	}
+
+	// 1xx, 204 and 304 responses never carry a body
+	if (status >= 100 && status < 200) || status == 204 || status == 304 {
+		return bodyStart
+	}
+
+	chunked := false
+	contentLength := -1
+
+	for _, line := range lines[1:] {
+		colon := strings.Index(line, ":")
+		if colon <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:colon])
+		val := strings.TrimSpace(line[colon+1:])
+
+		switch {
+		case strings.EqualFold(key, "Transfer-Encoding") &&
+			strings.Contains(strings.ToLower(val), "chunked"):
+			chunked = true
+		case strings.EqualFold(key, "Content-Length"):
+			if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+				contentLength = n
+			}
+		}
+	}
+
+	if chunked {
+		n := chunkedLength(raw[bodyStart:])
+		if n == -1 {
+			return -1
+		}
+		return bodyStart + n
+	}
+
+	if contentLength >= 0 {
+		if len(raw) < bodyStart+contentLength {
+			return -1
+		}
+		return bodyStart + contentLength
+	}
+
+	return -1
+}
+
+// chunkedLength returns the length of a complete chunked body (including
+// the terminating chunk and trailers), or -1 if it is incomplete or malformed.
+func chunkedLength(body string) int {
+	pos := 0
+	for {
+		lineEnd := strings.Index(body[pos:], "\r\n")
+		if lineEnd == -1 {
+			return -1
+		}
+
+		sizeField := body[pos : pos+lineEnd]
+		if semi := strings.Index(sizeField, ";"); semi != -1 {
+			sizeField = sizeField[:semi]
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+		if err != nil || size < 0 {
+			return -1
+		}
+		pos += lineEnd + 2
+
+		if size == 0 {
+			// trailers end with an empty line
+			for {
+				end := strings.Index(body[pos:], "\r\n")
+				if end == -1 {
+					return -1
+				}
+				pos += end + 2
+				if end == 0 {
+					return pos
+				}
+			}
+		}
+
+		if int64(len(body)-pos) < size+2 {
+			return -1
+		}
+		pos += int(size) + 2
+	}
+}
+
+// decodeChunked decodes a chunked message body, returning the content and
+// any trailer fields. Chunk lines may end in CRLF or bare LF and chunk
+// extensions are ignored. ok is false when a chunk size is malformed or
+// the body ends before the terminating chunk; the content decoded up to
+// that point is still returned.
+func decodeChunked(body string) (content string, trailers map[string]string, ok bool) {
+	var out strings.Builder
+	pos := 0
+
+	readLine := func() (string, bool) {
+		end := strings.IndexByte(body[pos:], '\n')
+		if end == -1 {
+			return "", false
+		}
+		line := strings.TrimSuffix(body[pos:pos+end], "\r")
+		pos += end + 1
+		return line, true
+	}
+
+	for {
+		sizeLine, found := readLine()
+		if !found {
+			return out.String(), nil, false
+		}
+		if semi := strings.IndexByte(sizeLine, ';'); semi != -1 {
+			sizeLine = sizeLine[:semi]
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(sizeLine), 16, 64)
+		if err != nil || size < 0 {
+			return out.String(), nil, false
+		}
+
+		if size == 0 {
+			for {
+				line, found := readLine()
+				if !found {
+					// a missing final blank line is tolerated
+					return out.String(), trailers, true
+				}
+				if line == "" {
+					return out.String(), trailers, true
+				}
+				if colon := strings.Index(line, ":"); colon > 0 {
+					if trailers == nil {
+						trailers = make(map[string]string)
+					}
+					trailers[strings.TrimSpace(line[:colon])] = strings.TrimSpace(line[colon+1:])
+				}
+			}
+		}
+
+		if int64(len(body)-pos) < size {
+			out.WriteString(body[pos:])
+			return out.String(), nil, false
+		}
+		out.WriteString(body[pos : pos+int(size)])
+		pos += int(size)
+
+		// each chunk's data is followed by its own line break
+		if strings.HasPrefix(body[pos:], "\r\n") {
+			pos += 2
+		} else if strings.HasPrefix(body[pos:], "\n") {
+			pos++
+		} else {
+			return out.String(), nil, false
+		}
+	}
+}
+
+// splitResponses splits a byte stream holding one or more pipelined HTTP
+// responses into individual raw responses. Any trailing bytes that do not
+// form a delimited response are returned as the final element.
+func splitResponses(raw string) []string {
+	var out []string
+	for raw != "" {
+		n := responseLength(raw)
+		if n <= 0 {
+			out = append(out, raw)
+			break
+		}
+		out = append(out, raw[:n])
+		raw = raw[n:]
+	}
+	return out
+}
+
+// countResponses returns how many complete responses raw holds.
+func countResponses(raw string) int {
+	count := 0
+	for raw != "" {
+		n := responseLength(raw)
+		if n <= 0 {
+			break
+		}
+		count++
+		raw = raw[n:]
+	}
+	return count
+}
@@ -0,0 +1,310 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+
+	"smuggler/internal/models"
+)
+
+// ErrNoHTTP2 is returned when a target does not negotiate HTTP/2 over TLS
+// or does not answer in HTTP/2 frames over plain TCP.
+var ErrNoHTTP2 = errors.New("target does not speak HTTP/2")
+
+// h2StreamID is the stream every request is sent on; each request uses a
+// fresh connection, so it is always the first client stream.
+const h2StreamID = 1
+
+// H2Sender sends HTTP/2 requests with hand-built frames. Unlike net/http it
+// sends header fields as given, so content-length and transfer-encoding can
+// disagree with the DATA frames, which is what probing a front-end that
+// downgrades to HTTP/1.1 requires.
+//
+// It shares the RawSender's dialer, TLS settings, timeouts and stats, so
+// proxy, SNI and timeout configuration apply to both. Over TLS the target
+// must negotiate h2 via ALPN; plain connections use prior knowledge (h2c).
+type H2Sender struct {
+	rs *RawSender
+}
+
+// NewH2Sender creates an HTTP/2 sender that connects the way rs does.
+func NewH2Sender(rs *RawSender) *H2Sender {
+	return &H2Sender{rs: rs}
+}
+
+// SendRequest sends req on a new connection and returns the response on
+// its stream.
+func (hs *H2Sender) SendRequest(target string, req *models.H2Request) (*models.HTTPResponse, error) {
+	return hs.SendRequestContext(context.Background(), target, req)
+}
+
+// SendRequestContext is like SendRequest but aborts as soon as ctx is done.
+// A stream reset or GOAWAY from the server is reported as a closed
+// connection with the error code in StatusLine, not as an error.
+func (hs *H2Sender) SendRequestContext(ctx context.Context, target string, req *models.H2Request) (*models.HTTPResponse, error) {
+	rs := hs.rs
+	response := &models.HTTPResponse{
+		Headers: make(map[string][]string),
+	}
+
+	if err := rs.wait(ctx); err != nil {
+		response.Error = abortError(target, err)
+		return response, response.Error
+	}
+	startTime := time.Now()
+
+	conn, timing, err := hs.dial(ctx, target)
+	if err != nil {
+		if ctx.Err() != nil {
+			response.Error = abortError(target, ctx.Err())
+		} else {
+			response.Error = connectError(target, err)
+		}
+		return response, response.Error
+	}
+
+	defer conn.Close()
+	rs.stats.addRequests(1)
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	framer := http2.NewFramer(conn, conn)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+
+	conn.SetWriteDeadline(time.Now().Add(rs.timeout))
+	if err := writeH2Request(conn, framer, req); err != nil {
+		if ctx.Err() != nil {
+			response.Error = abortError(target, ctx.Err())
+		} else {
+			response.Error = writeError(target, err)
+		}
+		return response, response.Error
+	}
+
+	sentAt := time.Now()
+	conn.SetReadDeadline(sentAt.Add(rs.readTimeout))
+
+	firstByte, readErr := readH2Response(framer, response, rs.readBufferSize)
+	response.TimingMS = time.Since(startTime).Milliseconds()
+	response.ConnectMS = timing.Connect.Milliseconds()
+	response.TLSMS = timing.TLS.Milliseconds()
+	recordTLS(response, conn)
+	if !firstByte.IsZero() {
+		response.TTFBMS = firstByte.Sub(sentAt).Milliseconds()
+	}
+
+	if tlsRejected(response.StatusLine, readErr) {
+		response.Error = rejectedError(target, readErr)
+		return response, response.Error
+	}
+	if readErr != nil {
+		timedOut := isTimeout(readErr)
+		if response.StatusLine == "" && !timedOut && ctx.Err() == nil {
+			// not even a stream reset: the server does not speak HTTP/2
+			response.Error = fmt.Errorf("%w: %v", ErrNoHTTP2, readErr)
+			return response, response.Error
+		}
+		response.ConnectionClosed = closedByPeer(readErr)
+	}
+
+	response.Raw = renderH2Response(response)
+
+	if ctx.Err() != nil {
+		response.ConnectionClosed = false
+		response.Error = abortError(target, ctx.Err())
+		return response, response.Error
+	}
+
+	return response, nil
+}
+
+// dial connects to target offering h2 via ALPN when TLS is enabled.
+func (hs *H2Sender) dial(ctx context.Context, target string) (net.Conn, DialTiming, error) {
+	rs := hs.rs
+
+	tlsConfig := rs.tlsConfig()
+	if tlsConfig != nil {
+		tlsConfig.NextProtos = []string{http2.NextProtoTLS}
+	}
+
+	conn, timing, err := rs.dialer.DialContext(ctx, target, tlsConfig)
+	if err != nil {
+		return nil, timing, err
+	}
+
+	if tc, ok := conn.(*tls.Conn); ok && tc.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+		conn.Close()
+		return nil, timing, fmt.Errorf("%w: ALPN did not select h2", ErrNoHTTP2)
+	}
+	return rs.stats.track(conn), timing, nil
+}
+
+// writeH2Request writes the connection preface, an empty SETTINGS frame and
+// req as HEADERS plus DATA frames on h2StreamID.
+func writeH2Request(w io.Writer, framer *http2.Framer, req *models.H2Request) error {
+	if _, err := io.WriteString(w, http2.ClientPreface); err != nil {
+		return err
+	}
+	if err := framer.WriteSettings(); err != nil {
+		return err
+	}
+
+	var block bytes.Buffer
+	enc := hpack.NewEncoder(&block)
+	fields := []hpack.HeaderField{
+		{Name: ":method", Value: req.Method},
+		{Name: ":scheme", Value: req.Scheme},
+		{Name: ":authority", Value: req.Authority},
+		{Name: ":path", Value: req.Path},
+	}
+	for _, h := range req.Headers {
+		fields = append(fields, hpack.HeaderField{Name: h[0], Value: h[1]})
+	}
+	for _, f := range fields {
+		if err := enc.WriteField(f); err != nil {
+			return err
+		}
+	}
+
+	err := framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      h2StreamID,
+		BlockFragment: block.Bytes(),
+		EndStream:     req.Body == "",
+		EndHeaders:    true,
+	})
+	if err != nil || req.Body == "" {
+		return err
+	}
+
+	// 16384 is the minimum SETTINGS_MAX_FRAME_SIZE every peer accepts
+	body := []byte(req.Body)
+	for len(body) > 0 {
+		n := len(body)
+		if n > 16384 {
+			n = 16384
+		}
+		if err := framer.WriteData(h2StreamID, n == len(body), body[:n]); err != nil {
+			return err
+		}
+		body = body[n:]
+	}
+	return nil
+}
+
+// readH2Response reads frames until the response on h2StreamID ends,
+// filling response with its status, headers and at most maxBody bytes of
+// body. It answers SETTINGS and PING and returns flow-control credit for
+// the DATA it consumes.
+func readH2Response(framer *http2.Framer, response *models.HTTPResponse, maxBody int) (time.Time, error) {
+	var firstByte time.Time
+	var body strings.Builder
+
+	defer func() { response.Body = body.String() }()
+
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			return firstByte, err
+		}
+
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				framer.WriteSettingsAck()
+			}
+
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				framer.WritePing(true, f.Data)
+			}
+
+		case *http2.MetaHeadersFrame:
+			if f.StreamID != h2StreamID {
+				continue
+			}
+			if firstByte.IsZero() {
+				firstByte = time.Now()
+			}
+			if status := f.PseudoValue("status"); status != "" && response.StatusLine == "" {
+				response.StatusLine = "HTTP/2 " + status
+				response.Proto = "HTTP/2"
+				fmt.Sscanf(status, "%d", &response.StatusCode)
+				for _, hf := range f.RegularFields() {
+					response.Headers[hf.Name] = append(response.Headers[hf.Name], hf.Value)
+					response.HeaderOrder = append(response.HeaderOrder, hf.Name)
+				}
+			} else {
+				// a second HEADERS frame carries trailers
+				for _, hf := range f.RegularFields() {
+					if response.Trailers == nil {
+						response.Trailers = make(map[string]string)
+					}
+					response.Trailers[hf.Name] = hf.Value
+				}
+			}
+			if f.StreamEnded() {
+				return firstByte, nil
+			}
+
+		case *http2.DataFrame:
+			if f.StreamID != h2StreamID {
+				continue
+			}
+			data := f.Data()
+			if room := maxBody - body.Len(); room > 0 {
+				if len(data) > room {
+					data = data[:room]
+				}
+				body.Write(data)
+			}
+			if n := uint32(len(f.Data())); n > 0 {
+				framer.WriteWindowUpdate(0, n)
+				framer.WriteWindowUpdate(h2StreamID, n)
+			}
+			if f.StreamEnded() {
+				return firstByte, nil
+			}
+
+		case *http2.RSTStreamFrame:
+			if f.StreamID != h2StreamID {
+				continue
+			}
+			if response.StatusLine == "" {
+				response.StatusLine = "RST_STREAM " + f.ErrCode.String()
+			}
+			return firstByte, fmt.Errorf("stream reset by server: %v", f.ErrCode)
+
+		case *http2.GoAwayFrame:
+			if response.StatusLine == "" {
+				response.StatusLine = "GOAWAY " + f.ErrCode.String()
+			}
+			return firstByte, fmt.Errorf("connection closed by server: GOAWAY %v", f.ErrCode)
+		}
+	}
+}
+
+// renderH2Response renders a parsed HTTP/2 response in HTTP/1.1 message
+// form, so it can be stored and displayed like any other response.
+func renderH2Response(response *models.HTTPResponse) string {
+	if response.StatusLine == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(response.StatusLine + "\r\n")
+	b.WriteString(response.HeaderBlock())
+	b.WriteString("\r\n")
+	b.WriteString(response.Body)
+	return b.String()
+}
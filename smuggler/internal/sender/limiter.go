@@ -0,0 +1,73 @@
+package sender
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket holding a single token: each request takes the
+// next free slot, one interval after the previous one. It is safe for
+// concurrent use, so one Limiter shared by every sender bounds the total
+// request rate of a concurrent scan. A nil *Limiter never waits.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewLimiter allows at most rps requests per second. Non-positive rates
+// return nil (no limit).
+func NewLimiter(rps float64) *Limiter {
+	if rps <= 0 {
+		return nil
+	}
+	return NewDelayLimiter(time.Duration(float64(time.Second) / rps))
+}
+
+// NewDelayLimiter spaces requests at least d apart. Non-positive delays
+// return nil (no limit).
+func NewDelayLimiter(d time.Duration) *Limiter {
+	if d <= 0 {
+		return nil
+	}
+	return &Limiter{interval: d}
+}
+
+// Interval returns the minimum spacing between requests.
+func (l *Limiter) Interval() time.Duration {
+	if l == nil {
+		return 0
+	}
+	return l.interval
+}
+
+// Wait blocks until the caller may send, or until ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	slot := l.next
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
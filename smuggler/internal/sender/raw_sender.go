@@ -2,34 +2,56 @@ package sender
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
-	"fmt"
-	"io"
+	"crypto/x509"
+	"errors"
 	"net"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"smuggler/internal/models"
 )
 
+// DefaultReadBufferSize is the reader buffer used when none is configured.
+// It is deliberately larger than bufio's 4KB default because smuggled
+// responses can carry abnormally large header lines.
+const DefaultReadBufferSize = 64 * 1024
+
 type RawSender struct {
-	timeout     time.Duration
-	readTimeout time.Duration
-	useTLS      bool
-	insecureTLS bool
+	timeout          time.Duration
+	readTimeout      time.Duration
+	firstByteTimeout time.Duration
+	useTLS           bool
+	insecureTLS      bool
+	serverName       string
+	clientCerts      []tls.Certificate
+	rootCAs          *x509.CertPool
+	readBufferSize   int
+	dialer           *Dialer
+	stats            *Stats
+	limiter          *Limiter
+	splitPoints      []int
+	splitDelay       time.Duration
 }
 
 func NewRawSender() *RawSender {
 	return &RawSender{
-		timeout:     10 * time.Second,
-		readTimeout: 10 * time.Second,
+		timeout:        10 * time.Second,
+		readTimeout:    10 * time.Second,
+		readBufferSize: DefaultReadBufferSize,
+		dialer:         NewDialer(10 * time.Second),
 	}
 }
 
 func NewRawSenderWithTimeout(timeout, readTimeout time.Duration) *RawSender {
 	return &RawSender{
-		timeout:     timeout,
-		readTimeout: readTimeout,
+		timeout:        timeout,
+		readTimeout:    readTimeout,
+		readBufferSize: DefaultReadBufferSize,
+		dialer:         NewDialer(timeout),
 	}
 }
 
@@ -43,79 +65,355 @@ func (rs *RawSender) SetInsecureTLS(insecure bool) *RawSender {
 	return rs
 }
 
-func (rs *RawSender) SendRequest(target string, payloadStr string) (*models.HTTPResponse, error) {
-	startTime := time.Now()
+// SetServerName overrides the TLS SNI value, which otherwise defaults to
+// the host being dialed.
+func (rs *RawSender) SetServerName(name string) *RawSender {
+	rs.serverName = name
+	return rs
+}
 
-	response := &models.HTTPResponse{
-		Headers: make(map[string]string),
+// SetClientCert presents the X.509 key pair in certFile and keyFile (PEM)
+// to servers that require a client certificate (mTLS).
+func (rs *RawSender) SetClientCert(certFile, keyFile string) error {
+	cert, err := LoadClientCert(certFile, keyFile)
+	if err != nil {
+		return err
 	}
+	rs.clientCerts = []tls.Certificate{cert}
+	return nil
+}
 
-	var conn net.Conn
-	var err error
+// SetCACert verifies servers against the PEM certificates in caFile
+// instead of the system roots, for targets signed by a private CA.
+func (rs *RawSender) SetCACert(caFile string) error {
+	pool, err := LoadCACert(caFile)
+	if err != nil {
+		return err
+	}
+	rs.rootCAs = pool
+	return nil
+}
+
+// Dialer returns the dialer used to open connections, for proxy configuration.
+func (rs *RawSender) Dialer() *Dialer {
+	return rs.dialer
+}
+
+// SetSplitWrite makes the sender write each payload in pieces, cut at the
+// given byte offsets, pausing between pieces so they leave in separate TCP
+// segments. Offsets outside the payload are ignored; nil restores single
+// writes.
+func (rs *RawSender) SetSplitWrite(points []int) *RawSender {
+	rs.splitPoints = append([]int(nil), points...)
+	sort.Ints(rs.splitPoints)
+	if rs.splitDelay == 0 {
+		rs.splitDelay = 50 * time.Millisecond
+	}
+	return rs
+}
+
+// SetSplitDelay sets the pause between split writes.
+func (rs *RawSender) SetSplitDelay(d time.Duration) *RawSender {
+	rs.splitDelay = d
+	return rs
+}
 
-	if rs.useTLS {
-		tlsConfig := &tls.Config{
-			InsecureSkipVerify: rs.insecureTLS,
-			MinVersion:         tls.VersionTLS12,
+// writePayload writes payload to conn, in segments when split points are set.
+func (rs *RawSender) writePayload(conn net.Conn, payload []byte) error {
+	prev := 0
+	for _, p := range rs.splitPoints {
+		if p <= prev || p >= len(payload) {
+			continue
+		}
+		if _, err := conn.Write(payload[prev:p]); err != nil {
+			return err
 		}
+		prev = p
+		time.Sleep(rs.splitDelay)
+	}
+	_, err := conn.Write(payload[prev:])
+	return err
+}
 
-		conn, err = tls.DialWithDialer(
-			&net.Dialer{Timeout: rs.timeout},
-			"tcp",
-			target,
-			tlsConfig,
-		)
-	} else {
-		conn, err = net.DialTimeout("tcp", target, rs.timeout)
+// SetConnectTimeout sets the timeout for connecting (including any proxy
+// CONNECT and TLS handshake) and for writing the request. Non-positive
+// values are ignored.
+func (rs *RawSender) SetConnectTimeout(d time.Duration) *RawSender {
+	if d > 0 {
+		rs.timeout = d
+		rs.dialer.SetTimeout(d)
+	}
+	return rs
+}
+
+// SetReadTimeout sets how long to wait for the response. Non-positive
+// values are ignored.
+func (rs *RawSender) SetReadTimeout(d time.Duration) *RawSender {
+	if d > 0 {
+		rs.readTimeout = d
+	}
+	return rs
+}
+
+// SetFirstByteTimeout bounds how long SendRequest waits for the first
+// response byte. Once a byte arrives the full read timeout applies from
+// that point on. Zero disables the separate deadline.
+func (rs *RawSender) SetFirstByteTimeout(d time.Duration) *RawSender {
+	rs.firstByteTimeout = d
+	return rs
+}
+
+// SetStats feeds connection and request counts into stats.
+func (rs *RawSender) SetStats(stats *Stats) *RawSender {
+	rs.stats = stats
+	return rs
+}
+
+// SetLimiter makes every send wait on l first. The wait happens before the
+// request is timed, so throttling never shows up in TimingMS. Share one
+// Limiter between senders to bound their combined rate.
+func (rs *RawSender) SetLimiter(l *Limiter) *RawSender {
+	rs.limiter = l
+	return rs
+}
+
+// wait blocks on the limiter, reporting the wait to stats.
+func (rs *RawSender) wait(ctx context.Context) error {
+	if rs.limiter == nil {
+		return nil
+	}
+	done := rs.stats.BeginWait("rate limit")
+	defer done()
+	return rs.limiter.Wait(ctx)
+}
+
+// SetReadBufferSize sets the size of the buffer used to read responses.
+// Values below 4096 are raised to 4096.
+func (rs *RawSender) SetReadBufferSize(n int) *RawSender {
+	if n < 4096 {
+		n = 4096
+	}
+	rs.readBufferSize = n
+	return rs
+}
+
+func (rs *RawSender) SendRequest(target string, payloadStr string) (*models.HTTPResponse, error) {
+	return rs.SendRequestBytesContext(context.Background(), target, []byte(payloadStr))
+}
+
+// SendRequestContext is like SendRequest but aborts the dial, write and
+// read as soon as ctx is done. The response's Error then wraps ctx.Err().
+func (rs *RawSender) SendRequestContext(ctx context.Context, target string, payloadStr string) (*models.HTTPResponse, error) {
+	return rs.SendRequestBytesContext(ctx, target, []byte(payloadStr))
+}
+
+// SendRequestBytes sends payload exactly as given, for payloads built from
+// byte sequences such as NULs, vertical tabs or bare CRs.
+func (rs *RawSender) SendRequestBytes(target string, payload []byte) (*models.HTTPResponse, error) {
+	return rs.SendRequestBytesContext(context.Background(), target, payload)
+}
+
+// SendRequestBytesContext is SendRequestBytes with the cancellation of
+// SendRequestContext.
+func (rs *RawSender) SendRequestBytesContext(ctx context.Context, target string, payload []byte) (*models.HTTPResponse, error) {
+	response := &models.HTTPResponse{
+		Headers: make(map[string][]string),
 	}
 
+	if err := rs.wait(ctx); err != nil {
+		response.Error = abortError(target, err)
+		return response, response.Error
+	}
+	startTime := time.Now()
+
+	conn, timing, err := rs.dial(ctx, target)
 	if err != nil {
-		response.Error = fmt.Errorf("failed to connect to %s: %w", target, err)
+		if ctx.Err() != nil {
+			response.Error = abortError(target, ctx.Err())
+		} else {
+			response.Error = connectError(target, err)
+		}
 		return response, response.Error
 	}
 
 	defer conn.Close()
+	rs.stats.addRequests(1)
+
+	// Closing the connection unblocks any pending write or read; the
+	// deadlines set below cannot undo it.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
 
 	// Write request
 	conn.SetWriteDeadline(time.Now().Add(rs.timeout))
 
-	_, err = conn.Write([]byte(payloadStr))
+	err = rs.writePayload(conn, payload)
 	if err != nil {
-		response.Error = fmt.Errorf("failed to send request: %w", err)
+		if ctx.Err() != nil {
+			response.Error = abortError(target, ctx.Err())
+		} else {
+			response.Error = writeError(target, err)
+			response.ConnectionClosed = !errors.Is(response.Error, ErrTimeout)
+		}
 		return response, response.Error
 	}
 
-	// Read response
-	conn.SetReadDeadline(time.Now().Add(rs.readTimeout))
+	// Read response. With a first-byte timeout, the first deadline only
+	// covers the wait for a response to start; the full read timeout is
+	// applied once it does.
+	sentAt := time.Now()
+	var extend time.Duration
+	if rs.firstByteTimeout > 0 && rs.firstByteTimeout < rs.readTimeout {
+		conn.SetReadDeadline(sentAt.Add(rs.firstByteTimeout))
+		extend = rs.readTimeout
+	} else {
+		conn.SetReadDeadline(sentAt.Add(rs.readTimeout))
+	}
 
-	raw, readErr := readFullResponse(conn)
+	raw, firstByte, readErr := readFullResponse(conn, rs.readBufferSize, extend)
 	response.Raw = raw
 	response.TimingMS = time.Since(startTime).Milliseconds()
-
-	if readErr != nil && readErr != io.EOF {
-		// timeout = connection probably kept alive
-		if ne, ok := readErr.(net.Error); ok && ne.Timeout() {
-			response.ConnectionClosed = false
-		} else {
-			response.ConnectionClosed = true
-		}
+	response.ConnectMS = timing.Connect.Milliseconds()
+	response.TLSMS = timing.TLS.Milliseconds()
+	recordTLS(response, conn)
+	if !firstByte.IsZero() {
+		response.TTFBMS = firstByte.Sub(sentAt).Milliseconds()
+	} else if extend > 0 && isTimeout(readErr) {
+		response.FirstByteTimeout = true
+	}
+	if tlsRejected(raw, readErr) {
+		response.Error = rejectedError(target, readErr)
+		return response, response.Error
 	}
 
+	// EOF or a reset means the server closed the connection; a read
+	// timeout means it kept it alive.
+	response.ConnectionClosed = closedByPeer(readErr)
+
+	// A response to a smuggled request may arrive in the same read; keep
+	// it apart so Raw and Body describe the first response only.
+	if parts := SplitResponses(raw); len(parts) > 1 {
+		response.Raw = parts[0].Raw
+		response.Extra = parts[1:]
+	}
 	parseHTTPResponse(response)
 
+	if ctx.Err() != nil {
+		response.ConnectionClosed = false
+		response.Error = abortError(target, ctx.Err())
+		return response, response.Error
+	}
+	if raw == "" && readErr != nil {
+		response.Error = noResponseError(target, readErr)
+		return response, response.Error
+	}
+
 	return response, nil
 }
 
-// reads until timeout/EOF safely
-func readFullResponse(conn net.Conn) (string, error) {
-	reader := bufio.NewReader(conn)
+// tlsConfig returns the TLS configuration for new connections, or nil when
+// TLS is disabled.
+func (rs *RawSender) tlsConfig() *tls.Config {
+	if !rs.useTLS {
+		return nil
+	}
+	return &tls.Config{
+		InsecureSkipVerify: rs.insecureTLS,
+		ServerName:         rs.serverName,
+		Certificates:       rs.clientCerts,
+		RootCAs:            rs.rootCAs,
+		MinVersion:         tls.VersionTLS12,
+		NextProtos:         []string{"http/1.1"},
+	}
+}
+
+// dial opens a connection to target, using TLS when enabled.
+func (rs *RawSender) dial(ctx context.Context, target string) (net.Conn, DialTiming, error) {
+	conn, timing, err := rs.dialer.DialContext(ctx, target, rs.tlsConfig())
+	if err != nil {
+		return nil, timing, err
+	}
+	return rs.stats.track(conn), timing, nil
+}
+
+// SendPipelined writes all payloads back-to-back on a single connection and
+// returns one parsed response per response received, in order. Fewer
+// responses than payloads means the server did not serve the whole pipeline.
+func (rs *RawSender) SendPipelined(target string, payloads []string) ([]*models.HTTPResponse, error) {
+	return rs.SendPipelinedContext(context.Background(), target, payloads)
+}
+
+// SendPipelinedContext is like SendPipelined but gives up as soon as ctx is
+// done, whether waiting on the rate limiter, dialing or mid-exchange.
+func (rs *RawSender) SendPipelinedContext(ctx context.Context, target string, payloads []string) ([]*models.HTTPResponse, error) {
+	if err := rs.wait(ctx); err != nil {
+		return nil, abortError(target, err)
+	}
+	startTime := time.Now()
+
+	conn, _, err := rs.dial(ctx, target)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, abortError(target, ctx.Err())
+		}
+		return nil, connectError(target, err)
+	}
+	defer conn.Close()
+	rs.stats.addRequests(len(payloads))
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	conn.SetWriteDeadline(time.Now().Add(rs.timeout))
+
+	if err := rs.writePayload(conn, []byte(strings.Join(payloads, ""))); err != nil {
+		if ctx.Err() != nil {
+			return nil, abortError(target, ctx.Err())
+		}
+		return nil, writeError(target, err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(rs.readTimeout))
+
+	raw, readErr := readResponses(conn, rs.readBufferSize, len(payloads))
+	elapsed := time.Since(startTime).Milliseconds()
+	if ctx.Err() != nil {
+		return nil, abortError(target, ctx.Err())
+	}
+	if tlsRejected(raw, readErr) {
+		return nil, rejectedError(target, readErr)
+	}
+
+	responses := SplitResponses(raw)
+	for _, resp := range responses {
+		resp.TimingMS = elapsed
+		recordTLS(resp, conn)
+	}
+
+	if len(responses) > 0 && closedByPeer(readErr) {
+		responses[len(responses)-1].ConnectionClosed = true
+	}
+
+	return responses, nil
+}
+
+// readResponses reads until want complete responses have arrived, or until
+// timeout/EOF when want is zero or the stream is not length-delimited.
+func readResponses(conn net.Conn, bufSize, want int) (string, error) {
+	if bufSize <= 0 {
+		bufSize = DefaultReadBufferSize
+	}
+	reader := bufio.NewReaderSize(conn, bufSize)
 	var buf strings.Builder
-	tmp := make([]byte, 4096)
+	tmp := make([]byte, bufSize)
 
 	for {
 		n, err := reader.Read(tmp)
 		if n > 0 {
 			buf.Write(tmp[:n])
+			if want > 0 && countResponses(buf.String()) >= want {
+				return buf.String(), nil
+			}
 		}
 
 		if err != nil {
@@ -124,6 +422,81 @@ func readFullResponse(conn net.Conn) (string, error) {
 	}
 }
 
+// readFullResponse reads one response. It returns as soon as the response
+// is complete according to its Content-Length or chunked framing, so
+// keep-alive servers do not hold it until the read timeout; responses with
+// neither are read until EOF or timeout. Reads are raw byte chunks rather
+// than lines, so header lines longer than the buffer are captured intact.
+// The arrival time of the first byte is returned for TTFB measurement;
+// when extend is non-zero the read deadline is reset to extend from that
+// moment.
+func readFullResponse(conn net.Conn, bufSize int, extend time.Duration) (string, time.Time, error) {
+	if bufSize <= 0 {
+		bufSize = DefaultReadBufferSize
+	}
+	reader := bufio.NewReaderSize(conn, bufSize)
+	var buf strings.Builder
+	var firstByte time.Time
+	tmp := make([]byte, bufSize)
+
+	for {
+		n, err := reader.Read(tmp)
+		if n > 0 {
+			if firstByte.IsZero() {
+				firstByte = time.Now()
+				if extend > 0 {
+					conn.SetReadDeadline(firstByte.Add(extend))
+				}
+			}
+			buf.Write(tmp[:n])
+			if responseLength(buf.String()) > 0 {
+				return buf.String(), firstByte, nil
+			}
+		}
+
+		if err != nil {
+			return buf.String(), firstByte, err
+		}
+	}
+}
+
+// SplitResponses parses a connection's byte stream into its responses, in
+// order, framing each by its Content-Length or chunked encoding. Bytes
+// after the last complete response become a final, partial response.
+func SplitResponses(raw string) []*models.HTTPResponse {
+	var responses []*models.HTTPResponse
+	for _, part := range splitResponses(raw) {
+		resp := &models.HTTPResponse{
+			Raw:     part,
+			Headers: make(map[string][]string),
+		}
+		parseHTTPResponse(resp)
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+// parseStatusLine splits "HTTP/1.1 400 Bad Request" into its protocol,
+// code and reason phrase. code is 0 when the line does not start with
+// "HTTP/" or has no three-digit code; proto is then empty as well.
+func parseStatusLine(line string) (proto string, code int, reason string) {
+	line = strings.TrimLeft(line, " \t")
+	if !strings.HasPrefix(line, "HTTP/") {
+		return "", 0, ""
+	}
+	proto, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimLeft(rest, " ")
+	codeText, reason, _ := strings.Cut(rest, " ")
+	if len(codeText) != 3 {
+		return "", 0, ""
+	}
+	n, err := strconv.Atoi(codeText)
+	if err != nil || n < 100 {
+		return "", 0, ""
+	}
+	return proto, n, strings.TrimSpace(reason)
+}
+
 // parseHTTPResponse parses raw HTTP response safely.
 func parseHTTPResponse(response *models.HTTPResponse) {
 
@@ -131,27 +504,26 @@ func parseHTTPResponse(response *models.HTTPResponse) {
 		return
 	}
 
-	lines := strings.Split(response.Raw, "\r\n")
-	if len(lines) == 0 {
-		return
-	}
-
-	// status line
-	parts := strings.Fields(lines[0])
-	if len(parts) >= 2 {
-		fmt.Sscanf(parts[1], "%d", &response.StatusCode)
+	// The header boundary may be CRLF, bare LF or bare CR framed; header
+	// lines are split the same way so lenient back-ends still parse.
+	head := response.Raw
+	headerEnd, bodyStart := headerBoundary(response.Raw)
+	if headerEnd != -1 {
+		head = response.Raw[:headerEnd]
 	}
 
-	headerEnd := -1
+	lines := splitLines(head)
 
-	for i := 1; i < len(lines); i++ {
-
-		line := lines[i]
+	// status line; a desynced backend may emit a non-numeric code
+	// (e.g. "HTTP/1.1 GPOST") or no status line at all, which is kept
+	// rather than dropped.
+	response.StatusLine = lines[0]
+	response.Proto, response.StatusCode, response.Reason = parseStatusLine(lines[0])
+	if response.StatusCode == 0 {
+		response.MalformedStatus = true
+	}
 
-		if line == "" {
-			headerEnd = i
-			break
-		}
+	for _, line := range lines[1:] {
 
 		colon := strings.Index(line, ":")
 		if colon <= 0 {
@@ -161,10 +533,25 @@ func parseHTTPResponse(response *models.HTTPResponse) {
 		key := strings.TrimSpace(line[:colon])
 		val := strings.TrimSpace(line[colon+1:])
 
-		response.Headers[key] = val
+		response.Headers[key] = append(response.Headers[key], val)
+		response.HeaderOrder = append(response.HeaderOrder, key)
 	}
 
-	if headerEnd != -1 && headerEnd+1 < len(lines) {
-		response.Body = strings.Join(lines[headerEnd+1:], "\r\n")
+	if headerEnd == -1 {
+		return
+	}
+
+	response.Body = response.Raw[bodyStart:]
+
+	// Decode chunked bodies so Body holds only content; Raw keeps the
+	// bytes as received. A malformed body is left as it arrived.
+	if te := response.Header("Transfer-Encoding"); strings.Contains(strings.ToLower(te), "chunked") {
+		content, trailers, ok := decodeChunked(response.Body)
+		if ok {
+			response.Body = content
+			response.Trailers = trailers
+		} else {
+			response.MalformedChunking = true
+		}
 	}
 }
@@ -0,0 +1,229 @@
+package sender
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serve runs handle on every connection to a local listener and returns
+// its address. The listener and any open connections are closed when the
+// test ends.
+func serve(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var conns []net.Conn
+	t.Cleanup(func() {
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+	})
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+			go handle(c)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// silentServer reads whatever is sent but never answers.
+func silentServer(t *testing.T) string {
+	return serve(t, func(c net.Conn) { io.Copy(io.Discard, c) })
+}
+
+// replyServer answers every request header block with response and then
+// keeps the connection open, as a keep-alive server would.
+func replyServer(t *testing.T, response string) string {
+	return serve(t, func(c net.Conn) {
+		br := bufio.NewReader(c)
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line == "\r\n" {
+				io.WriteString(c, response)
+			}
+		}
+	})
+}
+
+func TestSendRequestContextCancel(t *testing.T) {
+	addr := silentServer(t)
+	request := "GET / HTTP/1.1\r\nHost: test\r\n\r\n"
+
+	tests := []struct {
+		name string
+		send func(ctx context.Context) error
+	}{
+		{"SendRequestContext", func(ctx context.Context) error {
+			_, err := NewRawSender().SendRequestContext(ctx, addr, request)
+			return err
+		}},
+		{"SendPipelinedContext", func(ctx context.Context) error {
+			_, err := NewRawSender().SendPipelinedContext(ctx, addr, []string{request, request})
+			return err
+		}},
+		// The silent server never answers the TLS handshake, so the
+		// cancel lands mid-dial.
+		{"OpenConnContext", func(ctx context.Context) error {
+			conn, err := NewRawSender().SetTLS(true).OpenConnContext(ctx, addr)
+			if err == nil {
+				conn.Close()
+			}
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+
+			start := time.Now()
+			err := tt.send(ctx)
+			elapsed := time.Since(start)
+
+			if !errors.Is(err, ErrAborted) {
+				t.Fatalf("err = %v, want ErrAborted", err)
+			}
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("err = %v, want it to wrap context.Canceled", err)
+			}
+			if elapsed > time.Second {
+				t.Errorf("%s returned %v after start, want shortly after the 100ms cancel", tt.name, elapsed)
+			}
+		})
+	}
+}
+
+func TestSendRequestOversizedHeaderLine(t *testing.T) {
+	long := strings.Repeat("a", 200*1024)
+	body := "hello"
+	addr := replyServer(t, "HTTP/1.1 200 OK\r\nX-Long: "+long+"\r\nContent-Length: 5\r\n\r\n"+body)
+
+	rs := NewRawSenderWithTimeout(2*time.Second, 5*time.Second).SetReadBufferSize(4096)
+
+	start := time.Now()
+	resp, err := rs.SendRequest(addr, "GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := resp.Header("X-Long"); got != long {
+		t.Errorf("X-Long header is %d bytes, want %d", len(got), len(long))
+	}
+	if resp.Body != body {
+		t.Errorf("body = %q, want %q", resp.Body, body)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("read took %v, want it to finish on Content-Length rather than the read timeout", elapsed)
+	}
+}
+
+func TestParseStatusLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		proto  string
+		code   int
+		reason string
+	}{
+		{"HTTP/1.1 200 OK", "HTTP/1.1", 200, "OK"},
+		{"HTTP/1.0 400 Bad Request", "HTTP/1.0", 400, "Bad Request"},
+		{"HTTP/1.1 204", "HTTP/1.1", 204, ""},
+		{"  HTTP/1.1  302 Found ", "HTTP/1.1", 302, "Found"},
+		{"HTTP/1.1 GPOST", "", 0, ""},
+		{"HTTP/1.1 GPOST / HTTP/1.1", "", 0, ""},
+		{"HTTP/1.1 20 OK", "", 0, ""},
+		{"HTTP/1.1 2000 OK", "", 0, ""},
+		{"HTTP/1.1 099 Low", "", 0, ""},
+		{"HTTP/1.1", "", 0, ""},
+		{"GPOST / HTTP/1.1", "", 0, ""},
+		{"<html>", "", 0, ""},
+		{"", "", 0, ""},
+	}
+	for _, tt := range tests {
+		proto, code, reason := parseStatusLine(tt.line)
+		if proto != tt.proto || code != tt.code || reason != tt.reason {
+			t.Errorf("parseStatusLine(%q) = %q, %d, %q; want %q, %d, %q",
+				tt.line, proto, code, reason, tt.proto, tt.code, tt.reason)
+		}
+	}
+}
+
+func TestParseHTTPResponseHeaderOrder(t *testing.T) {
+	resp := SplitResponses("HTTP/1.1 200 OK\r\n" +
+		"Server: nginx\r\n" +
+		"Set-Cookie: a=1\r\n" +
+		"content-type: text/plain\r\n" +
+		"Set-Cookie: b=2\r\n" +
+		"X-Cache: MISS\r\n" +
+		"Content-Length: 0\r\n\r\n")[0]
+
+	want := []string{"Server", "Set-Cookie", "content-type", "Set-Cookie", "X-Cache", "Content-Length"}
+	if strings.Join(resp.HeaderOrder, ",") != strings.Join(want, ",") {
+		t.Errorf("HeaderOrder = %v, want %v", resp.HeaderOrder, want)
+	}
+	if got := resp.Headers["Set-Cookie"]; len(got) != 2 || got[0] != "a=1" || got[1] != "b=2" {
+		t.Errorf("Set-Cookie values = %v, want [a=1 b=2]", got)
+	}
+}
+
+func TestSendRequestReadTimeout(t *testing.T) {
+	addr := silentServer(t)
+	rs := NewRawSender().SetReadTimeout(time.Second)
+
+	start := time.Now()
+	resp, err := rs.SendRequest(addr, "GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, ErrNoResponse) {
+		t.Errorf("err = %v, want ErrTimeout wrapping ErrNoResponse", err)
+	}
+	if elapsed < time.Second || elapsed > 3*time.Second {
+		t.Errorf("unanswered request returned after %v, want about the 1s read timeout", elapsed)
+	}
+	if resp.Raw != "" || resp.StatusCode != 0 {
+		t.Errorf("got response %q from a server that never answered", resp.Raw)
+	}
+	if resp.ConnectionClosed {
+		t.Error("read timeout reported as the server closing the connection")
+	}
+}
+
+func TestSendRequestClosedWithoutResponse(t *testing.T) {
+	addr := serve(t, func(c net.Conn) {
+		bufio.NewReader(c).ReadString('\n')
+		c.Close()
+	})
+
+	resp, err := NewRawSender().SendRequest(addr, "GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+	if !errors.Is(err, ErrRead) || !errors.Is(err, ErrNoResponse) {
+		t.Errorf("err = %v, want ErrRead wrapping ErrNoResponse", err)
+	}
+	if resp == nil || !resp.ConnectionClosed {
+		t.Error("close without a response not reported as the server closing the connection")
+	}
+}
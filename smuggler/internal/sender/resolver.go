@@ -0,0 +1,108 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDNSCacheTTL is how long a resolved address is reused.
+const DefaultDNSCacheTTL = 30 * time.Second
+
+// Resolver resolves target hostnames once per TTL and shares the answer
+// across every dial in a run, so sweeping several ports of one host does
+// not hit the system resolver each time. Pinned hosts (-resolve) always
+// resolve to their pinned address and never consult DNS.
+type Resolver struct {
+	ttl     time.Duration
+	timeout time.Duration
+	lookup  func(ctx context.Context, host string) ([]string, error)
+
+	mu    sync.Mutex
+	cache map[string]dnsEntry
+	pins  map[string]string
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// NewResolver creates a caching resolver. A ttl of zero or less uses
+// DefaultDNSCacheTTL.
+func NewResolver(ttl time.Duration) *Resolver {
+	if ttl <= 0 {
+		ttl = DefaultDNSCacheTTL
+	}
+	return &Resolver{
+		ttl:     ttl,
+		timeout: 10 * time.Second,
+		lookup:  net.DefaultResolver.LookupHost,
+		cache:   make(map[string]dnsEntry),
+		pins:    make(map[string]string),
+	}
+}
+
+// Pin makes host always resolve to addr, which must be an IP address.
+func (r *Resolver) Pin(host, addr string) error {
+	if net.ParseIP(addr) == nil {
+		return fmt.Errorf("invalid pinned address %q for %s", addr, host)
+	}
+	r.mu.Lock()
+	r.pins[strings.ToLower(host)] = addr
+	r.mu.Unlock()
+	return nil
+}
+
+// Resolve returns an address for host: the host itself if it is already an
+// IP, its pin if it has one, otherwise a cached or freshly looked-up address.
+// A lookup is bounded by ctx as well as the resolver's own timeout, so
+// cancelling a send also abandons a slow DNS query.
+func (r *Resolver) Resolve(ctx context.Context, host string) (string, error) {
+	addrs, err := r.ResolveAll(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	return addrs[0], nil
+}
+
+// ResolveAll is like Resolve but returns every address of host, in the
+// order the lookup gave them; Resolve returns the first. Pinned hosts and IP
+// literals have exactly one.
+func (r *Resolver) ResolveAll(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	key := strings.ToLower(host)
+
+	r.mu.Lock()
+	if addr, ok := r.pins[key]; ok {
+		r.mu.Unlock()
+		return []string{addr}, nil
+	}
+	if e, ok := r.cache[key]; ok && time.Now().Before(e.expires) {
+		r.mu.Unlock()
+		return e.addrs, nil
+	}
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	addrs, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+
+	r.mu.Lock()
+	r.cache[key] = dnsEntry{addrs: addrs, expires: time.Now().Add(r.ttl)}
+	r.mu.Unlock()
+
+	return addrs, nil
+}
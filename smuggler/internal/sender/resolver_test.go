@@ -0,0 +1,141 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingResolver returns a resolver whose lookups answer 127.0.0.1 and
+// are counted in n.
+func countingResolver(ttl time.Duration, n *int32) *Resolver {
+	r := NewResolver(ttl)
+	r.lookup = func(ctx context.Context, host string) ([]string, error) {
+		atomic.AddInt32(n, 1)
+		return []string{"127.0.0.1"}, nil
+	}
+	return r
+}
+
+func TestResolverCachesWithinTTL(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	var lookups int32
+	d := NewDialer(2 * time.Second).SetResolver(countingResolver(time.Minute, &lookups))
+
+	for i := 0; i < 2; i++ {
+		conn, _, err := d.Dial(net.JoinHostPort("target.test", port), nil)
+		if err != nil {
+			t.Fatalf("dial %d: %v", i+1, err)
+		}
+		conn.Close()
+	}
+	if lookups != 1 {
+		t.Errorf("lookups = %d, want 1 for two dials within the TTL", lookups)
+	}
+}
+
+func TestDialTriesEachAddress(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// Nothing listens on 127.0.0.2, so the first address refuses.
+	r := NewResolver(time.Minute)
+	r.lookup = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"127.0.0.2", "127.0.0.1"}, nil
+	}
+	d := NewDialer(2 * time.Second).SetResolver(r)
+
+	conn, _, err := d.Dial(net.JoinHostPort("target.test", port), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if got := conn.RemoteAddr().String(); got != ln.Addr().String() {
+		t.Errorf("connected to %s, want %s", got, ln.Addr())
+	}
+}
+
+func TestResolverLooksUpAgainAfterTTL(t *testing.T) {
+	var lookups int32
+	r := countingResolver(10*time.Millisecond, &lookups)
+
+	if _, err := r.Resolve(context.Background(), "target.test"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := r.Resolve(context.Background(), "target.test"); err != nil {
+		t.Fatal(err)
+	}
+	if lookups != 2 {
+		t.Errorf("lookups = %d, want 2 once the TTL expired", lookups)
+	}
+}
+
+func TestResolverSkipsLookupForPinsAndIPs(t *testing.T) {
+	var lookups int32
+	r := countingResolver(time.Minute, &lookups)
+	if err := r.Pin("Pinned.test", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if addr, _ := r.Resolve(context.Background(), "pinned.test"); addr != "10.0.0.1" {
+		t.Errorf("pinned host resolved to %q, want 10.0.0.1", addr)
+	}
+	if addr, _ := r.Resolve(context.Background(), "::1"); addr != "::1" {
+		t.Errorf("IP resolved to %q, want ::1", addr)
+	}
+	if lookups != 0 {
+		t.Errorf("lookups = %d, want 0", lookups)
+	}
+}
+
+func TestResolverHonoursContext(t *testing.T) {
+	r := NewResolver(time.Minute)
+	r.lookup = func(ctx context.Context, host string) ([]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := r.Resolve(ctx, "slow.test")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Resolve took %v after the context expired", elapsed)
+	}
+}
@@ -0,0 +1,174 @@
+package sender
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats aggregates live throughput figures from one or more senders and
+// from the limiters that throttle them. A nil *Stats is valid and records
+// nothing, so senders can feed it unconditionally.
+type Stats struct {
+	active   int64
+	requests int64
+	queued   int64
+
+	mu      sync.Mutex
+	waiting map[string]int
+}
+
+// NewStats creates an empty stats aggregator.
+func NewStats() *Stats {
+	return &Stats{waiting: make(map[string]int)}
+}
+
+// StatsSnapshot is a point-in-time view of Stats.
+type StatsSnapshot struct {
+	Active     int64
+	Queued     int64
+	Requests   int64
+	RPS        float64
+	Bottleneck string
+}
+
+func (s StatsSnapshot) String() string {
+	return fmt.Sprintf("active conns: %d | queued targets: %d | requests: %d (%.1f req/s) | limited by: %s",
+		s.Active, s.Queued, s.Requests, s.RPS, s.Bottleneck)
+}
+
+// SetQueued records how many targets are still waiting to be scanned.
+func (s *Stats) SetQueued(n int) {
+	if s == nil {
+		return
+	}
+	atomic.StoreInt64(&s.queued, int64(n))
+}
+
+// BeginWait records that a request is blocked on the named limiter and
+// returns a function to call once it is released.
+func (s *Stats) BeginWait(limiter string) func() {
+	if s == nil {
+		return func() {}
+	}
+	s.mu.Lock()
+	s.waiting[limiter]++
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		s.waiting[limiter]--
+		s.mu.Unlock()
+	}
+}
+
+func (s *Stats) addRequests(n int) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.requests, int64(n))
+}
+
+// track counts conn as active until it is closed.
+func (s *Stats) track(conn net.Conn) net.Conn {
+	if s == nil {
+		return conn
+	}
+	atomic.AddInt64(&s.active, 1)
+	return &trackedConn{Conn: conn, stats: s}
+}
+
+// Snapshot returns the current figures. The request rate is not filled in;
+// Report derives it from consecutive snapshots.
+func (s *Stats) Snapshot() StatsSnapshot {
+	snap := StatsSnapshot{
+		Active:   atomic.LoadInt64(&s.active),
+		Queued:   atomic.LoadInt64(&s.queued),
+		Requests: atomic.LoadInt64(&s.requests),
+	}
+	snap.Bottleneck = s.bottleneck(snap)
+	return snap
+}
+
+// bottleneck names the limiter with the most blocked requests. With none
+// blocked, throughput is bounded by the targets' own response times.
+func (s *Stats) bottleneck(snap StatsSnapshot) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.waiting))
+	for name, n := range s.waiting {
+		if n > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		if snap.Active > 0 {
+			return "target latency"
+		}
+		return "idle"
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if s.waiting[names[i]] != s.waiting[names[j]] {
+			return s.waiting[names[i]] > s.waiting[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return fmt.Sprintf("%s (%d waiting)", names[0], s.waiting[names[0]])
+}
+
+// Report rewrites a single status line on w every interval until the
+// returned stop function is called.
+func (s *Stats) Report(w io.Writer, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last := s.Snapshot()
+		lastAt := time.Now()
+		for {
+			select {
+			case <-done:
+				fmt.Fprint(w, "\r\033[K")
+				return
+			case now := <-ticker.C:
+				snap := s.Snapshot()
+				if elapsed := now.Sub(lastAt).Seconds(); elapsed > 0 {
+					snap.RPS = float64(snap.Requests-last.Requests) / elapsed
+				}
+				last, lastAt = snap, now
+				fmt.Fprintf(w, "\r\033[K[stats] %s", snap)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}
+}
+
+// trackedConn decrements the active connection count when closed.
+type trackedConn struct {
+	net.Conn
+	stats *Stats
+	once  sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		atomic.AddInt64(&c.stats.active, -1)
+	})
+	return c.Conn.Close()
+}
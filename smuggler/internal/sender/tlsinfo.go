@@ -0,0 +1,106 @@
+package sender
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/net/http2"
+
+	"smuggler/internal/models"
+)
+
+// recordTLS copies the negotiated TLS parameters of conn onto response.
+// Plain connections leave the fields empty.
+func recordTLS(response *models.HTTPResponse, conn net.Conn) {
+	if tc, ok := conn.(*trackedConn); ok {
+		conn = tc.Conn
+	}
+	tc, ok := conn.(*tls.Conn)
+	if !ok {
+		return
+	}
+
+	state := tc.ConnectionState()
+	response.TLSVersion = tls.VersionName(state.Version)
+	response.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	response.ALPN = state.NegotiatedProtocol
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		response.PeerCN = cert.Subject.CommonName
+		response.PeerSANs = append([]string(nil), cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			response.PeerSANs = append(response.PeerSANs, ip.String())
+		}
+	}
+}
+
+// tlsRejected reports whether a read that returned nothing failed on a TLS
+// alert. With TLS 1.3 the client finishes its side of the handshake before
+// the server checks the client certificate, so a missing or untrusted
+// certificate only shows up as an alert on the first read.
+func tlsRejected(raw string, readErr error) bool {
+	var opErr *net.OpError
+	return raw == "" && errors.As(readErr, &opErr) && opErr.Op == "remote error"
+}
+
+// rejectedError reports a handshake the server refused after the fact.
+func rejectedError(target string, readErr error) *SendError {
+	return &SendError{Kind: KindConnect, Target: target, Err: fmt.Errorf("TLS handshake rejected: %w", readErr)}
+}
+
+// ProbeALPN completes a TLS handshake with target offering h2 and
+// http/1.1 and returns the protocol the server picked, "" if it ignored
+// ALPN. The raw sender only offers http/1.1, so this is how a scan learns
+// that the front-end speaks HTTP/2. No request is sent.
+func (rs *RawSender) ProbeALPN(ctx context.Context, target string) (string, error) {
+	tlsConfig := rs.tlsConfig()
+	if tlsConfig == nil {
+		return "", nil
+	}
+	tlsConfig.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+
+	if err := rs.wait(ctx); err != nil {
+		return "", abortError(target, err)
+	}
+	conn, _, err := rs.dialer.DialContext(ctx, target, tlsConfig)
+	if err != nil {
+		return "", connectError(target, err)
+	}
+	defer conn.Close()
+
+	if tc, ok := conn.(*trackedConn); ok {
+		conn = tc.Conn
+	}
+	tc, ok := conn.(*tls.Conn)
+	if !ok {
+		return "", &SendError{Kind: KindConnect, Target: target, Err: errors.New("connection is not TLS")}
+	}
+	return tc.ConnectionState().NegotiatedProtocol, nil
+}
+
+// LoadClientCert reads a PEM certificate and its private key for mTLS.
+func LoadClientCert(certFile, keyFile string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// LoadCACert reads one or more PEM CA certificates into a pool.
+func LoadCACert(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,91 @@
+package targets
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Normalize splits a target string into its host, port and whether to use
+// TLS. IPv6 literals may be bare (::1, 2001:db8::1) or bracketed, with or
+// without a port ([2001:db8::1]:8443); the host is returned unbracketed.
+// defaultPort and https are the -port and -https settings.
+//
+// Precedence, most specific first:
+//   - an explicit scheme decides TLS, and its default port applies
+//     when the URL names none (http://h:443 stays plain HTTP);
+//   - an explicit :port always beats defaultPort;
+//   - without a scheme, port 80 is plain and 443 is TLS, and any other
+//     port uses TLS only with https.
+func Normalize(raw string, defaultPort int, https bool) (string, int, bool, error) {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimSuffix(raw, "/")
+
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", 0, false, err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return "", 0, false, fmt.Errorf("unsupported scheme %q", u.Scheme)
+		}
+		h := u.Hostname()
+		if h == "" {
+			return "", 0, false, fmt.Errorf("missing host in %q", raw)
+		}
+		useTLS := u.Scheme == "https"
+		if p := u.Port(); p != "" {
+			pi, err := parsePort(p)
+			if err != nil {
+				return "", 0, false, err
+			}
+			return h, pi, useTLS, nil
+		}
+		if useTLS {
+			return h, 443, true, nil
+		}
+		return h, 80, false, nil
+	}
+
+	// Raw host, maybe with :port. A bare IPv6 literal has several colons
+	// and no brackets, so it never carries a port.
+	if net.ParseIP(raw) == nil && strings.Contains(raw, ":") {
+		h, p, err := net.SplitHostPort(raw)
+		if err == nil {
+			pi, err := parsePort(p)
+			if err != nil {
+				return "", 0, false, err
+			}
+			return h, pi, portTLS(pi, https), nil
+		}
+	}
+
+	// [2001:db8::1] without a port
+	if strings.HasPrefix(raw, "[") && strings.HasSuffix(raw, "]") {
+		raw = raw[1 : len(raw)-1]
+	}
+
+	return raw, defaultPort, portTLS(defaultPort, https), nil
+}
+
+// portTLS decides TLS for a target without a scheme: by the well-known
+// port, otherwise by https.
+func portTLS(p int, https bool) bool {
+	switch p {
+	case 80:
+		return false
+	case 443:
+		return true
+	}
+	return https
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(s)
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return p, nil
+}
@@ -0,0 +1,110 @@
+package targets
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw    string
+		host   string
+		port   int
+		useTLS bool
+	}{
+		// IPv4
+		{"192.0.2.1", "192.0.2.1", 80, false},
+		{"192.0.2.1:8080", "192.0.2.1", 8080, false},
+		{"http://192.0.2.1/", "192.0.2.1", 80, false},
+		{"https://192.0.2.1:8443", "192.0.2.1", 8443, true},
+
+		// IPv6, bare and bracketed
+		{"::1", "::1", 80, false},
+		{"2001:db8::1", "2001:db8::1", 80, false},
+		{"[2001:db8::1]", "2001:db8::1", 80, false},
+		{"[2001:db8::1]:8443", "2001:db8::1", 8443, false},
+		{"[::1]:443", "::1", 443, true},
+		{"http://[2001:db8::1]/", "2001:db8::1", 80, false},
+		{"https://[2001:db8::1]:8443/", "2001:db8::1", 8443, true},
+
+		// hostnames
+		{"example.com", "example.com", 80, false},
+		{" example.com/ ", "example.com", 80, false},
+		{"example.com:8080", "example.com", 8080, false},
+		{"http://example.com", "example.com", 80, false},
+		{"https://example.com", "example.com", 443, true},
+		{"https://example.com:8443/", "example.com", 8443, true},
+	}
+	for _, tt := range tests {
+		host, port, useTLS, err := Normalize(tt.raw, 80, false)
+		if err != nil {
+			t.Errorf("Normalize(%q): %v", tt.raw, err)
+			continue
+		}
+		if host != tt.host || port != tt.port || useTLS != tt.useTLS {
+			t.Errorf("Normalize(%q) = %q, %d, %t; want %q, %d, %t",
+				tt.raw, host, port, useTLS, tt.host, tt.port, tt.useTLS)
+		}
+	}
+}
+
+func TestNormalizeErrors(t *testing.T) {
+	for _, raw := range []string{
+		"ftp://example.com",
+		"https://",
+		"http://example.com:0",
+		"http://example.com:65536",
+		"example.com:99999",
+		"[2001:db8::1]:0",
+	} {
+		if host, port, _, err := Normalize(raw, 80, false); err == nil {
+			t.Errorf("Normalize(%q) = %q, %d; want an error", raw, host, port)
+		}
+	}
+}
+
+func TestNormalizePrecedence(t *testing.T) {
+	tests := []struct {
+		rule        string
+		raw         string
+		defaultPort int
+		https       bool
+		port        int
+		useTLS      bool
+	}{
+		// An explicit scheme decides TLS, whatever the port or -https.
+		{"http scheme on 443 stays plain", "http://example.com:443", 80, false, 443, false},
+		{"http scheme beats -https", "http://example.com:8443", 80, true, 8443, false},
+		{"https scheme on 80 uses TLS", "https://example.com:80", 80, false, 80, true},
+		{"https scheme without -https", "https://example.com:8443", 80, false, 8443, true},
+
+		// A scheme's default port beats -port.
+		{"http default port beats -port", "http://example.com", 8080, false, 80, false},
+		{"https default port beats -port", "https://example.com", 8080, false, 443, true},
+
+		// An explicit :port beats -port.
+		{"explicit port beats -port", "example.com:8081", 9000, false, 8081, false},
+		{"explicit IPv6 port beats -port", "[::1]:8081", 9000, false, 8081, false},
+
+		// Without a scheme, 80 and 443 decide TLS, even against -https.
+		{"host:443 uses TLS", "example.com:443", 80, false, 443, true},
+		{"host:80 stays plain with -https", "example.com:80", 80, true, 80, false},
+		{"-port 443 uses TLS", "example.com", 443, false, 443, true},
+		{"-port 80 stays plain with -https", "example.com", 80, true, 80, false},
+
+		// Otherwise -https decides.
+		{"host:8443 plain without -https", "example.com:8443", 80, false, 8443, false},
+		{"host:8443 with -https", "example.com:8443", 80, true, 8443, true},
+		{"-port 8443 with -https", "example.com", 8443, true, 8443, true},
+		{"bare IPv6 with -port and -https", "2001:db8::1", 8443, true, 8443, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, port, useTLS, err := Normalize(tt.raw, tt.defaultPort, tt.https)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if port != tt.port || useTLS != tt.useTLS {
+				t.Errorf("Normalize(%q, %d, %t) = port %d, tls %t; want port %d, tls %t",
+					tt.raw, tt.defaultPort, tt.https, port, useTLS, tt.port, tt.useTLS)
+			}
+		})
+	}
+}
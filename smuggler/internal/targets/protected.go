@@ -0,0 +1,94 @@
+package targets
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"smuggler/internal/sender"
+)
+
+// ProtectedEndpoint is an upstream the tool itself depends on, such as
+// the proxy or the AI endpoint, named by the flag that configures it.
+type ProtectedEndpoint struct {
+	Role string
+	Host string
+	Port int
+}
+
+func (ep ProtectedEndpoint) String() string {
+	return net.JoinHostPort(ep.Host, strconv.Itoa(ep.Port))
+}
+
+// Protected is the set of endpoints that must never be scanned, so a
+// fat-fingered target list cannot smuggle against the tool's own proxy or
+// AI endpoint. Host names are compared by address through the run's
+// Resolver, so -resolve pins and the DNS cache apply and "localhost"
+// matches 127.0.0.1. A nil *Protected protects nothing.
+type Protected struct {
+	resolver  *sender.Resolver
+	endpoints []ProtectedEndpoint
+}
+
+// NewProtected returns an empty set that resolves host names through r.
+func NewProtected(r *sender.Resolver) *Protected {
+	if r == nil {
+		r = sender.NewResolver(0)
+	}
+	return &Protected{resolver: r}
+}
+
+// Add protects the host:port of rawURL, using the scheme's default port
+// when it names none. URLs without a host are ignored.
+func (p *Protected) Add(role, rawURL string) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return
+	}
+	port, _ := strconv.Atoi(u.Port())
+	if port == 0 {
+		port = 80
+		if u.Scheme == "https" {
+			port = 443
+		}
+	}
+	p.endpoints = append(p.endpoints, ProtectedEndpoint{Role: role, Host: u.Hostname(), Port: port})
+}
+
+// Match returns the protected endpoint that host:port refers to, if any.
+func (p *Protected) Match(ctx context.Context, host string, port int) (ProtectedEndpoint, bool) {
+	if p == nil {
+		return ProtectedEndpoint{}, false
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	for _, ep := range p.endpoints {
+		if ep.Port == port && p.sameHost(ctx, ep.Host, host) {
+			return ep, true
+		}
+	}
+	return ProtectedEndpoint{}, false
+}
+
+// sameHost reports whether two host names refer to the same machine,
+// comparing resolved addresses. A name that does not resolve only matches
+// itself.
+func (p *Protected) sameHost(ctx context.Context, a, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	addrsA, errA := p.resolver.ResolveAll(ctx, a)
+	addrsB, errB := p.resolver.ResolveAll(ctx, b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	for _, x := range addrsA {
+		for _, y := range addrsB {
+			if net.ParseIP(x).Equal(net.ParseIP(y)) {
+				return true
+			}
+		}
+	}
+	return false
+}
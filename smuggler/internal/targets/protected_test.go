@@ -0,0 +1,49 @@
+package targets
+
+import (
+	"context"
+	"testing"
+
+	"smuggler/internal/sender"
+)
+
+func TestProtectedMatch(t *testing.T) {
+	r := sender.NewResolver(0)
+	for host, addr := range map[string]string{"proxy.test": "10.0.0.5", "alias.test": "10.0.0.5", "other.test": "10.0.0.6"} {
+		if err := r.Pin(host, addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewProtected(r)
+	p.Add("-proxy", "http://proxy.test:8080")
+	p.Add("-openai-base-url", "https://[2001:db8::1]/v1")
+	p.Add("ignored", "not a url")
+
+	tests := []struct {
+		host string
+		port int
+		role string
+	}{
+		{"proxy.test", 8080, "-proxy"},
+		{"PROXY.test", 8080, "-proxy"},
+		{"10.0.0.5", 8080, "-proxy"},
+		{"alias.test", 8080, "-proxy"},
+		{"proxy.test", 80, ""},
+		{"other.test", 8080, ""},
+		{"2001:db8::1", 443, "-openai-base-url"},
+		{"[2001:db8::1]", 443, "-openai-base-url"},
+		{"2001:db8::1", 80, ""},
+	}
+	for _, tt := range tests {
+		ep, ok := p.Match(context.Background(), tt.host, tt.port)
+		if ok != (tt.role != "") || ep.Role != tt.role {
+			t.Errorf("Match(%q, %d) = %q, %t; want %q", tt.host, tt.port, ep.Role, ok, tt.role)
+		}
+	}
+
+	var none *Protected
+	if _, ok := none.Match(context.Background(), "proxy.test", 8080); ok {
+		t.Error("nil Protected matched")
+	}
+}
@@ -0,0 +1,125 @@
+package targets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outcomes recorded for a target in a State.
+const (
+	OutcomeVulnerable = "vulnerable" // the scan flagged at least one finding
+	OutcomeClean      = "clean"      // the scan completed without findings
+	OutcomeSkipped    = "skipped"    // the target was refused or did not parse
+	OutcomeFailed     = "failed"     // the scan returned an error; retried on resume
+)
+
+// TargetState is the recorded outcome of one target.
+type TargetState struct {
+	Outcome     string    `json:"outcome"`
+	Findings    int       `json:"findings,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// State is a checkpoint of a multi-target run: the targets that have
+// completed and how. It is rewritten after every Record, so an
+// interrupted run can be resumed by skipping the targets it holds. Record
+// may be called from several goroutines.
+type State struct {
+	path string
+	mu   sync.Mutex
+
+	Version   int                    `json:"version"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	Targets   map[string]TargetState `json:"targets"`
+}
+
+// stateVersion is the format written by Record.
+const stateVersion = 1
+
+// LoadState reads the checkpoint at path. A missing file is an empty
+// state, which the first Record creates.
+func LoadState(path string) (*State, error) {
+	s := NewState(path)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if s.Targets == nil {
+		s.Targets = make(map[string]TargetState)
+	}
+	return s, nil
+}
+
+// NewState returns an empty state saved to path, ignoring anything
+// already there until the first Record overwrites it.
+func NewState(path string) *State {
+	return &State{path: path, Version: stateVersion, Targets: make(map[string]TargetState)}
+}
+
+// Done reports whether target completed in an earlier run. Failed targets
+// do not count, so a resumed run tries them again.
+func (s *State) Done(target string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts, ok := s.Targets[target]
+	return ok && ts.Outcome != OutcomeFailed
+}
+
+// Record stores the outcome of target and saves the state. The file is
+// written to a temporary file in the same directory and renamed over the
+// old one, so a crash mid-write leaves the previous checkpoint intact.
+func (s *State) Record(target string, ts TargetState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ts.CompletedAt.IsZero() {
+		ts.CompletedAt = time.Now()
+	}
+	s.Targets[target] = ts
+	s.UpdatedAt = ts.CompletedAt
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeAtomic(s.path, append(data, '\n'))
+}
+
+// writeAtomic replaces path with data via a temporary file and a rename.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
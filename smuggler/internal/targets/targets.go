@@ -0,0 +1,178 @@
+package targets
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxExpansion caps how many targets a single spec may expand to, so a
+// mistyped prefix length cannot exhaust memory before -max-targets is
+// even checked.
+var MaxExpansion = 1 << 16
+
+// rangePattern matches a numeric bracket range such as "[01-10]".
+var rangePattern = regexp.MustCompile(`\[(\d+)-(\d+)\]`)
+
+// Expand turns a target spec into the individual targets it denotes:
+//   - an IPv4 or IPv6 CIDR ("10.0.0.0/24") yields each host address,
+//     excluding the IPv4 network and broadcast addresses for prefixes
+//     shorter than /31;
+//   - numeric bracket ranges ("web[01-10].example.com") yield each value,
+//     keeping the zero padding of the range start; several ranges in one
+//     spec expand as a cross product;
+//   - anything else is returned unchanged.
+func Expand(spec string) ([]string, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	if !strings.Contains(spec, "://") && strings.Contains(spec, "/") {
+		if _, ipnet, err := net.ParseCIDR(spec); err == nil {
+			return expandCIDR(ipnet)
+		}
+	}
+
+	if rangePattern.MatchString(spec) {
+		return expandRanges(spec)
+	}
+
+	return []string{spec}, nil
+}
+
+func expandCIDR(ipnet *net.IPNet) ([]string, error) {
+	ones, bits := ipnet.Mask.Size()
+	hostBits := bits - ones
+	if hostBits > 30 || (1<<hostBits) > MaxExpansion+2 {
+		return nil, fmt.Errorf("CIDR %s expands to more than %d targets", ipnet, MaxExpansion)
+	}
+
+	total := 1 << hostBits
+	first, last := 0, total-1
+	if bits == 32 && hostBits >= 2 {
+		// skip the network and broadcast addresses
+		first, last = 1, total-2
+	}
+	if last-first+1 > MaxExpansion {
+		return nil, fmt.Errorf("CIDR %s expands to more than %d targets", ipnet, MaxExpansion)
+	}
+
+	base := new(big.Int).SetBytes(ipnet.IP)
+	out := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		n := new(big.Int).Add(base, big.NewInt(int64(i)))
+		ip := make(net.IP, len(ipnet.IP))
+		n.FillBytes(ip)
+		out = append(out, ip.String())
+	}
+	return out, nil
+}
+
+func expandRanges(spec string) ([]string, error) {
+	loc := rangePattern.FindStringSubmatchIndex(spec)
+	if loc == nil {
+		return []string{spec}, nil
+	}
+
+	startStr := spec[loc[2]:loc[3]]
+	endStr := spec[loc[4]:loc[5]]
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range start %q in %s", startStr, spec)
+	}
+	end, err := strconv.Atoi(endStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range end %q in %s", endStr, spec)
+	}
+	if end < start {
+		return nil, fmt.Errorf("range [%s-%s] in %s is descending", startStr, endStr, spec)
+	}
+
+	width := 0
+	if len(startStr) > 1 && startStr[0] == '0' {
+		width = len(startStr)
+	}
+
+	prefix, suffix := spec[:loc[0]], spec[loc[1]:]
+	var out []string
+	for i := start; i <= end; i++ {
+		rest, err := expandRanges(suffix)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rest {
+			out = append(out, fmt.Sprintf("%s%0*d%s", prefix, width, i, r))
+			if len(out) > MaxExpansion {
+				return nil, fmt.Errorf("%s expands to more than %d targets", spec, MaxExpansion)
+			}
+		}
+	}
+	return out, nil
+}
+
+// ParsePorts parses a port list such as "80,443,8000-8010".
+func ParsePorts(spec string) ([]int, error) {
+	var ports []int
+	seen := make(map[int]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i > 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		start, err1 := strconv.Atoi(lo)
+		end, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || start < 1 || end > 65535 || end < start {
+			return nil, fmt.Errorf("invalid port or range %q", part)
+		}
+
+		for p := start; p <= end; p++ {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+	return ports, nil
+}
+
+// HasPort reports whether a target spec already names its port, either as
+// a URL (whose scheme implies one) or as host:port.
+func HasPort(target string) bool {
+	if strings.Contains(target, "://") {
+		return true
+	}
+	_, _, err := net.SplitHostPort(target)
+	return err == nil
+}
+
+// WithPorts pairs every target that does not name a port with each port.
+// Targets that already name one are kept as they are. IPv6 hosts may be
+// given bare or in brackets.
+func WithPorts(list []string, ports []int) []string {
+	if len(ports) == 0 {
+		return list
+	}
+
+	out := make([]string, 0, len(list)*len(ports))
+	for _, t := range list {
+		if HasPort(t) {
+			out = append(out, t)
+			continue
+		}
+		host := strings.TrimSuffix(strings.TrimPrefix(t, "["), "]")
+		for _, p := range ports {
+			out = append(out, net.JoinHostPort(host, strconv.Itoa(p)))
+		}
+	}
+	return out
+}
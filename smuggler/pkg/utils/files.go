@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReportFileName builds a filesystem-safe per-target file name such as
+// "example.com_443.sarif". Characters outside [A-Za-z0-9._-] become '_',
+// so IPv6 hosts and stray separators cannot escape the output directory.
+func ReportFileName(host string, port int, ext string) string {
+	name := host + "_" + strconv.Itoa(port)
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+
+	safe := strings.TrimLeft(b.String(), ".")
+	if safe == "" {
+		safe = "target"
+	}
+	return safe + "." + ext
+}
+
+// CreateReportFile creates (or truncates) the per-target report file in
+// dir, creating dir if needed. Each target gets its own file, so parallel
+// workers never write to the same one.
+func CreateReportFile(dir, host string, port int, ext string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(filepath.Join(dir, ReportFileName(host, port, ext)))
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"html/template"
+	"strings"
+	"time"
+
+	"smuggler/internal/models"
+)
+
+// htmlBodyPreview caps how much of each response body is shown in the
+// HTML report.
+const htmlBodyPreview = 2048
+
+type htmlResponse struct {
+	Status    int
+	TimingMS  int64
+	BodySize  int
+	Body      string
+	Truncated bool
+}
+
+type htmlFinding struct {
+	Index      int
+	Target     string
+	Technique  string
+	Verdict    string
+	Class      string
+	Percent    int
+	Severity   string
+	ReasonCode string
+	Reason     string
+	Baseline   *htmlResponse
+	Test       *htmlResponse
+}
+
+type htmlReport struct {
+	Title      string
+	Generated  string
+	Total      int
+	Vulnerable int
+	Findings   []htmlFinding
+}
+
+// The template escapes everything it prints, so bodies echoed by a
+// malicious target cannot script the page that displays them.
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 6px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+.bar { background: #eee; width: 120px; height: 10px; display: inline-block; }
+.bar span { background: #c33; height: 10px; display: block; }
+.suspicious { color: #c33; font-weight: bold; }
+.blocked { color: #b80; }
+.clean { color: #393; }
+pre { background: #f7f7f7; padding: 8px; white-space: pre-wrap; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{.Generated}} &middot; {{.Total}} tests &middot; {{.Vulnerable}} suspicious</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>#</th><th>Target</th><th>Technique</th><th>Verdict</th><th>Confidence</th><th>Severity</th><th>Reason code</th></tr>
+{{range .Findings}}<tr>
+<td><a href="#f{{.Index}}">{{.Index}}</a></td>
+<td>{{.Target}}</td>
+<td>{{.Technique}}</td>
+<td class="{{.Class}}">{{.Verdict}}</td>
+<td><span class="bar"><span style="width: {{.Percent}}%"></span></span> {{.Percent}}%</td>
+<td>{{.Severity}}</td>
+<td>{{.ReasonCode}}</td>
+</tr>
+{{end}}</table>
+
+<h2>Findings</h2>
+{{range .Findings}}<h3 id="f{{.Index}}">{{.Index}}. {{.Technique}} &ndash; <span class="{{.Class}}">{{.Verdict}}</span></h3>
+<table>
+<tr><th></th><th>Status</th><th>Timing</th><th>Body size</th></tr>
+{{with .Baseline}}<tr><td>Baseline</td><td>{{.Status}}</td><td>{{.TimingMS}} ms</td><td>{{.BodySize}} bytes</td></tr>{{end}}
+{{with .Test}}<tr><td>Test</td><td>{{.Status}}</td><td>{{.TimingMS}} ms</td><td>{{.BodySize}} bytes</td></tr>{{end}}
+</table>
+{{if .Reason}}<pre>{{.Reason}}</pre>{{end}}
+{{with .Test}}{{if .Body}}<details><summary>Test response body{{if .Truncated}} (truncated){{end}}</summary><pre>{{.Body}}</pre></details>{{end}}{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// GenerateHTMLReport renders results as a self-contained HTML page: a
+// summary table with confidence bars, then each result's baseline and test
+// responses and the detector's reasoning.
+func GenerateHTMLReport(results []*models.ScanResult, target string) (string, error) {
+	report := htmlReport{
+		Title:     "HTTP Request Smuggling Report: " + target,
+		Generated: time.Now().Format(time.RFC1123),
+	}
+
+	for _, sr := range results {
+		if sr == nil {
+			continue
+		}
+		report.Total++
+
+		f := htmlFinding{
+			Index:      report.Total,
+			Target:     sr.Target,
+			Technique:  sr.Technique,
+			Verdict:    "Clean",
+			Class:      "clean",
+			Percent:    int(sr.GetConfidence()*100 + 0.5),
+			Severity:   findingSeverity(sr),
+			ReasonCode: sr.ReasonCode,
+			Reason:     sr.Reason,
+			Baseline:   newHTMLResponse(sr.BaselineResponse),
+			Test:       newHTMLResponse(sr.TestResponse),
+		}
+		switch {
+		case sr.Suspicious:
+			report.Vulnerable++
+			f.Verdict, f.Class = "Suspicious", "suspicious"
+		case sr.Blocked:
+			f.Verdict, f.Class = "Blocked", "blocked"
+		case sr.WAFDetected:
+			f.Verdict, f.Class = "WAF", "blocked"
+		case sr.Stalled:
+			f.Verdict, f.Class = "Stalled", "blocked"
+		}
+		report.Findings = append(report.Findings, f)
+	}
+
+	var b strings.Builder
+	if err := htmlReportTemplate.Execute(&b, report); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func newHTMLResponse(resp *models.HTTPResponse) *htmlResponse {
+	if resp == nil {
+		return nil
+	}
+	r := &htmlResponse{
+		Status:    resp.StatusCode,
+		TimingMS:  resp.TimingMS,
+		BodySize:  len(resp.Body),
+		Body:      resp.Body,
+		Truncated: resp.Truncated,
+	}
+	if len(r.Body) > htmlBodyPreview {
+		r.Body = r.Body[:htmlBodyPreview]
+		r.Truncated = true
+	}
+	return r
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"smuggler/internal/detector"
+	"smuggler/internal/models"
+)
+
+// NucleiInfo mirrors the "info" block of a Nuclei result.
+type NucleiInfo struct {
+	Name        string   `json:"name"`
+	Author      []string `json:"author"`
+	Tags        []string `json:"tags"`
+	Description string   `json:"description,omitempty"`
+	Severity    string   `json:"severity"`
+}
+
+// NucleiResult mirrors the subset of Nuclei's JSON result schema that
+// triage pipelines key on.
+type NucleiResult struct {
+	TemplateID    string     `json:"template-id"`
+	Info          NucleiInfo `json:"info"`
+	Type          string     `json:"type"`
+	Host          string     `json:"host"`
+	MatchedAt     string     `json:"matched-at"`
+	Timestamp     time.Time  `json:"timestamp"`
+	MatcherStatus bool       `json:"matcher-status"`
+}
+
+// NucleiTemplateID maps a technique name to a synthetic template id,
+// e.g. "CL.TE" -> "http-request-smuggling-clte".
+func NucleiTemplateID(technique string) string {
+	t := strings.ToLower(strings.ReplaceAll(technique, ".", ""))
+
+	var b strings.Builder
+	dash := false
+	for _, r := range t {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	return "http-request-smuggling-" + strings.TrimSuffix(b.String(), "-")
+}
+
+// findingSeverity returns the severity recorded on a result, or the
+// detector's default mapping for results recorded without one.
+func findingSeverity(sr *models.ScanResult) string {
+	if sr.Severity != "" {
+		return string(sr.Severity)
+	}
+	return string(detector.NewDetector().Severity(sr))
+}
+
+// WriteNucleiJSON writes one Nuclei-compatible JSON line per suspicious
+// result. targetURL is reported as matched-at; its host:port as host.
+func WriteNucleiJSON(w io.Writer, targetURL string, results []*models.ScanResult) error {
+	host := targetURL
+	if u, err := url.Parse(targetURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, r := range results {
+		if r == nil || !r.Suspicious {
+			continue
+		}
+
+		nr := NucleiResult{
+			TemplateID: NucleiTemplateID(r.Technique),
+			Info: NucleiInfo{
+				Name:        "HTTP Request Smuggling (" + r.Technique + ")",
+				Author:      []string{"smuggler"},
+				Tags:        []string{"http", "smuggling", "desync"},
+				Description: r.Reason,
+				Severity:    findingSeverity(r),
+			},
+			Type:          "http",
+			Host:          host,
+			MatchedAt:     targetURL,
+			Timestamp:     time.Now().UTC(),
+			MatcherStatus: true,
+		}
+
+		b, err := json.Marshal(nr)
+		if err != nil {
+			return err
+		}
+		if _, err := bw.Write(b); err != nil {
+			return err
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -66,6 +66,33 @@ func WriteJSONLines(w io.Writer, results []*models.ScanResult) error {
     return bw.Flush()
 }
 
+// WriteJSONArray writes ScanResults as a single indented JSON array.
+func WriteJSONArray(w io.Writer, results []*models.ScanResult) error {
+    out := make([]*models.ScanResult, 0, len(results))
+    for _, r := range results {
+        if r == nil {
+            continue
+        }
+        if r.BaselineResponse != nil && r.BaselineResponse.Error != nil {
+            r.BaselineResponse.ErrorString = r.BaselineResponse.Error.Error()
+        }
+        if r.TestResponse != nil && r.TestResponse.Error != nil {
+            r.TestResponse.ErrorString = r.TestResponse.Error.Error()
+        }
+        out = append(out, r)
+    }
+
+    b, err := json.MarshalIndent(out, "", "  ")
+    if err != nil {
+        return err
+    }
+    if _, err := w.Write(b); err != nil {
+        return err
+    }
+    _, err = io.WriteString(w, "\n")
+    return err
+}
+
 // GroupByThread groups ScanResults by thread ID. Results without thread ID
 // are grouped under the key "__no_thread".
 func GroupByThread(results []*models.ScanResult) map[string][]*models.ScanResult {
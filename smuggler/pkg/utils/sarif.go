@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"smuggler/internal/detector"
+	"smuggler/internal/models"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	// sarifFingerprintKey versions the fingerprint scheme; bump it if the
+	// hashed fields ever change so old alerts are not silently re-matched.
+	sarifFingerprintKey = "smugglerFinding/v1"
+)
+
+// sarifHelpURIs links each technique family to an explanation of it.
+var sarifHelpURIs = map[string]string{
+	"CL.TE":            "https://portswigger.net/web-security/request-smuggling#cl-te-vulnerabilities",
+	"TE.CL":            "https://portswigger.net/web-security/request-smuggling#te-cl-vulnerabilities",
+	"Mixed-TE":         "https://portswigger.net/web-security/request-smuggling#te-te-behavior-obfuscating-the-te-header",
+	"TE.TE":            "https://portswigger.net/web-security/request-smuggling#te-te-behavior-obfuscating-the-te-header",
+	"Obfuscated-TE":    "https://portswigger.net/web-security/request-smuggling#te-te-behavior-obfuscating-the-te-header",
+	"Chunk-Ext":        "https://www.rfc-editor.org/rfc/rfc9112#section-7.1.1",
+	"CL.TE-GPOST":      "https://portswigger.net/web-security/request-smuggling/exploiting",
+	"Header-Injection": "https://portswigger.net/web-security/request-smuggling/advanced/response-queue-poisoning",
+	"CL.0":             "https://portswigger.net/research/browser-powered-desync-attacks",
+}
+
+const sarifDefaultHelpURI = "https://portswigger.net/web-security/request-smuggling"
+
+// SARIFTarget groups the results of one scanned target.
+type SARIFTarget struct {
+	URL     string
+	Results []*models.ScanResult
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string          `json:"id"`
+	Name             string          `json:"name"`
+	ShortDescription sarifMessage    `json:"shortDescription"`
+	HelpURI          string          `json:"helpUri"`
+	Properties       sarifProperties `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          sarifProperties   `json:"properties"`
+}
+
+type sarifProperties struct {
+	Severity         string `json:"severity,omitempty"`
+	SecuritySeverity string `json:"security-severity,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifLevel maps a finding severity onto SARIF's result levels.
+func sarifLevel(sr *models.ScanResult) string {
+	switch findingSeverity(sr) {
+	case "critical", "high":
+		return "error"
+	case "medium":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// sarifSecuritySeverity maps a finding severity onto the CVSS-style score
+// GitHub code scanning uses to rank security alerts.
+func sarifSecuritySeverity(sr *models.ScanResult) string {
+	switch findingSeverity(sr) {
+	case "critical":
+		return "9.5"
+	case "high":
+		return "8.0"
+	case "medium":
+		return "5.5"
+	case "low":
+		return "3.0"
+	default:
+		return "0.0"
+	}
+}
+
+// SARIFFingerprint hashes target, technique and reason code. None of them
+// carry measured values, so a finding keeps its fingerprint across runs
+// and changes only when a different set of signals fires.
+func SARIFFingerprint(targetURL string, sr *models.ScanResult) string {
+	reasonCode := sr.ReasonCode
+	if reasonCode == "" {
+		reasonCode = detector.ReasonCode(sr.Technique, sr.Signals)
+	}
+	sum := sha256.Sum256([]byte(targetURL + "\x00" + sr.Technique + "\x00" + reasonCode))
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteSARIF writes the suspicious results of all targets as one SARIF log
+// suitable for GitHub code scanning upload.
+func WriteSARIF(w io.Writer, targets []SARIFTarget) error {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "smuggler", Rules: []sarifRule{}}},
+		Results: []sarifResult{},
+	}
+	rules := make(map[string]int)
+
+	for _, t := range targets {
+		for _, r := range t.Results {
+			if r == nil || !r.Suspicious {
+				continue
+			}
+
+			family := detector.TechniqueFamily(r.Technique)
+			ruleID := NucleiTemplateID(family)
+			if _, ok := rules[ruleID]; !ok {
+				rules[ruleID] = len(run.Tool.Driver.Rules)
+				helpURI, ok := sarifHelpURIs[family]
+				if !ok {
+					helpURI = sarifDefaultHelpURI
+				}
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+					ID:               ruleID,
+					Name:             "HTTPRequestSmuggling" + strings.NewReplacer(".", "", "-", "").Replace(family),
+					ShortDescription: sarifMessage{Text: "HTTP Request Smuggling (" + family + ")"},
+					HelpURI:          helpURI,
+				})
+			}
+
+			// GitHub ranks alerts by their rule's security-severity, so the
+			// rule carries the worst finding filed under it.
+			rule := &run.Tool.Driver.Rules[rules[ruleID]]
+			if score := sarifSecuritySeverity(r); rule.Properties.SecuritySeverity == "" || score > rule.Properties.SecuritySeverity {
+				rule.Properties.SecuritySeverity = score
+			}
+
+			run.Results = append(run.Results, sarifResult{
+				RuleID:  ruleID,
+				Level:   sarifLevel(r),
+				Message: sarifMessage{Text: r.Technique + " at " + t.URL + ": " + r.Reason},
+				Locations: []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: t.URL},
+					},
+				}},
+				PartialFingerprints: map[string]string{
+					sarifFingerprintKey: SARIFFingerprint(t.URL, r),
+				},
+				Properties: sarifProperties{Severity: findingSeverity(r)},
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{run},
+	})
+}